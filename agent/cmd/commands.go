@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"miniPanel-agent/internal/agent"
+	"miniPanel-agent/internal/config"
+	"miniPanel-agent/internal/wire"
+)
+
+// runOnce 采集并发送一次数据，失败时返回非零
+func runOnce(cfg *config.Config) int {
+	a, err := agent.New(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "创建Agent失败: %v\n", err)
+		return 1
+	}
+	if err := a.Once(); err != nil {
+		fmt.Fprintf(os.Stderr, "发送失败: %v\n", err)
+		return 1
+	}
+	fmt.Println("数据发送成功")
+	return 0
+}
+
+// runDryRun 采集一次数据并输出将要发送的JSON，不连接服务器
+func runDryRun(cfg *config.Config) int {
+	// 只采集，不创建客户端
+	dry := *cfg
+	dry.Server.URL = ""
+	dry.Servers = nil
+	dry.Pull.Enabled = false
+
+	a, err := agent.New(&dry)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "创建Agent失败: %v\n", err)
+		return 1
+	}
+	metrics, err := a.Collect()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "数据采集失败: %v\n", err)
+		return 1
+	}
+
+	// 与实际发送的编码相同，包含协议版本和发送时间
+	data, err := wire.EncodeJSON(metrics, time.Now())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "序列化失败: %v\n", err)
+		return 1
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		fmt.Fprintf(os.Stderr, "序列化失败: %v\n", err)
+		return 1
+	}
+	fmt.Println(out.String())
+	return 0
+}
+
+// runCheck 校验配置并测试与服务器的连接和认证
+func runCheck(cfg *config.Config) int {
+	fmt.Println("配置校验通过")
+
+	a, err := agent.New(cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "创建HTTP客户端失败: %v\n", err)
+		return 1
+	}
+
+	upstreams := a.Upstreams()
+	if len(upstreams) == 0 {
+		fmt.Println("未配置服务器地址（仅拉取模式），跳过连接检查")
+		return 0
+	}
+
+	// 逐个检查所有服务器，任何一个失败都返回非零
+	code := 0
+	for _, u := range upstreams {
+		result, err := u.Client.Check()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "连接检查失败: %s: %v\n", u.Name, err)
+			code = 1
+			continue
+		}
+
+		fmt.Printf("服务器连接正常: %s\n", u.Name)
+		fmt.Printf("节点名称: %s（来源: %s，客户端IP: %s）\n", result.NodeName, result.Identity, result.ClientIP)
+		if result.NodeID != 0 {
+			fmt.Printf("已登记的节点ID: %d\n", result.NodeID)
+		}
+	}
+	return code
+}
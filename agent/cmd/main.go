@@ -2,26 +2,37 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
-	"time"
 
-	"miniPanel-agent/internal/client"
-	"miniPanel-agent/internal/collector"
+	"miniPanel-agent/internal/agent"
 	"miniPanel-agent/internal/config"
+	"miniPanel-agent/internal/version"
 )
 
 func main() {
 	// 命令行参数
 	configPath := flag.String("config", "/etc/miniPanel/agent.json", "配置文件路径")
+	once := flag.Bool("once", false, "采集并发送一次数据后退出，失败时返回非零")
+	dryRun := flag.Bool("dry-run", false, "采集一次数据并输出将要发送的JSON，不连接服务器")
+	check := flag.Bool("check", false, "校验配置并测试与服务器的连接和认证")
+	showVersion := flag.Bool("version", false, "输出版本号后退出")
 	flag.Parse()
 
+	if *showVersion {
+		fmt.Println(version.Version)
+		return
+	}
+
 	// 加载配置
 	var cfg *config.Config
 
-	if _, err := os.Stat(*configPath); os.IsNotExist(err) {
+	if _, err := os.Stat(*configPath); os.IsNotExist(err) && *check {
+		log.Fatalf("配置文件 %s 不存在", *configPath)
+	} else if os.IsNotExist(err) {
 		// 配置文件不存在，使用默认配置
 		log.Printf("配置文件 %s 不存在，使用默认配置", *configPath)
 		cfg = config.DefaultConfig()
@@ -33,77 +44,112 @@ func main() {
 		}
 	}
 
-	log.Printf("MiniPanel Agent 启动")
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("配置无效:\n%v", err)
+	}
+
+	switch {
+	case *check:
+		os.Exit(runCheck(cfg))
+	case *dryRun:
+		os.Exit(runDryRun(cfg))
+	case *once:
+		os.Exit(runOnce(cfg))
+	}
+
+	log.Printf("MiniPanel Agent %s 启动", version.Version)
 	log.Printf("节点名称: %s", cfg.Agent.NodeName)
-	log.Printf("服务器地址: %s", cfg.Server.URL)
+	for _, server := range cfg.Destinations() {
+		log.Printf("服务器地址: %s", server.URL)
+	}
+	if len(cfg.Servers) > 1 {
+		log.Printf("多服务器模式: %s", cfg.ServerMode)
+	}
+	if cfg.Pull.Enabled {
+		log.Printf("拉取模式: %s", cfg.Pull.Listen)
+	}
 	log.Printf("采集间隔: %d秒", cfg.Agent.Interval)
 
-	// 创建数据采集器
-	collectorInstance := collector.NewCollector(
-		cfg.Collector.CPU,
-		cfg.Collector.Memory,
-		cfg.Collector.Temp,
-	)
-
-	// 创建HTTP客户端
-	clientInstance := client.NewClient(cfg.Server.URL, cfg.Agent.NodeName)
-
-	// 测试连接
-	log.Printf("测试服务器连接...")
-	if err := clientInstance.TestConnection(); err != nil {
-		log.Printf("警告: 无法连接到服务器: %v", err)
-		log.Printf("Agent将继续运行，稍后重试连接")
-	} else {
-		log.Printf("服务器连接正常")
+	a, err := agent.New(cfg)
+	if err != nil {
+		log.Fatalf("创建HTTP客户端失败: %v", err)
+	}
+
+	// 上次升级的新版本反复重启时已回滚，重启运行旧版本
+	if a.StartupUpdate() {
+		a.Restart()
+	}
+
+	// 拉取模式：提供HTTP接口由服务器拉取
+	if pullServer := a.PullServer(); pullServer != nil {
+		go pullServer.ListenAndServe(cfg.Pull.Listen)
+	}
+
+	// 本地状态接口
+	if cfg.Status.Enabled {
+		if err := a.ServeStatus(cfg.Status.Listen); err != nil {
+			log.Printf("状态接口启动失败: %v", err)
+		}
 	}
 
-	// 创建定时器
-	ticker := time.NewTicker(time.Duration(cfg.Agent.Interval) * time.Second)
-	defer ticker.Stop()
+	// 未配置服务器地址时只使用拉取模式
+	if upstreams := a.Upstreams(); len(upstreams) > 0 {
+		// 测试连接
+		log.Printf("测试服务器连接...")
+		for _, u := range upstreams {
+			if err := u.Client.TestConnection(); err != nil {
+				log.Printf("警告: 无法连接到服务器 %s: %v", u.Name, err)
+				log.Printf("Agent将继续运行，稍后重试连接")
+			} else {
+				log.Printf("服务器连接正常: %s", u.Name)
+			}
+		}
+	} else if !cfg.Pull.Enabled {
+		log.Fatalf("未配置服务器地址，也未开启拉取模式")
+	}
 
 	// 监听系统信号
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-	// 立即执行一次数据采集
-	collectAndSend(collectorInstance, clientInstance)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 
 	// 主循环
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		a.Run(stop)
+		close(done)
+	}()
+
+	restart := false
+loop:
 	for {
 		select {
-		case <-ticker.C:
-			// 定时采集和发送数据
-			collectAndSend(collectorInstance, clientInstance)
-
 		case sig := <-sigChan:
+			if sig == syscall.SIGHUP {
+				// 重新加载配置，失败时继续使用原配置
+				log.Printf("收到信号 %v，重新加载配置 %s", sig, *configPath)
+				newCfg, err := config.LoadConfig(*configPath)
+				if err == nil {
+					err = a.Reload(newCfg)
+				}
+				if err != nil {
+					log.Printf("重新加载配置失败，继续使用原配置:\n%v", err)
+				}
+				continue
+			}
 			log.Printf("收到信号 %v，正在关闭Agent...", sig)
-			return
-		}
-	}
-}
+			break loop
 
-// collectAndSend 采集数据并发送到服务器
-func collectAndSend(collector *collector.Collector, client *client.Client) {
-	// 采集监控数据
-	metrics, err := collector.CollectMetrics()
-	if err != nil {
-		log.Printf("数据采集失败: %v", err)
-		return
+		case <-a.RestartRequested():
+			log.Printf("程序文件已替换，正在重启Agent...")
+			restart = true
+			break loop
+		}
 	}
+	close(stop)
+	<-done
 
-	log.Printf("采集数据 - CPU: %.2f%%, 内存: %.2f%% (%.2fGB/%.2fGB), CPU温度: %.1f°C",
-		metrics.CPUPercent,
-		metrics.MemoryPercent,
-		float64(metrics.MemoryUsed)/1024/1024/1024,
-		float64(metrics.MemoryTotal)/1024/1024/1024,
-		metrics.CPUTemp)
-
-	// 发送数据到服务器
-	err = client.SendMetrics(metrics)
-	if err != nil {
-		log.Printf("数据发送失败: %v", err)
-		return
+	if restart {
+		a.Restart()
 	}
-
-	log.Printf("数据发送成功")
-}
\ No newline at end of file
+}
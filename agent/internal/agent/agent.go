@@ -0,0 +1,416 @@
+// Package agent 组织Agent的采集、发送和状态记录
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"miniPanel-agent/internal/checks"
+	"miniPanel-agent/internal/client"
+	"miniPanel-agent/internal/collector"
+	"miniPanel-agent/internal/config"
+	"miniPanel-agent/internal/pull"
+	"miniPanel-agent/internal/update"
+)
+
+// Agent 按配置的间隔采集数据并发送到一个或多个服务器，发送失败的数据暂存在 spool 中
+type Agent struct {
+	cfg       *config.Config       // 生效的配置
+	local     *config.Config       // 配置文件中的配置
+	remote    *client.RemoteConfig // 已应用的服务器配置，没有时为 nil
+	collector *collector.Collector
+	checks    *checks.Runner           // 未配置服务检查时为 nil
+	units     *collector.UnitCollector // 未配置 systemd 单元时为 nil
+	dests     []*destination           // 上报服务器，未配置服务器地址时为空
+	active    int                      // failover 模式当前使用的服务器
+	pull      *pull.Server             // 未开启拉取模式时为 nil
+	labels    map[string]string
+	startedAt time.Time
+
+	reloads  chan reloadRequest
+	commands chan client.Command // 服务器通过 gRPC 流下发的命令，Run 运行期间不为 nil
+	ctx      context.Context     // Run 停止时取消，中断发送重试
+
+	updater           *update.Updater // 无法确定二进制文件路径时为 nil
+	restart           chan struct{}
+	restarting        bool
+	lastUpdateTarget  string
+	lastUpdateAttempt time.Time
+
+	mu     sync.Mutex
+	status runStatus
+}
+
+// reloadRequest 交给采集循环应用的新配置
+type reloadRequest struct {
+	cfg    *config.Config
+	result chan error
+}
+
+// runStatus 最近一次采集和发送的结果
+type runStatus struct {
+	lastCollectAt  time.Time
+	lastCollectErr error
+	lastMetrics    *collector.MetricsData
+	lastChecks     []checks.Result
+	lastUnits      []collector.UnitStatus
+	lastUnitsErr   error
+	send           sendStatus // 所有服务器合计，至少一个服务器收到数据即为成功
+	configVersion  string
+	updateMarker   *update.Marker
+	updateErr      error
+}
+
+// New 根据配置创建Agent
+func New(cfg *config.Config) (*Agent, error) {
+	a := &Agent{
+		cfg:   cfg,
+		local: cfg,
+		collector: collector.NewCollector(
+			cfg.Collector.CPU,
+			cfg.Collector.Memory,
+			cfg.Collector.Temp,
+		),
+		labels:    cfg.Agent.Labels,
+		startedAt: time.Now(),
+		reloads:   make(chan reloadRequest),
+		ctx:       context.Background(),
+		restart:   make(chan struct{}),
+	}
+
+	// 升级和回滚需要知道当前二进制文件的位置
+	if u, err := update.New(); err != nil {
+		log.Printf("无法确定Agent程序路径，自动升级不可用: %v", err)
+	} else {
+		a.updater = u
+	}
+
+	if len(cfg.Checks) > 0 {
+		a.checks = checks.NewRunner(cfg.Checks)
+	}
+	if len(cfg.Systemd.Units) > 0 {
+		a.units = collector.NewUnitCollector(cfg.Systemd.Units)
+	}
+
+	// 节点标签随每次上报发送
+	if a.labels == nil {
+		a.labels = map[string]string{}
+	}
+
+	dests, err := a.buildDestinations(cfg)
+	if err != nil {
+		return nil, err
+	}
+	a.dests = dests
+	if cfg.Pull.Enabled {
+		a.pull = pull.NewServer(cfg.Agent.NodeName, cfg.Pull.Token)
+	}
+	return a, nil
+}
+
+// PullServer 返回拉取接口，未开启拉取模式时为 nil
+func (a *Agent) PullServer() *pull.Server {
+	return a.pull
+}
+
+// Run 立即执行一次采集，之后按间隔执行，直到 stop 关闭
+func (a *Agent) Run(stop <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	a.ctx = ctx
+
+	// gRPC 流在 Run 期间保持连接，-once 模式只使用 HTTP
+	a.commands = make(chan client.Command, 1)
+	a.openStreams()
+	defer func() {
+		for _, d := range a.dests {
+			d.client.Close()
+		}
+	}()
+
+	interval := a.cfg.Agent.Interval
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	a.Tick()
+	a.checkUpdate()
+	for {
+		select {
+		case <-ticker.C:
+			a.Tick()
+			a.checkUpdate()
+		case req := <-a.reloads:
+			req.result <- a.reloadLocal(req.cfg)
+		case cmd := <-a.commands:
+			a.handleCommand(cmd)
+		case <-stop:
+			return
+		}
+
+		// 本地或服务器配置修改了采集间隔
+		if a.cfg.Agent.Interval != interval {
+			interval = a.cfg.Agent.Interval
+			ticker.Reset(time.Duration(interval) * time.Second)
+		}
+	}
+}
+
+// Reload 校验并应用新的本地配置，重建采集器和客户端，暂存的数据保留。
+// 配置无效时返回错误并继续使用原配置；需要在 Run 运行期间调用
+func (a *Agent) Reload(cfg *config.Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	old := a.cfg
+	a.mu.Unlock()
+
+	// 监听地址在启动时绑定，修改后需要重启才能生效
+	if cfg.Pull.Enabled != old.Pull.Enabled || cfg.Pull.Listen != old.Pull.Listen {
+		log.Printf("pull.enabled 和 pull.listen 需要重启Agent才能生效")
+		cfg.Pull.Enabled, cfg.Pull.Listen = old.Pull.Enabled, old.Pull.Listen
+	}
+	if cfg.Status != old.Status {
+		log.Printf("status 配置需要重启Agent才能生效")
+		cfg.Status = old.Status
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	req := reloadRequest{cfg: cfg, result: make(chan error, 1)}
+	a.reloads <- req
+	return <-req.result
+}
+
+// reloadLocal 在采集循环中替换本地配置，已获取的服务器配置继续生效
+func (a *Agent) reloadLocal(local *config.Config) error {
+	remote := a.remote
+	if !local.Remote.Enabled {
+		remote = nil
+	}
+
+	var overlay *config.Remote
+	if remote != nil {
+		overlay = &remote.Config
+	}
+	changes, err := a.applyConfig(local.WithRemote(overlay))
+	if err != nil {
+		return err
+	}
+
+	a.local = local
+	a.setRemote(remote)
+	logChanges("配置已重新加载", changes)
+	return nil
+}
+
+// applyConfig 校验并应用生效的配置，返回变化的配置项
+func (a *Agent) applyConfig(cfg *config.Config) ([]string, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	changes := config.Diff(a.cfg, cfg)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return changes, a.apply(cfg)
+}
+
+func logChanges(title string, changes []string) {
+	if len(changes) == 0 {
+		log.Printf("%s，配置没有变化", title)
+		return
+	}
+	log.Printf("%s:", title)
+	for _, change := range changes {
+		log.Printf("  %s", change)
+	}
+}
+
+// apply 在采集循环中替换配置，先创建新的客户端，失败时不做任何修改
+func (a *Agent) apply(cfg *config.Config) error {
+	old := a.cfg
+
+	dests, active := a.dests, a.active
+	if !slices.Equal(cfg.Destinations(), old.Destinations()) || cfg.ServerMode != old.ServerMode ||
+		cfg.Agent.NodeName != old.Agent.NodeName {
+		var err error
+		dests, err = a.buildDestinations(cfg)
+		if err != nil {
+			return err
+		}
+		active = 0
+	}
+
+	if cfg.Collector != old.Collector {
+		a.collector = collector.NewCollector(cfg.Collector.CPU, cfg.Collector.Memory, cfg.Collector.Temp)
+	}
+	if !slices.Equal(cfg.Checks, old.Checks) {
+		a.checks = nil
+		if len(cfg.Checks) > 0 {
+			a.checks = checks.NewRunner(cfg.Checks)
+		}
+	}
+	if !slices.Equal(cfg.Systemd.Units, old.Systemd.Units) {
+		a.units = nil
+		if len(cfg.Systemd.Units) > 0 {
+			a.units = collector.NewUnitCollector(cfg.Systemd.Units)
+		}
+	}
+	a.labels = cfg.Agent.Labels
+	if a.labels == nil {
+		a.labels = map[string]string{}
+	}
+	if a.pull != nil {
+		a.pull.SetAuth(cfg.Agent.NodeName, cfg.Pull.Token)
+	}
+
+	a.mu.Lock()
+	oldDests := a.dests
+	a.cfg = cfg
+	a.dests, a.active = dests, active
+	a.mu.Unlock()
+
+	closeUnused(oldDests, dests)
+	a.openStreams()
+
+	for _, s := range a.spools() {
+		s.Resize(cfg.Agent.SpoolSize)
+	}
+	return nil
+}
+
+// Tick 采集一次数据，更新拉取接口并发送到服务器
+func (a *Agent) Tick() {
+	metrics, err := a.Collect()
+	if err != nil {
+		log.Printf("数据采集失败: %v", err)
+		return
+	}
+
+	log.Printf("采集数据 - CPU: %.2f%%, 内存: %.2f%% (%.2fGB/%.2fGB), CPU温度: %.1f°C",
+		metrics.CPUPercent,
+		metrics.MemoryPercent,
+		float64(metrics.MemoryUsed)/1024/1024/1024,
+		float64(metrics.MemoryTotal)/1024/1024/1024,
+		metrics.CPUTemp)
+	for _, r := range metrics.Checks {
+		if r.Status != checks.StatusOK {
+			log.Printf("服务检查 %s 失败: %s", r.Name, r.Message)
+		}
+	}
+	for _, u := range metrics.Units {
+		if u.ActiveState == "failed" {
+			log.Printf("systemd 单元 %s 处于 failed 状态", u.Name)
+		}
+	}
+
+	if a.pull != nil {
+		a.pull.Update(metrics)
+	}
+	if len(a.dests) == 0 {
+		return
+	}
+
+	if err := a.Send(metrics); err != nil {
+		log.Printf("数据发送失败: %v", err)
+		return
+	}
+	log.Printf("数据发送成功")
+}
+
+// Once 采集并发送一次数据，用于 -once 模式
+func (a *Agent) Once() error {
+	if len(a.dests) == 0 {
+		return fmt.Errorf("server.url is not configured")
+	}
+
+	metrics, err := a.Collect()
+	if err != nil {
+		return fmt.Errorf("collect: %v", err)
+	}
+	if err := a.once(metrics); err != nil {
+		return fmt.Errorf("send: %v", err)
+	}
+	return nil
+}
+
+// Collect 采集一次数据并附带标签、有变化的主机信息、服务检查结果和 systemd 单元状态
+func (a *Agent) Collect() (*collector.MetricsData, error) {
+	metrics, err := a.collector.CollectMetrics()
+
+	// 采集失败时也执行检查，结果显示在状态接口中
+	results := []checks.Result{}
+	if a.checks != nil {
+		results = a.checks.Run(a.ctx)
+	}
+	var units []collector.UnitStatus
+	var unitsErr error
+	if a.units != nil {
+		if units, unitsErr = a.units.Collect(a.ctx); unitsErr != nil {
+			log.Printf("systemd 单元采集失败: %v", unitsErr)
+		}
+	}
+
+	a.mu.Lock()
+	a.status.lastCollectAt = time.Now()
+	a.status.lastCollectErr = err
+	if err == nil {
+		a.status.lastMetrics = metrics
+	}
+	a.status.lastChecks = results
+	units = mergeUnits(units, a.status.lastUnits, unitsErr)
+	a.status.lastUnits = units
+	a.status.lastUnitsErr = unitsErr
+	a.mu.Unlock()
+
+	if err != nil {
+		return nil, err
+	}
+	metrics.Labels = a.labels
+	metrics.Host = a.collector.HostInfoIfChanged()
+	metrics.Checks = results
+	metrics.Units = units
+	metrics.UnitsUnavailable = units == nil
+	return metrics, nil
+}
+
+// mergeUnits 决定本次上报的 systemd 单元。systemctl 失败时沿用上次的结果，避免服务器认为单元已被移除；
+// 还没有成功采集过时返回 nil，上报中标记为未获取，服务器保留已有单元。
+// 未配置单元或 systemctl 确实没有返回单元时返回空列表
+func mergeUnits(units, last []collector.UnitStatus, err error) []collector.UnitStatus {
+	if err != nil {
+		return last
+	}
+	if units == nil {
+		return []collector.UnitStatus{}
+	}
+	return units
+}
+
+// recordSend 记录一次数据的发送结果，delivered 表示至少一个服务器收到了数据
+func (a *Agent) recordSend(delivered bool, err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	a.status.send.record(now, err)
+	if delivered {
+		a.status.send.lastSuccessAt = now
+		a.status.send.consecutiveFailures = 0
+	}
+}
@@ -0,0 +1,35 @@
+package agent
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"miniPanel-agent/internal/collector"
+)
+
+func TestMergeUnits(t *testing.T) {
+	nginx := []collector.UnitStatus{{Name: "nginx.service", ActiveState: "active", SubState: "running"}}
+	failed := errors.New("systemctl: exit status 1")
+
+	tests := []struct {
+		name  string
+		units []collector.UnitStatus
+		last  []collector.UnitStatus
+		err   error
+		want  []collector.UnitStatus
+	}{
+		{"collected", nginx, nil, nil, nginx},
+		// 未配置单元或 systemctl 没有返回单元，服务器删除已有单元
+		{"none", nil, nginx, nil, []collector.UnitStatus{}},
+		{"failed keeps last", nil, nginx, failed, nginx},
+		// 启动后还没有成功采集过，不能上报空列表
+		{"failed first", nil, nil, failed, nil},
+	}
+	for _, tt := range tests {
+		got := mergeUnits(tt.units, tt.last, tt.err)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: mergeUnits = %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"miniPanel-agent/internal/client"
+	"miniPanel-agent/internal/collector"
+	"miniPanel-agent/internal/config"
+	"miniPanel-agent/internal/spool"
+	"miniPanel-agent/internal/wire"
+)
+
+// destination 一个上报服务器，有自己的客户端、熔断器和发送状态。
+// failover 模式下所有服务器共用一个暂存队列，fanout 模式下各自暂存
+type destination struct {
+	cfg    config.ServerConfig
+	client *client.Client
+	spool  *spool.Spool
+	status sendStatus // 在采集循环中修改，读取需要持有 Agent.mu
+}
+
+// sendStatus 最近一次发送的结果
+type sendStatus struct {
+	lastSendAt          time.Time
+	lastSuccessAt       time.Time
+	lastSendErr         error
+	consecutiveFailures int
+}
+
+func (s *sendStatus) record(now time.Time, err error) {
+	s.lastSendAt = now
+	s.lastSendErr = err
+	if err != nil {
+		s.consecutiveFailures++
+	} else {
+		s.lastSuccessAt = now
+		s.consecutiveFailures = 0
+	}
+}
+
+// Upstream 上报服务器的名称和客户端
+type Upstream struct {
+	Name   string
+	Client *client.Client
+}
+
+// Upstreams 返回所有上报服务器，未配置服务器地址时为空
+func (a *Agent) Upstreams() []Upstream {
+	var out []Upstream
+	for _, d := range a.dests {
+		out = append(out, Upstream{Name: d.cfg.Label(), Client: d.client})
+	}
+	return out
+}
+
+// buildDestinations 根据配置创建上报服务器。配置没有变化的服务器沿用原来的客户端，
+// 保留熔断状态；暂存的数据按服务器地址保留，failover 模式沿用原来第一个服务器的暂存队列
+func (a *Agent) buildDestinations(cfg *config.Config) ([]*destination, error) {
+	old := map[string]*destination{}
+	for _, d := range a.dests {
+		old[d.cfg.URL] = d
+	}
+
+	var shared *spool.Spool
+	if cfg.ServerMode == config.ServerModeFailover {
+		shared = spool.New(cfg.Agent.SpoolSize)
+		if len(a.dests) > 0 {
+			shared = a.dests[0].spool
+		}
+	}
+
+	var dests []*destination
+	used := map[*spool.Spool]bool{}
+	for _, sc := range cfg.Destinations() {
+		d := &destination{cfg: sc}
+		prev := old[sc.URL]
+		if prev != nil {
+			d.status = prev.status
+		}
+
+		if prev != nil && prev.cfg == sc && a.cfg.Agent.NodeName == cfg.Agent.NodeName {
+			d.client = prev.client
+		} else {
+			c, err := client.NewClient(sc, cfg.Agent.NodeName)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", sc.Label(), err)
+			}
+			d.client = c
+		}
+
+		switch {
+		case shared != nil:
+			d.spool = shared
+		case prev != nil && !used[prev.spool]:
+			d.spool = prev.spool
+		default:
+			d.spool = spool.New(cfg.Agent.SpoolSize)
+		}
+		used[d.spool] = true
+		dests = append(dests, d)
+	}
+	return dests, nil
+}
+
+// openStreams 连接配置了 gRPC 地址的服务器，已连接的不受影响
+func (a *Agent) openStreams() {
+	if a.commands == nil {
+		return
+	}
+	for _, d := range a.dests {
+		d.client.OpenStream(a.commands)
+	}
+}
+
+// closeUnused 断开重建服务器后不再使用的客户端的 gRPC 流
+func closeUnused(old, dests []*destination) {
+	used := map[*client.Client]bool{}
+	for _, d := range dests {
+		used[d.client] = true
+	}
+	for _, d := range old {
+		if !used[d.client] {
+			d.client.Close()
+		}
+	}
+}
+
+// handleCommand 在采集循环中执行服务器下发的命令
+func (a *Agent) handleCommand(cmd client.Command) {
+	log.Printf("收到服务器命令: %s", cmd.Type)
+	switch cmd.Type {
+	case wire.CommandCollectNow:
+		a.Tick()
+	case wire.CommandSendInventory:
+		a.collector.ForceHostInfo()
+		a.Tick()
+	case wire.CommandSyncConfig:
+		// 集中配置只从 primary 服务器获取
+		if p := a.primary(); p != nil && p.client == cmd.Source {
+			a.syncRemote(cmd.ConfigVersion)
+		}
+	default:
+		log.Printf("忽略不支持的命令: %s", cmd.Type)
+	}
+}
+
+// primary 获取集中配置和升级文件使用的服务器：failover 模式为当前使用的服务器，
+// fanout 模式为第一个服务器。未配置服务器地址时为 nil
+func (a *Agent) primary() *destination {
+	if len(a.dests) == 0 {
+		return nil
+	}
+	if a.cfg.ServerMode == config.ServerModeFanout {
+		return a.dests[0]
+	}
+	return a.dests[a.active]
+}
+
+// spools 返回不重复的暂存队列
+func (a *Agent) spools() []*spool.Spool {
+	var out []*spool.Spool
+	seen := map[*spool.Spool]bool{}
+	for _, d := range a.dests {
+		if !seen[d.spool] {
+			seen[d.spool] = true
+			out = append(out, d.spool)
+		}
+	}
+	return out
+}
+
+// Send 发送数据：failover 模式按顺序发送到第一个可用的服务器，fanout 模式发送到所有服务器。
+// 发送失败的数据暂存，成功后补发；被服务器拒绝的数据（如格式错误）重发也不会成功，直接丢弃
+func (a *Agent) Send(metrics *collector.MetricsData) error {
+	if a.cfg.ServerMode == config.ServerModeFanout {
+		return a.sendFanout(metrics)
+	}
+	return a.sendFailover(metrics)
+}
+
+func (a *Agent) sendFailover(metrics *collector.MetricsData) error {
+	var errs []error
+	permanent := true
+	for i, d := range a.dests {
+		result, err := a.sendTo(d, metrics)
+		if err == nil {
+			a.setActive(i)
+			a.recordSend(true, nil)
+			a.collector.MarkHostInfoReported(metrics.Host)
+			a.replay(d)
+			a.syncRemote(result.ConfigVersion)
+			return nil
+		}
+		if !client.IsPermanent(err) {
+			permanent = false
+		}
+		errs = append(errs, a.labelError(d, err))
+	}
+
+	err := errors.Join(errs...)
+	a.recordSend(false, err)
+	if permanent {
+		return fmt.Errorf("rejected by server, dropped: %v", err)
+	}
+	a.dests[0].spool.Push(metrics)
+	return err
+}
+
+func (a *Agent) sendFanout(metrics *collector.MetricsData) error {
+	var errs []error
+	var primary *client.SendResult
+	for i, d := range a.dests {
+		result, err := a.sendTo(d, metrics)
+		switch {
+		case err == nil:
+			if i == 0 {
+				primary = result
+			}
+			a.replay(d)
+		case client.IsPermanent(err):
+			errs = append(errs, a.labelError(d, fmt.Errorf("rejected by server, dropped: %v", err)))
+		default:
+			d.spool.Push(metrics)
+			errs = append(errs, a.labelError(d, err))
+		}
+	}
+
+	// 至少一个服务器收到数据即认为主机信息已上报，发送失败的服务器在补发时收到
+	delivered := len(errs) < len(a.dests)
+	err := errors.Join(errs...)
+	a.recordSend(delivered, err)
+	if delivered {
+		a.collector.MarkHostInfoReported(metrics.Host)
+	}
+	if primary != nil {
+		a.syncRemote(primary.ConfigVersion)
+	}
+	return err
+}
+
+// labelError 多个服务器时在错误前加上服务器名称
+func (a *Agent) labelError(d *destination, err error) error {
+	if len(a.dests) == 1 {
+		return err
+	}
+	return fmt.Errorf("%s: %w", d.cfg.Label(), err)
+}
+
+// sendTo 发送到一个服务器并记录结果
+func (a *Agent) sendTo(d *destination, metrics *collector.MetricsData) (*client.SendResult, error) {
+	result, err := d.client.SendMetrics(a.ctx, metrics)
+
+	a.mu.Lock()
+	d.status.record(time.Now(), err)
+	a.mu.Unlock()
+	return result, err
+}
+
+// replay 补发暂存的数据
+func (a *Agent) replay(d *destination) {
+	if n := d.spool.Len(); n > 0 {
+		log.Printf("补发暂存的 %d 条数据到 %s", n, d.cfg.Label())
+	}
+	for item := d.spool.Peek(); item != nil; item = d.spool.Peek() {
+		_, err := a.sendTo(d, item)
+		if client.IsPermanent(err) {
+			log.Printf("暂存的数据被服务器拒绝，已丢弃: %v", err)
+			d.spool.Pop()
+			continue
+		}
+		if err != nil {
+			log.Printf("补发失败，剩余 %d 条: %v", d.spool.Len(), err)
+			break
+		}
+		d.spool.Pop()
+	}
+}
+
+// setActive 记录 failover 模式当前使用的服务器
+func (a *Agent) setActive(i int) {
+	if i == a.active {
+		return
+	}
+	log.Printf("切换到服务器 %s", a.dests[i].cfg.Label())
+
+	a.mu.Lock()
+	a.active = i
+	a.mu.Unlock()
+}
+
+// once 发送一次数据，不暂存也不应用服务器配置，用于 -once 模式
+func (a *Agent) once(metrics *collector.MetricsData) error {
+	var errs []error
+	for _, d := range a.dests {
+		_, err := d.client.SendMetrics(context.Background(), metrics)
+		if err == nil && a.cfg.ServerMode == config.ServerModeFailover {
+			return nil
+		}
+		if err != nil {
+			errs = append(errs, a.labelError(d, err))
+		}
+	}
+	return errors.Join(errs...)
+}
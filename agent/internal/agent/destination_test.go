@@ -0,0 +1,197 @@
+package agent
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"miniPanel-agent/internal/collector"
+	"miniPanel-agent/internal/config"
+)
+
+// fakeServer 按设置的状态码回复上报，记录收到的数据（以 cpu_percent 标记）
+type fakeServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	status   int
+	received []float64
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	s := &fakeServer{status: http.StatusOK}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.status != http.StatusOK {
+			http.Error(w, `{"success":false,"message":"unavailable"}`, s.status)
+			return
+		}
+		var m collector.MetricsData
+		if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+			http.Error(w, `{"success":false}`, http.StatusBadRequest)
+			return
+		}
+		s.received = append(s.received, m.CPUPercent)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"success":true,"data":{}}`))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeServer) setStatus(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+// take 返回并清空收到的数据
+func (s *fakeServer) take() []float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	got := s.received
+	s.received = nil
+	return got
+}
+
+func newTestAgent(t *testing.T, mode string, servers ...*fakeServer) *Agent {
+	t.Helper()
+	cfg := config.DefaultConfig()
+	cfg.ServerMode = mode
+	cfg.Agent.NodeName = "test-node"
+	cfg.Agent.SpoolSize = 10
+	for _, s := range servers {
+		cfg.Servers = append(cfg.Servers, config.ServerConfig{
+			URL:     s.URL + "/api/metrics",
+			Format:  config.FormatJSON,
+			Timeout: 5,
+		})
+	}
+	a, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return a
+}
+
+func sample(mark float64) *collector.MetricsData {
+	return &collector.MetricsData{CPUPercent: mark, Labels: map[string]string{}}
+}
+
+func equalMarks(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestFailover(t *testing.T) {
+	a1, a2 := newFakeServer(t), newFakeServer(t)
+	a := newTestAgent(t, config.ServerModeFailover, a1, a2)
+	if a.dests[0].spool != a.dests[1].spool {
+		t.Fatal("failover servers must share one spool")
+	}
+
+	steps := []struct {
+		name       string
+		status1    int
+		status2    int
+		wantErr    bool
+		want1      []float64
+		want2      []float64
+		wantActive int
+		wantSpool  int
+	}{
+		{"first server", 200, 200, false, []float64{1}, nil, 0, 0},
+		{"fail over to second", 503, 200, false, nil, []float64{2}, 1, 0},
+		{"both down spools", 503, 503, true, nil, nil, 1, 1},
+		{"still down spools", 503, 503, true, nil, nil, 1, 2},
+		// 恢复后先发送新数据，再按顺序补发暂存的数据
+		{"first back replays", 200, 503, false, []float64{5, 3, 4}, nil, 0, 0},
+		// 被拒绝的数据重发也不会成功，不暂存
+		{"rejected is dropped", 400, 400, true, nil, nil, 0, 0},
+	}
+	for i, st := range steps {
+		a1.setStatus(st.status1)
+		a2.setStatus(st.status2)
+		err := a.Send(sample(float64(i + 1)))
+		if (err != nil) != st.wantErr {
+			t.Fatalf("%s: Send err = %v, wantErr %v", st.name, err, st.wantErr)
+		}
+		if got := a1.take(); !equalMarks(got, st.want1) {
+			t.Errorf("%s: first server received %v, want %v", st.name, got, st.want1)
+		}
+		if got := a2.take(); !equalMarks(got, st.want2) {
+			t.Errorf("%s: second server received %v, want %v", st.name, got, st.want2)
+		}
+		if a.active != st.wantActive {
+			t.Errorf("%s: active = %d, want %d", st.name, a.active, st.wantActive)
+		}
+		if n := a.dests[0].spool.Len(); n != st.wantSpool {
+			t.Errorf("%s: spooled %d, want %d", st.name, n, st.wantSpool)
+		}
+	}
+}
+
+func TestFanout(t *testing.T) {
+	a1, a2 := newFakeServer(t), newFakeServer(t)
+	a := newTestAgent(t, config.ServerModeFanout, a1, a2)
+	if a.dests[0].spool == a.dests[1].spool {
+		t.Fatal("fanout servers must have their own spools")
+	}
+
+	a2.setStatus(http.StatusServiceUnavailable)
+	if err := a.Send(sample(1)); err == nil {
+		t.Error("Send with one server down returned no error")
+	}
+	if got := a1.take(); !equalMarks(got, []float64{1}) {
+		t.Errorf("first server received %v, want [1]", got)
+	}
+	if a.dests[0].spool.Len() != 0 || a.dests[1].spool.Len() != 1 {
+		t.Errorf("spools = %d/%d, want 0/1", a.dests[0].spool.Len(), a.dests[1].spool.Len())
+	}
+
+	// 恢复的服务器补发自己暂存的数据，另一个服务器不会重复收到
+	a2.setStatus(http.StatusOK)
+	if err := a.Send(sample(2)); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if got := a1.take(); !equalMarks(got, []float64{2}) {
+		t.Errorf("first server received %v, want [2]", got)
+	}
+	if got := a2.take(); !equalMarks(got, []float64{2, 1}) {
+		t.Errorf("second server received %v, want [2 1]", got)
+	}
+	if a.dests[1].spool.Len() != 0 {
+		t.Errorf("second spool has %d items after replay", a.dests[1].spool.Len())
+	}
+}
+
+func TestBuildDestinationsKeepsSpool(t *testing.T) {
+	a1, a2 := newFakeServer(t), newFakeServer(t)
+	a := newTestAgent(t, config.ServerModeFanout, a1, a2)
+	a2.setStatus(http.StatusServiceUnavailable)
+	a.Send(sample(1))
+
+	// 重新加载配置时按服务器地址保留暂存的数据
+	cfg := *a.cfg
+	cfg.Servers = []config.ServerConfig{a.cfg.Servers[1], a.cfg.Servers[0]}
+	dests, err := a.buildDestinations(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dests[0].spool != a.dests[1].spool || dests[0].spool.Len() != 1 {
+		t.Errorf("spool of %s not kept after reordering servers", cfg.Servers[0].URL)
+	}
+	if dests[0].client != a.dests[1].client {
+		t.Error("client of unchanged server was recreated")
+	}
+}
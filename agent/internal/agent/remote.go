@@ -0,0 +1,63 @@
+package agent
+
+import (
+	"fmt"
+	"log"
+
+	"miniPanel-agent/internal/client"
+)
+
+// syncRemote 服务器返回的配置版本与已应用的不同时获取并应用新配置，
+// 版本为空表示服务器上已没有适用的配置，恢复本地配置
+func (a *Agent) syncRemote(version string) {
+	if !a.local.Remote.Enabled {
+		return
+	}
+
+	current := ""
+	if a.remote != nil {
+		current = a.remote.Version
+	}
+	if version == current {
+		return
+	}
+
+	if version == "" {
+		changes, err := a.applyConfig(a.local)
+		if err != nil {
+			log.Printf("恢复本地配置失败: %v", err)
+			return
+		}
+		a.setRemote(nil)
+		logChanges("服务器配置已移除，恢复本地配置", changes)
+		return
+	}
+
+	remote, err := a.primary().client.FetchConfig()
+	if err != nil {
+		// 下次上报时重试
+		log.Printf("获取服务器配置失败: %v", err)
+		return
+	}
+
+	// 无效的配置也记录版本，避免每次上报都重新获取
+	a.setRemote(remote)
+	changes, err := a.applyConfig(a.local.WithRemote(&remote.Config))
+	if err != nil {
+		log.Printf("服务器配置 %s 无效，继续使用当前配置:\n%v", remote.Version, err)
+		return
+	}
+	logChanges(fmt.Sprintf("已应用服务器配置 %s %v", remote.Version, remote.Profiles), changes)
+}
+
+// setRemote 记录已应用的服务器配置
+func (a *Agent) setRemote(remote *client.RemoteConfig) {
+	a.remote = remote
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.status.configVersion = ""
+	if remote != nil {
+		a.status.configVersion = remote.Version
+	}
+}
@@ -0,0 +1,116 @@
+package agent
+
+import (
+	"log"
+	"time"
+
+	"miniPanel-agent/internal/update"
+	"miniPanel-agent/internal/version"
+)
+
+// updateRetryInterval 升级失败后再次尝试同一版本的间隔
+const updateRetryInterval = 10 * time.Minute
+
+// StartupUpdate 检查上次升级的结果，新版本反复重启时回滚，返回 true 表示需要重启
+func (a *Agent) StartupUpdate() bool {
+	if a.updater == nil {
+		return false
+	}
+
+	restart, err := a.updater.Startup(version.Version)
+	if err != nil {
+		log.Printf("检查升级状态失败: %v", err)
+	}
+	if m := a.updater.Marker(); m != nil && m.State == update.StatePending {
+		log.Printf("已从版本 %s 升级，等待上报成功（期限 %s）", m.From, m.Deadline.Format(time.RFC3339))
+	}
+	a.recordUpdate(err)
+	return restart
+}
+
+// RestartRequested 升级或回滚后关闭，之后需要调用 Restart
+func (a *Agent) RestartRequested() <-chan struct{} {
+	return a.restart
+}
+
+// Restart 重启Agent以运行替换后的二进制文件
+func (a *Agent) Restart() {
+	a.updater.Restart()
+}
+
+// checkUpdate 在每次采集后调用：确认或回滚等待确认的新版本，或者升级到配置的目标版本
+func (a *Agent) checkUpdate() {
+	u := a.updater
+	if u == nil || a.restarting {
+		return
+	}
+
+	if u.Pending() {
+		switch {
+		case a.reported():
+			err := u.Confirm()
+			if err == nil {
+				log.Printf("版本 %s 上报成功，升级完成", version.Version)
+			}
+			a.recordUpdate(err)
+		case u.Expired(time.Now()):
+			log.Printf("版本 %s 未能在期限内上报成功，回滚", version.Version)
+			err := u.Rollback()
+			a.recordUpdate(err)
+			if err != nil {
+				log.Printf("回滚失败: %v", err)
+				return
+			}
+			a.requestRestart()
+		}
+		return
+	}
+
+	cfg := a.cfg.Update
+	target := cfg.Version
+	if !cfg.Enabled || len(a.dests) == 0 || target == "" || target == version.Version || target == u.Failed() {
+		return
+	}
+	if target == a.lastUpdateTarget && time.Since(a.lastUpdateAttempt) < updateRetryInterval {
+		return
+	}
+	a.lastUpdateTarget, a.lastUpdateAttempt = target, time.Now()
+
+	log.Printf("开始升级到版本 %s", target)
+	err := u.Apply(a.primary().client, version.Version, target, cfg.PublicKey, time.Duration(cfg.Deadline)*time.Second)
+	a.recordUpdate(err)
+	if err != nil {
+		log.Printf("升级到版本 %s 失败: %v", target, err)
+		return
+	}
+	log.Printf("已替换为版本 %s，重启Agent", target)
+	a.requestRestart()
+}
+
+// reported 本次启动后是否已成功上报或被服务器拉取
+func (a *Agent) reported() bool {
+	a.mu.Lock()
+	ok := !a.status.send.lastSuccessAt.IsZero()
+	a.mu.Unlock()
+
+	if !ok && a.pull != nil {
+		ok = !a.pull.LastServed().IsZero()
+	}
+	return ok
+}
+
+func (a *Agent) requestRestart() {
+	if !a.restarting {
+		a.restarting = true
+		close(a.restart)
+	}
+}
+
+// recordUpdate 记录升级状态供状态接口展示
+func (a *Agent) recordUpdate(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.status.updateMarker = a.updater.Marker()
+	a.status.updateErr = err
+}
@@ -0,0 +1,241 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"miniPanel-agent/internal/checks"
+	"miniPanel-agent/internal/collector"
+	"miniPanel-agent/internal/config"
+	"miniPanel-agent/internal/update"
+	"miniPanel-agent/internal/version"
+)
+
+// Status 本地状态接口返回的内容
+type Status struct {
+	Version       string `json:"version"`
+	NodeName      string `json:"node_name"`
+	StartedAt     string `json:"started_at"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+
+	LastCollection *CollectionStatus      `json:"last_collection"`
+	Checks         []checks.Result        `json:"checks,omitempty"`      // 最近一次服务检查结果
+	Units          []collector.UnitStatus `json:"units,omitempty"`       // 最近一次采集的 systemd 单元状态
+	UnitsError     string                 `json:"units_error,omitempty"` // 最近一次 systemd 单元采集的错误，此时 units 为之前的结果
+
+	LastSendAt          string `json:"last_send_at,omitempty"`
+	LastSuccessAt       string `json:"last_success_at,omitempty"`
+	LastSendError       string `json:"last_send_error,omitempty"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	Circuit             string `json:"circuit,omitempty"`            // 当前使用的服务器的熔断器状态: closed、open 或 half-open
+	CircuitOpenUntil    string `json:"circuit_open_until,omitempty"` // 熔断期间恢复发送的时间
+
+	Spool SpoolStatus `json:"spool"` // 所有服务器合计
+
+	Destinations []DestinationStatus `json:"destinations,omitempty"`
+
+	Config        *config.Config `json:"config"`                   // 生效的配置，密钥已隐藏
+	ConfigVersion string         `json:"config_version,omitempty"` // 已应用的服务器配置版本
+
+	Update *UpdateStatus `json:"update,omitempty"`
+}
+
+// UpdateStatus 自动升级状态
+type UpdateStatus struct {
+	Target    string `json:"target,omitempty"`     // 配置的目标版本
+	State     string `json:"state,omitempty"`      // pending 或 failed
+	From      string `json:"from,omitempty"`       // 升级前的版本
+	To        string `json:"to,omitempty"`         // 升级到的版本
+	Deadline  string `json:"deadline,omitempty"`   // 新版本需要在此之前上报成功
+	LastError string `json:"last_error,omitempty"` // 最近一次升级或回滚的错误
+}
+
+// CollectionStatus 最近一次采集结果
+type CollectionStatus struct {
+	Time          string  `json:"time"`
+	Error         string  `json:"error,omitempty"`
+	CPUPercent    float64 `json:"cpu_percent"`
+	MemoryPercent float64 `json:"memory_percent"`
+	CPUTemp       float64 `json:"cpu_temp"`
+}
+
+// DestinationStatus 一个上报服务器的发送状态
+type DestinationStatus struct {
+	Name                string      `json:"name"`
+	URL                 string      `json:"url"`
+	Active              bool        `json:"active"`           // failover 模式当前使用的服务器，fanout 模式均为 true
+	Format              string      `json:"format"`           // 当前使用的上报格式: json 或 protobuf
+	Stream              string      `json:"stream,omitempty"` // gRPC 流状态: connected 或 disconnected，未配置时为空
+	LastSendAt          string      `json:"last_send_at,omitempty"`
+	LastSuccessAt       string      `json:"last_success_at,omitempty"`
+	LastSendError       string      `json:"last_send_error,omitempty"`
+	ConsecutiveFailures int         `json:"consecutive_failures"`
+	Circuit             string      `json:"circuit"`
+	CircuitOpenUntil    string      `json:"circuit_open_until,omitempty"`
+	Spool               SpoolStatus `json:"spool"` // failover 模式所有服务器共用
+}
+
+// SpoolStatus 暂存队列状态
+type SpoolStatus struct {
+	Size     int    `json:"size"`
+	Capacity int    `json:"capacity"`
+	Dropped  uint64 `json:"dropped"`
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// Status 返回当前状态
+func (a *Agent) Status() Status {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	st := Status{
+		Version:             version.Version,
+		NodeName:            a.cfg.Agent.NodeName,
+		StartedAt:           formatTime(a.startedAt),
+		UptimeSeconds:       int64(time.Since(a.startedAt).Seconds()),
+		LastSendAt:          formatTime(a.status.send.lastSendAt),
+		LastSuccessAt:       formatTime(a.status.send.lastSuccessAt),
+		ConsecutiveFailures: a.status.send.consecutiveFailures,
+		Config:              a.cfg.Redacted(),
+		ConfigVersion:       a.status.configVersion,
+	}
+	if a.status.send.lastSendErr != nil {
+		st.LastSendError = a.status.send.lastSendErr.Error()
+	}
+
+	for _, s := range a.spools() {
+		st.Spool.Size += s.Len()
+		st.Spool.Capacity += s.Capacity()
+		st.Spool.Dropped += s.Dropped()
+	}
+	if len(a.dests) == 0 {
+		// 仅拉取模式
+		st.Spool.Capacity = a.cfg.Agent.SpoolSize
+	}
+	fanout := a.cfg.ServerMode == config.ServerModeFanout
+	for i, d := range a.dests {
+		circuit, openUntil := d.client.Circuit()
+		ds := DestinationStatus{
+			Name:                d.cfg.Label(),
+			URL:                 d.cfg.URL,
+			Active:              fanout || i == a.active,
+			Format:              d.client.Format(),
+			Stream:              d.client.StreamState(),
+			LastSendAt:          formatTime(d.status.lastSendAt),
+			LastSuccessAt:       formatTime(d.status.lastSuccessAt),
+			ConsecutiveFailures: d.status.consecutiveFailures,
+			Circuit:             circuit,
+			CircuitOpenUntil:    formatTime(openUntil),
+			Spool: SpoolStatus{
+				Size:     d.spool.Len(),
+				Capacity: d.spool.Capacity(),
+				Dropped:  d.spool.Dropped(),
+			},
+		}
+		if d.status.lastSendErr != nil {
+			ds.LastSendError = d.status.lastSendErr.Error()
+		}
+		st.Destinations = append(st.Destinations, ds)
+		if ds.Active && st.Circuit == "" {
+			st.Circuit, st.CircuitOpenUntil = ds.Circuit, ds.CircuitOpenUntil
+		}
+	}
+
+	if a.cfg.Update.Enabled || a.status.updateMarker != nil || a.status.updateErr != nil {
+		st.Update = &UpdateStatus{Target: a.cfg.Update.Version}
+		if m := a.status.updateMarker; m != nil {
+			st.Update.State, st.Update.From, st.Update.To = m.State, m.From, m.To
+			if m.State == update.StatePending {
+				st.Update.Deadline = formatTime(m.Deadline)
+			}
+		}
+		if a.status.updateErr != nil {
+			st.Update.LastError = a.status.updateErr.Error()
+		}
+	}
+
+	st.Checks = a.status.lastChecks
+	st.Units = a.status.lastUnits
+	if a.status.lastUnitsErr != nil {
+		st.UnitsError = a.status.lastUnitsErr.Error()
+	}
+	if !a.status.lastCollectAt.IsZero() {
+		st.LastCollection = &CollectionStatus{Time: formatTime(a.status.lastCollectAt)}
+		if a.status.lastCollectErr != nil {
+			st.LastCollection.Error = a.status.lastCollectErr.Error()
+		} else if m := a.status.lastMetrics; m != nil {
+			st.LastCollection.CPUPercent = m.CPUPercent
+			st.LastCollection.MemoryPercent = m.MemoryPercent
+			st.LastCollection.CPUTemp = m.CPUTemp
+		}
+	}
+	return st
+}
+
+// healthy 最近一次采集成功且没有超过3个采集间隔时认为正常，
+// 发送失败（服务器不可用）不影响健康状态，避免被反复重启
+func (a *Agent) healthy() (bool, string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	limit := 3 * time.Duration(a.cfg.Agent.Interval) * time.Second
+	if a.status.lastCollectAt.IsZero() {
+		if time.Since(a.startedAt) > limit {
+			return false, "no collection since start"
+		}
+		return true, "starting"
+	}
+	if a.status.lastCollectErr != nil {
+		return false, "last collection failed: " + a.status.lastCollectErr.Error()
+	}
+	if time.Since(a.status.lastCollectAt) > limit {
+		return false, "collection loop stalled"
+	}
+	return true, "ok"
+}
+
+// ServeStatus 在本机地址上提供 /status 和 /healthz
+func (a *Agent) ServeStatus(addr string) error {
+	// 状态接口包含配置信息，只允许监听本机地址
+	if err := config.CheckLoopback(addr); err != nil {
+		return fmt.Errorf("status listener %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(a.Status())
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		ok, reason := a.healthy()
+		if !ok {
+			http.Error(w, reason, http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, reason)
+	})
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	log.Printf("状态接口监听: %s", addr)
+	go func() {
+		if err := http.Serve(ln, mux); err != nil {
+			log.Printf("状态接口退出: %v", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,219 @@
+// Package checks 服务检查：TCP 端口、HTTP 接口、进程和文件，结果随监控数据上报
+package checks
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"miniPanel-agent/internal/config"
+	"miniPanel-agent/internal/version"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// 检查结果状态
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// maxBodySize HTTP 检查最多读取的响应内容
+const maxBodySize = 1 << 20
+
+// Result 一次检查的结果
+type Result struct {
+	Name      string  `json:"name"`
+	Type      string  `json:"type"`
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Message   string  `json:"message,omitempty"` // 失败原因，或成功时的附加信息
+}
+
+// Runner 按配置执行检查，HTTP 检查复用连接
+type Runner struct {
+	checks []config.CheckConfig
+	bodies []*regexp.Regexp // 与 checks 对应，未配置 expect_body 时为 nil
+	client *http.Client
+}
+
+// NewRunner 根据配置创建检查，配置应已通过校验
+func NewRunner(checks []config.CheckConfig) *Runner {
+	r := &Runner{
+		checks: checks,
+		bodies: make([]*regexp.Regexp, len(checks)),
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy:             http.ProxyFromEnvironment,
+				DisableKeepAlives: true,
+			},
+			// 跳转后的状态码才是检查结果
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= 5 {
+					return fmt.Errorf("stopped after 5 redirects")
+				}
+				return nil
+			},
+		},
+	}
+	for i, c := range checks {
+		if c.ExpectBody != "" {
+			r.bodies[i] = regexp.MustCompile(c.ExpectBody)
+		}
+	}
+	return r
+}
+
+// Run 并发执行所有检查，按配置顺序返回结果；总耗时不超过最长的检查超时
+func (r *Runner) Run(ctx context.Context) []Result {
+	results := make([]Result, len(r.checks))
+	var wg sync.WaitGroup
+	for i := range r.checks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = r.run(ctx, i)
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+func (r *Runner) run(ctx context.Context, i int) Result {
+	c := r.checks[i]
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(c.Timeout)*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	var message string
+	var err error
+	switch c.Type {
+	case config.CheckTCP:
+		err = checkTCP(ctx, c)
+	case config.CheckHTTP:
+		message, err = r.checkHTTP(ctx, c, r.bodies[i])
+	case config.CheckProcess:
+		message, err = checkProcess(ctx, c)
+	case config.CheckFile:
+		message, err = checkFile(c, start)
+	default:
+		err = fmt.Errorf("unknown check type %q", c.Type)
+	}
+
+	result := Result{
+		Name:      c.Name,
+		Type:      c.Type,
+		Status:    StatusOK,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		Message:   message,
+	}
+	if err != nil {
+		result.Status, result.Message = StatusFail, err.Error()
+	}
+	return result
+}
+
+// checkTCP 端口可以建立连接
+func checkTCP(ctx context.Context, c config.CheckConfig) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.Target)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// checkHTTP 状态码符合预期，配置了 expect_body 时响应内容匹配
+func (r *Runner) checkHTTP(ctx context.Context, c config.CheckConfig, body *regexp.Regexp) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.Target, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", version.UserAgent())
+
+	client := r.client
+	if c.InsecureSkipVerify {
+		insecure := *r.client
+		insecure.Transport = &http.Transport{
+			Proxy:             http.ProxyFromEnvironment,
+			DisableKeepAlives: true,
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		}
+		client = &insecure
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	ok := resp.StatusCode >= 200 && resp.StatusCode < 400
+	if c.ExpectStatus != 0 {
+		ok = resp.StatusCode == c.ExpectStatus
+	}
+	if !ok {
+		return "", fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	if body != nil {
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+		if err != nil {
+			return "", fmt.Errorf("read body: %v", err)
+		}
+		if !body.Match(data) {
+			return "", fmt.Errorf("body does not match %q", c.ExpectBody)
+		}
+	}
+	return fmt.Sprintf("status %d", resp.StatusCode), nil
+}
+
+// checkProcess 至少有一个进程名与 target 相同
+func checkProcess(ctx context.Context, c config.CheckConfig) (string, error) {
+	procs, err := process.ProcessesWithContext(ctx)
+	if err != nil {
+		return "", err
+	}
+	count := 0
+	for _, p := range procs {
+		name, err := p.NameWithContext(ctx)
+		if err != nil {
+			// 进程已退出或无权限读取
+			continue
+		}
+		if name == c.Target || strings.TrimSuffix(name, ".exe") == c.Target {
+			count++
+		}
+	}
+	if count == 0 {
+		return "", fmt.Errorf("process %s is not running", c.Target)
+	}
+	return fmt.Sprintf("%d running", count), nil
+}
+
+// checkFile 文件存在，修改时间和大小在配置的范围内
+func checkFile(c config.CheckConfig, now time.Time) (string, error) {
+	info, err := os.Stat(c.Target)
+	if err != nil {
+		return "", err
+	}
+	age := now.Sub(info.ModTime())
+	if c.MaxAge > 0 && age > time.Duration(c.MaxAge)*time.Second {
+		return "", fmt.Errorf("modified %v ago, more than %ds", age.Round(time.Second), c.MaxAge)
+	}
+	if c.MinSize > 0 && info.Size() < c.MinSize {
+		return "", fmt.Errorf("size %d is less than %d", info.Size(), c.MinSize)
+	}
+	if c.MaxSize > 0 && info.Size() > c.MaxSize {
+		return "", fmt.Errorf("size %d is more than %d", info.Size(), c.MaxSize)
+	}
+	return fmt.Sprintf("size %d, modified %v ago", info.Size(), age.Round(time.Second)), nil
+}
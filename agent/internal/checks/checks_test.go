@@ -0,0 +1,131 @@
+package checks
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"miniPanel-agent/internal/config"
+)
+
+func TestRun(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/healthz":
+			w.Write([]byte("status: ok"))
+		case "/moved":
+			http.Redirect(w, r, "/healthz", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	tlsSrv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsSrv.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := closed.Addr().String()
+	closed.Close()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "backup.tar")
+	if err := os.WriteFile(file, make([]byte, 100), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := filepath.Join(dir, "old.tar")
+	if err := os.WriteFile(old, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(old, time.Now().Add(-2*time.Hour), time.Now().Add(-2*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		check       config.CheckConfig
+		wantStatus  string
+		wantMessage string
+	}{
+		{config.CheckConfig{Type: config.CheckTCP, Target: ln.Addr().String()}, StatusOK, ""},
+		{config.CheckConfig{Type: config.CheckTCP, Target: closedAddr}, StatusFail, "refused"},
+		{config.CheckConfig{Type: config.CheckHTTP, Target: srv.URL + "/healthz"}, StatusOK, "status 200"},
+		{config.CheckConfig{Type: config.CheckHTTP, Target: srv.URL + "/healthz", ExpectBody: "status: (ok|up)"}, StatusOK, "status 200"},
+		{config.CheckConfig{Type: config.CheckHTTP, Target: srv.URL + "/healthz", ExpectBody: "down"}, StatusFail, "body does not match"},
+		{config.CheckConfig{Type: config.CheckHTTP, Target: srv.URL + "/missing"}, StatusFail, "unexpected status 404"},
+		{config.CheckConfig{Type: config.CheckHTTP, Target: srv.URL + "/missing", ExpectStatus: 404}, StatusOK, "status 404"},
+		{config.CheckConfig{Type: config.CheckHTTP, Target: srv.URL + "/moved"}, StatusOK, "status 200"},
+		{config.CheckConfig{Type: config.CheckHTTP, Target: srv.URL + "/loop"}, StatusFail, "redirects"},
+		{config.CheckConfig{Type: config.CheckHTTP, Target: tlsSrv.URL}, StatusFail, "certificate"},
+		{config.CheckConfig{Type: config.CheckHTTP, Target: tlsSrv.URL, InsecureSkipVerify: true}, StatusOK, "status 200"},
+		{config.CheckConfig{Type: config.CheckFile, Target: file, MaxAge: 60, MinSize: 10, MaxSize: 1000}, StatusOK, "size 100"},
+		{config.CheckConfig{Type: config.CheckFile, Target: filepath.Join(dir, "missing")}, StatusFail, "no such file"},
+		{config.CheckConfig{Type: config.CheckFile, Target: old, MaxAge: 3600}, StatusFail, "more than 3600s"},
+		{config.CheckConfig{Type: config.CheckFile, Target: file, MinSize: 200}, StatusFail, "less than 200"},
+		{config.CheckConfig{Type: config.CheckFile, Target: file, MaxSize: 50}, StatusFail, "more than 50"},
+		{config.CheckConfig{Type: config.CheckProcess, Target: filepath.Base(os.Args[0])}, StatusOK, "running"},
+		{config.CheckConfig{Type: config.CheckProcess, Target: "miniPanel-no-such-process"}, StatusFail, "is not running"},
+		{config.CheckConfig{Type: "ping", Target: "127.0.0.1"}, StatusFail, "unknown check type"},
+	}
+
+	cfgs := make([]config.CheckConfig, len(tests))
+	for i, tt := range tests {
+		cfgs[i] = tt.check
+		cfgs[i].Name = tt.check.Type + "-" + string(rune('a'+i))
+		cfgs[i].Timeout = 5
+	}
+	results := NewRunner(cfgs).Run(context.Background())
+	if len(results) != len(tests) {
+		t.Fatalf("got %d results, want %d", len(results), len(tests))
+	}
+	for i, tt := range tests {
+		r := results[i]
+		// 结果按配置顺序返回
+		if r.Name != cfgs[i].Name || r.Type != tt.check.Type {
+			t.Errorf("result %d is %s/%s, want %s/%s", i, r.Name, r.Type, cfgs[i].Name, tt.check.Type)
+		}
+		if r.Status != tt.wantStatus || !strings.Contains(r.Message, tt.wantMessage) {
+			t.Errorf("%s %s: %s %q, want %s containing %q", tt.check.Type, tt.check.Target, r.Status, r.Message, tt.wantStatus, tt.wantMessage)
+		}
+		if r.LatencyMs < 0 {
+			t.Errorf("%s: negative latency %v", r.Name, r.LatencyMs)
+		}
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	start := time.Now()
+	results := NewRunner([]config.CheckConfig{
+		{Name: "slow", Type: config.CheckHTTP, Target: srv.URL, Timeout: 1},
+	}).Run(context.Background())
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("check took %v with a 1s timeout", elapsed)
+	}
+	if results[0].Status != StatusFail || !strings.Contains(results[0].Message, "deadline exceeded") {
+		t.Errorf("slow check: %s %q", results[0].Status, results[0].Message)
+	}
+}
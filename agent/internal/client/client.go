@@ -2,64 +2,319 @@ package client
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"miniPanel-agent/internal/collector"
+	"miniPanel-agent/internal/config"
+	"miniPanel-agent/internal/version"
+	"miniPanel-agent/internal/wire"
 )
 
 // Client HTTP客户端
 type Client struct {
-	serverURL string
-	nodeName  string
+	serverURL  string
+	nodeName   string
 	httpClient *http.Client
+
+	retryCount       int
+	retryInterval    time.Duration
+	retryMaxInterval time.Duration
+	breaker          *breaker
+
+	format           string
+	protobuf         atomic.Bool // 当前使用 protobuf 格式上报
+	protobufRejected atomic.Bool // 服务器声明支持 protobuf 但无法解析，不再自动切换
+
+	stream *stream // 未配置 gRPC 地址时为 nil
 }
 
-// NewClient 创建新的HTTP客户端
-func NewClient(serverURL, nodeName string) *Client {
-	return &Client{
-		serverURL: serverURL,
-		nodeName:  nodeName,
+// NewClient 根据服务器配置创建HTTP客户端
+func NewClient(cfg config.ServerConfig, nodeName string) (*Client, error) {
+	tlsConfig, err := buildTLSConfig(cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{
+		serverURL:        cfg.URL,
+		nodeName:         nodeName,
+		retryCount:       cfg.RetryCount,
+		retryInterval:    time.Duration(cfg.RetryInterval) * time.Second,
+		retryMaxInterval: time.Duration(cfg.RetryMaxInterval) * time.Second,
+		breaker: &breaker{
+			threshold: cfg.BreakerThreshold,
+			cooldown:  time.Duration(cfg.BreakerCooldown) * time.Second,
+		},
+		format: cfg.Format,
 		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
+			Timeout: time.Duration(cfg.Timeout) * time.Second,
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			},
 		},
 	}
+	c.protobuf.Store(cfg.Format == config.FormatProtobuf)
+
+	if cfg.GRPC != "" {
+		// 上报地址为 https 时 gRPC 同样使用 TLS 和客户端证书
+		var streamTLS *tls.Config
+		if strings.HasPrefix(cfg.URL, "https://") {
+			streamTLS = tlsConfig
+		}
+		if c.stream, err = newStream(c, cfg.GRPC, streamTLS, c.httpClient.Timeout); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// OpenStream 在后台连接 gRPC 流，服务器下发的命令发送到 commands。
+// 未配置 gRPC 地址或已经连接时不做任何事
+func (c *Client) OpenStream(commands chan<- Command) {
+	if c.stream != nil && c.stream.done == nil {
+		c.stream.start(commands)
+	}
 }
 
-// SendMetrics 发送监控数据到服务器
-func (c *Client) SendMetrics(metrics *collector.MetricsData) error {
-	// 将数据转换为JSON
-	jsonData, err := json.Marshal(metrics)
+// Close 断开 gRPC 流，客户端不再使用时调用
+func (c *Client) Close() {
+	if c.stream != nil {
+		c.stream.close()
+	}
+}
+
+// StreamState gRPC 流的连接状态，未配置 gRPC 地址时为空
+func (c *Client) StreamState() string {
+	if c.stream == nil {
+		return ""
+	}
+	return c.stream.state()
+}
+
+// buildTLSConfig 加载自定义CA和客户端证书
+func buildTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		caData, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(caData) {
+			return nil, fmt.Errorf("no valid certificates found in %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// SendResult 服务器对上报数据的响应
+type SendResult struct {
+	ConfigVersion string `json:"config_version"` // 集中配置版本，旧版本服务器不返回
+}
+
+// SendMetrics 发送监控数据到服务器。可重试的错误按指数退避重试，
+// 服务器要求的 Retry-After 超过重试间隔上限时不再等待；熔断期间直接返回 ErrCircuitOpen
+func (c *Client) SendMetrics(ctx context.Context, metrics *collector.MetricsData) (*SendResult, error) {
+	probe, err := c.breaker.allow(time.Now())
 	if err != nil {
-		return fmt.Errorf("failed to marshal metrics: %v", err)
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		result, err := c.sendOnce(ctx, metrics)
+		if err == nil || IsPermanent(err) {
+			// 服务器可以访问，被拒绝的请求不计入熔断
+			c.breaker.success()
+			return result, err
+		}
+
+		wait := backoff(attempt, c.retryInterval, c.retryMaxInterval)
+		if after := retryAfter(err); after > wait {
+			wait = after
+		}
+		if probe || attempt >= c.retryCount || wait > c.retryMaxInterval || ctx.Err() != nil {
+			c.breaker.failure(time.Now(), retryAfter(err))
+			return nil, err
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			c.breaker.failure(time.Now(), retryAfter(err))
+			return nil, err
+		}
+	}
+}
+
+// sendOnce 发送一次数据，gRPC 流已连接时通过流发送，否则使用 HTTP。
+// 自动选择格式时，服务器无法解析 protobuf（如回退到了旧版本）则改用 JSON 立即重发
+func (c *Client) sendOnce(ctx context.Context, metrics *collector.MetricsData) (*SendResult, error) {
+	if c.stream != nil {
+		result, err := c.stream.send(ctx, metrics)
+		if !errors.Is(err, errStreamDown) {
+			return result, err
+		}
+	}
+
+	protobuf := c.protobuf.Load()
+	result, err := c.post(ctx, metrics, protobuf)
+
+	var se *StatusError
+	if protobuf && c.format == config.FormatAuto && errors.As(err, &se) &&
+		(se.StatusCode == http.StatusBadRequest || se.StatusCode == http.StatusUnsupportedMediaType) {
+		c.protobufRejected.Store(true)
+		c.protobuf.Store(false)
+		return c.post(ctx, metrics, false)
+	}
+	return result, err
+}
+
+// post 按指定格式编码并发送数据
+func (c *Client) post(ctx context.Context, metrics *collector.MetricsData, protobuf bool) (*SendResult, error) {
+	// 附带发送时间，补发的数据同样是补发时的时间
+	sentAt := time.Now()
+	var data []byte
+	contentType := wire.ContentTypeJSON
+	if protobuf {
+		data = wire.EncodeReport(metrics, sentAt)
+		contentType = wire.ContentTypeProtobuf
+	} else {
+		// 将数据转换为JSON，附带协议版本
+		var err error
+		data, err = wire.EncodeJSON(metrics, sentAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal metrics: %v", err)
+		}
 	}
 
 	// 创建HTTP请求
-	req, err := http.NewRequest("POST", c.serverURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.serverURL, bytes.NewReader(data))
 	if err != nil {
-		return fmt.Errorf("failed to create request: %v", err)
+		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
 
 	// 设置请求头
-	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Type", contentType)
 	req.Header.Set("Node-Name", c.nodeName)
-	req.Header.Set("User-Agent", "MiniPanel-Agent/1.0")
+	req.Header.Set("User-Agent", version.UserAgent())
 
 	// 发送请求
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %v", err)
+		return nil, fmt.Errorf("failed to send request: %v", err)
 	}
 	defer resp.Body.Close()
+	c.negotiate(resp.Header)
+
+	// 响应内容只包含附加信息，解析失败时忽略
+	var body struct {
+		Message string     `json:"message"`
+		Data    SendResult `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
 
 	// 检查响应状态
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("server returned status: %d", resp.StatusCode)
+		return nil, &StatusError{
+			StatusCode: resp.StatusCode,
+			Message:    body.Message,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
 	}
+	return &body.Data, nil
+}
 
-	return nil
+// negotiate 自动选择格式时，服务器在 Accept-Post 响应头中声明支持 protobuf 后改用 protobuf
+func (c *Client) negotiate(header http.Header) {
+	if c.format != config.FormatAuto || c.protobufRejected.Load() {
+		return
+	}
+	for _, value := range strings.Split(header.Get("Accept-Post"), ",") {
+		mediaType, _, _ := strings.Cut(strings.TrimSpace(value), ";")
+		if strings.EqualFold(mediaType, wire.ContentTypeProtobuf) {
+			c.protobuf.Store(true)
+			return
+		}
+	}
+}
+
+// Format 当前使用的上报格式: json 或 protobuf
+func (c *Client) Format() string {
+	if c.protobuf.Load() {
+		return config.FormatProtobuf
+	}
+	return config.FormatJSON
+}
+
+// Circuit 返回熔断器状态，状态为 open 时同时返回恢复发送的时间
+func (c *Client) Circuit() (string, time.Time) {
+	return c.breaker.state(time.Now())
+}
+
+// RemoteConfig 服务器下发的本节点配置
+type RemoteConfig struct {
+	Version  string        `json:"version"`
+	Config   config.Remote `json:"config"`
+	Profiles []string      `json:"profiles"`
+}
+
+// FetchConfig 获取服务器为本节点生效的集中配置
+func (c *Client) FetchConfig() (*RemoteConfig, error) {
+	req, err := http.NewRequest("GET", strings.TrimSuffix(c.serverURL, "/")+"/config", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create config request: %v", err)
+	}
+	req.Header.Set("Node-Name", c.nodeName)
+	req.Header.Set("User-Agent", version.UserAgent())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch config: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned status: %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Data RemoteConfig `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode config: %v", err)
+	}
+	return &body.Data, nil
 }
 
 // TestConnection 测试与服务器的连接
@@ -70,7 +325,7 @@ func (c *Client) TestConnection() error {
 		return fmt.Errorf("failed to create test request: %v", err)
 	}
 
-	req.Header.Set("User-Agent", "MiniPanel-Agent/1.0")
+	req.Header.Set("User-Agent", version.UserAgent())
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
@@ -79,4 +334,117 @@ func (c *Client) TestConnection() error {
 	defer resp.Body.Close()
 
 	return nil
-}
\ No newline at end of file
+}
+
+// CheckResult 服务器识别出的Agent身份
+type CheckResult struct {
+	NodeName string `json:"node_name"`
+	Identity string `json:"identity"`
+	ClientIP string `json:"client_ip"`
+	NodeID   int    `json:"node_id"`
+}
+
+// Check 调用服务器的检查接口，确认连接、TLS和认证配置有效
+func (c *Client) Check() (*CheckResult, error) {
+	req, err := http.NewRequest("GET", c.serverURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create check request: %v", err)
+	}
+	req.Header.Set("Node-Name", c.nodeName)
+	req.Header.Set("User-Agent", version.UserAgent())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to server: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Success bool        `json:"success"`
+		Message string      `json:"message"`
+		Data    CheckResult `json:"data"`
+	}
+	decodeErr := json.NewDecoder(resp.Body).Decode(&body)
+
+	switch {
+	case resp.StatusCode == http.StatusOK && decodeErr == nil:
+		return &body.Data, nil
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("check endpoint not found, server may be too old or url is wrong")
+	case body.Message != "":
+		return nil, fmt.Errorf("server returned status %d: %s", resp.StatusCode, body.Message)
+	default:
+		return nil, fmt.Errorf("server returned status: %d", resp.StatusCode)
+	}
+}
+
+// Release 服务器上的Agent发布文件
+type Release struct {
+	Version   string `json:"version"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	SHA256    string `json:"sha256"`
+	Signature string `json:"signature"`
+	Size      int64  `json:"size"`
+}
+
+// releaseURL 目标版本发布文件的接口地址，suffix 为空时获取文件信息，为 "/binary" 时下载文件
+func (c *Client) releaseURL(target, goos, arch, suffix string) string {
+	query := url.Values{"os": {goos}, "arch": {arch}}
+	return strings.TrimSuffix(c.serverURL, "/") + "/releases/" + url.PathEscape(target) + suffix + "?" + query.Encode()
+}
+
+// FetchRelease 获取发布文件的大小、摘要和签名
+func (c *Client) FetchRelease(target, goos, arch string) (*Release, error) {
+	req, err := http.NewRequest("GET", c.releaseURL(target, goos, arch, ""), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create release request: %v", err)
+	}
+	req.Header.Set("Node-Name", c.nodeName)
+	req.Header.Set("User-Agent", version.UserAgent())
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch release: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned status: %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Data Release `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode release: %v", err)
+	}
+	return &body.Data, nil
+}
+
+// DownloadRelease 下载发布文件写入 w，最多写入 maxSize 字节
+func (c *Client) DownloadRelease(target, goos, arch string, maxSize int64, w io.Writer) error {
+	req, err := http.NewRequest("GET", c.releaseURL(target, goos, arch, "/binary"), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create download request: %v", err)
+	}
+	req.Header.Set("Node-Name", c.nodeName)
+	req.Header.Set("User-Agent", version.UserAgent())
+
+	// 下载文件需要比上报更长的超时
+	download := *c.httpClient
+	download.Timeout = 10 * time.Minute
+	resp, err := download.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download release: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned status: %d", resp.StatusCode)
+	}
+	if _, err := io.Copy(w, io.LimitReader(resp.Body, maxSize)); err != nil {
+		return fmt.Errorf("failed to download release: %v", err)
+	}
+	return nil
+}
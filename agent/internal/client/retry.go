@@ -0,0 +1,151 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen 熔断期间不发送请求，数据由调用方暂存
+var ErrCircuitOpen = errors.New("circuit breaker is open, not sending")
+
+// StatusError 服务器返回的非 200 响应
+type StatusError struct {
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration // 服务器通过 Retry-After 要求的等待时间，没有时为 0
+}
+
+func (e *StatusError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("server returned status %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("server returned status: %d", e.StatusCode)
+}
+
+// IsPermanent 判断错误是否重试也不会成功。服务器拒绝了请求本身（4xx）时不重试，
+// 408 和 429 表示服务器暂时无法处理，与 5xx 和网络错误一样可以重试
+func IsPermanent(err error) bool {
+	var se *StatusError
+	if !errors.As(err, &se) {
+		return false
+	}
+	if se.StatusCode == http.StatusRequestTimeout || se.StatusCode == http.StatusTooManyRequests {
+		return false
+	}
+	return se.StatusCode >= 400 && se.StatusCode < 500
+}
+
+// retryAfter 返回错误中服务器要求的等待时间
+func retryAfter(err error) time.Duration {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.RetryAfter
+	}
+	return 0
+}
+
+// parseRetryAfter 解析 Retry-After 响应头，支持秒数和HTTP日期两种格式
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// backoff 第 attempt 次重试前的等待时间：指数增长到上限，再取其中随机的一半到全部，
+// 避免大量Agent在服务器恢复时同时重试
+func backoff(attempt int, base, max time.Duration) time.Duration {
+	d := base
+	for i := 0; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + rand.N(d-half+1)
+}
+
+// 熔断器状态
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// breaker 连续发送失败达到阈值后暂停发送，冷却时间过后放行一次试探请求，
+// 成功则恢复，失败则继续暂停。服务器通过 Retry-After 要求等待时同样暂停发送
+type breaker struct {
+	mu        sync.Mutex
+	threshold int // 为 0 时只遵守 Retry-After，不熔断
+	cooldown  time.Duration
+	failures  int
+	openUntil time.Time
+}
+
+// allow 返回是否可以发送，probe 表示这是熔断后的试探请求，不应重试
+func (b *breaker) allow(now time.Time) (probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if now.Before(b.openUntil) {
+		return false, ErrCircuitOpen
+	}
+	return b.threshold > 0 && b.failures >= b.threshold, nil
+}
+
+// success 服务器可以访问，清除失败计数
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.openUntil = time.Time{}
+}
+
+// failure 记录一次失败的发送（已包含重试），wait 为服务器要求的等待时间
+func (b *breaker) failure(now time.Time, wait time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	until := now.Add(wait)
+	if b.threshold > 0 && b.failures >= b.threshold && b.cooldown > wait {
+		until = now.Add(b.cooldown)
+	}
+	if until.After(b.openUntil) {
+		b.openUntil = until
+	}
+}
+
+// state 返回熔断器状态和暂停发送的截止时间
+func (b *breaker) state(now time.Time) (string, time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case now.Before(b.openUntil):
+		return CircuitOpen, b.openUntil
+	case b.threshold > 0 && b.failures >= b.threshold:
+		return CircuitHalfOpen, time.Time{}
+	default:
+		return CircuitClosed, time.Time{}
+	}
+}
@@ -0,0 +1,168 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestIsPermanent(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"network error", errors.New("connection refused"), false},
+		{"bad request", &StatusError{StatusCode: http.StatusBadRequest}, true},
+		{"unauthorized", &StatusError{StatusCode: http.StatusUnauthorized}, true},
+		{"gone", &StatusError{StatusCode: http.StatusGone}, true},
+		{"unprocessable", &StatusError{StatusCode: http.StatusUnprocessableEntity}, true},
+		{"request timeout", &StatusError{StatusCode: http.StatusRequestTimeout}, false},
+		{"too many requests", &StatusError{StatusCode: http.StatusTooManyRequests}, false},
+		{"server error", &StatusError{StatusCode: http.StatusInternalServerError}, false},
+		{"bad gateway", &StatusError{StatusCode: http.StatusBadGateway}, false},
+		{"wrapped", fmt.Errorf("send: %w", &StatusError{StatusCode: http.StatusForbidden}), true},
+	}
+	for _, tt := range tests {
+		if got := IsPermanent(tt.err); got != tt.want {
+			t.Errorf("%s: IsPermanent = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"0", 0},
+		{"5", 5 * time.Second},
+		{"120", 2 * time.Minute},
+		{"-3", 0},
+		{"soon", 0},
+		{now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second},
+		{now.Add(-30 * time.Second).Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if got := retryAfter(&StatusError{StatusCode: 429, RetryAfter: 7 * time.Second}); got != 7*time.Second {
+		t.Errorf("retryAfter = %v, want 7s", got)
+	}
+	if got := retryAfter(errors.New("timeout")); got != 0 {
+		t.Errorf("retryAfter(network error) = %v, want 0", got)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	base, max := time.Second, 10*time.Second
+	tests := []struct {
+		attempt int
+		full    time.Duration // 抖动前的等待时间
+	}{
+		{0, time.Second},
+		{1, 2 * time.Second},
+		{2, 4 * time.Second},
+		{3, 8 * time.Second},
+		{4, 10 * time.Second},
+		{50, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 100; i++ {
+			d := backoff(tt.attempt, base, max)
+			if d < tt.full/2 || d > tt.full {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", tt.attempt, d, tt.full/2, tt.full)
+			}
+		}
+	}
+	if d := backoff(3, 0, max); d != 0 {
+		t.Errorf("backoff with zero base = %v, want 0", d)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	b := &breaker{threshold: 3, cooldown: time.Minute}
+
+	// 未达到阈值时继续发送
+	for i := 0; i < 2; i++ {
+		b.failure(now, 0)
+		if probe, err := b.allow(now); err != nil || probe {
+			t.Fatalf("after %d failures: allow = %v, %v", i+1, probe, err)
+		}
+	}
+	if state, _ := b.state(now); state != CircuitClosed {
+		t.Fatalf("state = %s, want closed", state)
+	}
+
+	// 达到阈值后在冷却期内暂停
+	b.failure(now, 0)
+	if _, err := b.allow(now.Add(30 * time.Second)); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("during cooldown: allow err = %v, want ErrCircuitOpen", err)
+	}
+	if state, until := b.state(now); state != CircuitOpen || !until.Equal(now.Add(time.Minute)) {
+		t.Fatalf("state = %s until %v, want open until %v", state, until, now.Add(time.Minute))
+	}
+
+	// 冷却结束后放行一次试探请求
+	later := now.Add(time.Minute)
+	if probe, err := b.allow(later); err != nil || !probe {
+		t.Fatalf("after cooldown: allow = %v, %v, want probe", probe, err)
+	}
+	if state, _ := b.state(later); state != CircuitHalfOpen {
+		t.Fatalf("state = %s, want half-open", state)
+	}
+
+	// 试探失败继续暂停，成功则恢复
+	b.failure(later, 0)
+	if _, err := b.allow(later); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("after failed probe: allow err = %v, want ErrCircuitOpen", err)
+	}
+	b.success()
+	if probe, err := b.allow(later); err != nil || probe {
+		t.Fatalf("after success: allow = %v, %v", probe, err)
+	}
+	if state, _ := b.state(later); state != CircuitClosed {
+		t.Fatalf("state = %s, want closed", state)
+	}
+}
+
+func TestBreakerRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		threshold int
+		failures  int
+		wait      time.Duration
+		wantUntil time.Duration
+	}{
+		{"retry-after without breaker", 0, 1, 20 * time.Second, 20 * time.Second},
+		{"retry-after below threshold", 3, 1, 20 * time.Second, 20 * time.Second},
+		{"cooldown longer than retry-after", 3, 3, 20 * time.Second, time.Minute},
+		{"retry-after longer than cooldown", 3, 3, 5 * time.Minute, 5 * time.Minute},
+		{"disabled breaker never opens", 0, 10, 0, 0},
+	}
+	for _, tt := range tests {
+		b := &breaker{threshold: tt.threshold, cooldown: time.Minute}
+		for i := 0; i < tt.failures; i++ {
+			b.failure(now, tt.wait)
+		}
+		_, until := b.state(now)
+		var want time.Time
+		if tt.wantUntil > 0 {
+			want = now.Add(tt.wantUntil)
+		}
+		if !until.Equal(want) {
+			t.Errorf("%s: open until %v, want %v", tt.name, until, want)
+		}
+	}
+}
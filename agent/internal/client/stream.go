@@ -0,0 +1,262 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"miniPanel-agent/internal/collector"
+	"miniPanel-agent/internal/version"
+	"miniPanel-agent/internal/wire"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+)
+
+// errStreamDown gRPC 流未连接，改用 HTTP 上报
+var errStreamDown = errors.New("grpc stream is not connected")
+
+// 流断开后重连的等待时间
+const (
+	streamRetryMin = time.Second
+	streamRetryMax = time.Minute
+)
+
+// 流连接状态
+const (
+	StreamConnected    = "connected"
+	StreamDisconnected = "disconnected"
+)
+
+// Command 服务器通过 gRPC 流下发的命令
+type Command struct {
+	wire.Command
+	Source *Client // 收到命令的客户端
+}
+
+// stream 与服务器的 gRPC 双向流。连接在后台维护，断开后按指数退避重连；
+// 未连接期间上报数据走 HTTP
+type stream struct {
+	client   *Client
+	conn     *grpc.ClientConn
+	timeout  time.Duration
+	commands chan<- Command
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	sendMu sync.Mutex // SendMsg 不能并发调用
+
+	mu      sync.Mutex
+	cs      grpc.ClientStream // 未连接时为 nil
+	seq     uint64
+	pending map[uint64]chan *wire.Ack
+}
+
+// newStream 创建到 addr 的 gRPC 连接，tlsConfig 为 nil 时不加密
+func newStream(c *Client, addr string, tlsConfig *tls.Config, timeout time.Duration) (*stream, error) {
+	creds := insecure.NewCredentials()
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	conn, err := grpc.NewClient(addr,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithUserAgent(version.UserAgent()),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(wire.Codec{})),
+		// 与服务器的探测策略一致，间隔不能小于服务器允许的 10 秒
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                30 * time.Second,
+			Timeout:             10 * time.Second,
+			PermitWithoutStream: true,
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid grpc address %s: %v", addr, err)
+	}
+	return &stream{
+		client:  c,
+		conn:    conn,
+		timeout: timeout,
+		pending: map[uint64]chan *wire.Ack{},
+	}, nil
+}
+
+// start 在后台连接并处理服务器消息，命令发送到 commands
+func (s *stream) start(commands chan<- Command) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.commands, s.cancel = commands, cancel
+	s.done = make(chan struct{})
+
+	go func() {
+		defer close(s.done)
+		wait := streamRetryMin
+		for {
+			connected, err := s.connect(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			if connected {
+				wait = streamRetryMin
+				log.Printf("gRPC 流已断开，%v 后重连: %v", wait, err)
+			} else {
+				log.Printf("gRPC 流连接失败，%v 后重试: %v", wait, err)
+			}
+
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return
+			}
+			wait = min(wait*2, streamRetryMax)
+		}
+	}()
+}
+
+// close 断开连接，等待后台协程退出
+func (s *stream) close() {
+	if s.cancel != nil {
+		s.cancel()
+		<-s.done
+	}
+	s.conn.Close()
+}
+
+// connect 建立一次流连接并处理服务器消息直到断开，返回是否曾连接成功
+func (s *stream) connect(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cs, err := s.conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}, wire.StreamMethod)
+	if err != nil {
+		return false, err
+	}
+	if err := cs.SendMsg(wire.EncodeHello(s.client.nodeName, version.Version)); err != nil {
+		return false, err
+	}
+	msg, err := s.recv(cs)
+	if err != nil {
+		return false, err
+	}
+	if msg.Welcome == nil {
+		return false, fmt.Errorf("server did not send welcome")
+	}
+	log.Printf("gRPC 流已连接，节点 %s (ID %d)", msg.Welcome.NodeName, msg.Welcome.NodeID)
+
+	s.mu.Lock()
+	s.cs = cs
+	s.mu.Unlock()
+	defer s.down()
+
+	for {
+		msg, err := s.recv(cs)
+		if err != nil {
+			return true, err
+		}
+		switch {
+		case msg.Ack != nil:
+			s.mu.Lock()
+			ch := s.pending[msg.Ack.Seq]
+			delete(s.pending, msg.Ack.Seq)
+			s.mu.Unlock()
+			if ch != nil {
+				ch <- msg.Ack
+			}
+		case msg.Command != nil:
+			// 采集循环繁忙时丢弃命令，服务器可以重新下发
+			select {
+			case s.commands <- Command{Command: *msg.Command, Source: s.client}:
+			default:
+				log.Printf("忽略服务器命令 %s: 上一个命令尚未处理", msg.Command.Type)
+			}
+		}
+	}
+}
+
+func (s *stream) recv(cs grpc.ClientStream) (*wire.ServerMessage, error) {
+	var data []byte
+	if err := cs.RecvMsg(&data); err != nil {
+		return nil, err
+	}
+	return wire.DecodeServerMessage(data)
+}
+
+// down 标记流已断开，等待回复的上报数据改用 HTTP 发送
+func (s *stream) down() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cs = nil
+	for seq, ch := range s.pending {
+		close(ch)
+		delete(s.pending, seq)
+	}
+}
+
+// state 连接状态
+func (s *stream) state() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cs == nil {
+		return StreamDisconnected
+	}
+	return StreamConnected
+}
+
+// send 通过流发送一条数据并等待服务器回复。流未连接或在回复前断开时返回 errStreamDown
+func (s *stream) send(ctx context.Context, metrics *collector.MetricsData) (*SendResult, error) {
+	s.mu.Lock()
+	cs := s.cs
+	if cs == nil {
+		s.mu.Unlock()
+		return nil, errStreamDown
+	}
+	s.seq++
+	seq := s.seq
+	ch := make(chan *wire.Ack, 1)
+	s.pending[seq] = ch
+	s.mu.Unlock()
+
+	s.sendMu.Lock()
+	err := cs.SendMsg(wire.EncodeReportRequest(seq, metrics, time.Now()))
+	s.sendMu.Unlock()
+	if err != nil {
+		s.forget(seq)
+		return nil, errStreamDown
+	}
+
+	timer := time.NewTimer(s.timeout)
+	defer timer.Stop()
+	select {
+	case ack, ok := <-ch:
+		if !ok {
+			return nil, errStreamDown
+		}
+		if ack.Status != http.StatusOK {
+			return nil, &StatusError{
+				StatusCode: ack.Status,
+				Message:    ack.Message,
+				RetryAfter: time.Duration(ack.RetryAfter) * time.Second,
+			}
+		}
+		return &SendResult{ConfigVersion: ack.ConfigVersion}, nil
+	case <-timer.C:
+		s.forget(seq)
+		return nil, fmt.Errorf("no reply from grpc stream within %v", s.timeout)
+	case <-ctx.Done():
+		s.forget(seq)
+		return nil, ctx.Err()
+	}
+}
+
+func (s *stream) forget(seq uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pending, seq)
+}
@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"time"
 
+	"miniPanel-agent/internal/checks"
+	"miniPanel-agent/internal/version"
+
 	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/host"
 	"github.com/shirou/gopsutil/v3/mem"
@@ -17,6 +20,36 @@ type MetricsData struct {
 	MemoryPercent float64   `json:"memory_percent"`
 	CPUTemp       float64   `json:"cpu_temp"`
 	Timestamp     time.Time `json:"timestamp"`
+
+	// Labels 节点标签，由Agent配置提供，不能为 nil，否则服务器不会同步标签
+	Labels map[string]string `json:"labels"`
+
+	// Host 主机信息，只在启动后首次上报和发生变化时携带
+	Host *HostInfo `json:"host,omitempty"`
+
+	// Checks 服务检查结果，总是携带全部检查，服务器据此删除已移除的检查
+	Checks []checks.Result `json:"checks"`
+
+	// Units systemd 单元状态，与服务检查一样总是携带全部单元
+	Units []UnitStatus `json:"units"`
+
+	// UnitsUnavailable 没有获取到 systemd 单元状态，此时 Units 为 nil，服务器保留已有单元
+	UnitsUnavailable bool `json:"units_unavailable,omitempty"`
+}
+
+// HostInfo 主机信息
+type HostInfo struct {
+	Hostname        string `json:"hostname"`
+	OS              string `json:"os"`
+	Platform        string `json:"platform"`
+	PlatformVersion string `json:"platform_version"`
+	KernelVersion   string `json:"kernel_version"`
+	Arch            string `json:"arch"`
+	CPUModel        string `json:"cpu_model"`
+	CPUCores        int    `json:"cpu_cores"`
+	MemoryTotal     uint64 `json:"memory_total"`
+	BootTime        uint64 `json:"boot_time"` // Unix时间戳（秒）
+	AgentVersion    string `json:"agent_version"`
 }
 
 // Collector 数据采集器
@@ -24,6 +57,9 @@ type Collector struct {
 	enableCPU    bool
 	enableMemory bool
 	enableTemp   bool
+
+	// reportedHost 最近一次成功上报的主机信息
+	reportedHost *HostInfo
 }
 
 // NewCollector 创建新的采集器
@@ -77,6 +113,62 @@ func (c *Collector) CollectMetrics() (*MetricsData, error) {
 	return metrics, nil
 }
 
+// CollectHostInfo 采集主机信息
+func (c *Collector) CollectHostInfo() (*HostInfo, error) {
+	hostInfo, err := host.Info()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get host info: %v", err)
+	}
+
+	info := &HostInfo{
+		Hostname:        hostInfo.Hostname,
+		OS:              hostInfo.OS,
+		Platform:        hostInfo.Platform,
+		PlatformVersion: hostInfo.PlatformVersion,
+		KernelVersion:   hostInfo.KernelVersion,
+		Arch:            hostInfo.KernelArch,
+		BootTime:        hostInfo.BootTime,
+		AgentVersion:    version.Version,
+	}
+
+	// CPU型号和内存获取失败时保留其余信息
+	if cpuInfo, err := cpu.Info(); err == nil && len(cpuInfo) > 0 {
+		info.CPUModel = cpuInfo[0].ModelName
+	}
+	if cores, err := cpu.Counts(true); err == nil {
+		info.CPUCores = cores
+	}
+	if memInfo, err := mem.VirtualMemory(); err == nil {
+		info.MemoryTotal = memInfo.Total
+	}
+
+	return info, nil
+}
+
+// HostInfoIfChanged 返回需要上报的主机信息，与上次成功上报的相同时返回 nil
+func (c *Collector) HostInfoIfChanged() *HostInfo {
+	info, err := c.CollectHostInfo()
+	if err != nil {
+		return nil
+	}
+	if c.reportedHost != nil && *c.reportedHost == *info {
+		return nil
+	}
+	return info
+}
+
+// MarkHostInfoReported 记录已成功上报的主机信息
+func (c *Collector) MarkHostInfoReported(info *HostInfo) {
+	if info != nil {
+		c.reportedHost = info
+	}
+}
+
+// ForceHostInfo 下次采集时无论是否变化都携带主机信息
+func (c *Collector) ForceHostInfo() {
+	c.reportedHost = nil
+}
+
 // getCPUTemperature 获取CPU温度
 func (c *Collector) getCPUTemperature() (float64, error) {
 	// 尝试从host.SensorsTemperatures获取温度信息
@@ -102,4 +194,4 @@ func (c *Collector) getCPUTemperature() (float64, error) {
 	}
 
 	return 0, fmt.Errorf("no temperature sensors found")
-}
\ No newline at end of file
+}
@@ -0,0 +1,132 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// systemctlTimeout 一次采集中全部 systemctl 调用的超时时间
+const systemctlTimeout = 5 * time.Second
+
+// unitProperties systemctl show 读取的单元属性
+const unitProperties = "Id,LoadState,ActiveState,SubState,NRestarts,MemoryCurrent"
+
+// UnitStatus systemd 单元的状态
+type UnitStatus struct {
+	Name        string `json:"name"`
+	LoadState   string `json:"load_state"`   // loaded、not-found 等
+	ActiveState string `json:"active_state"` // active、inactive、failed 等
+	SubState    string `json:"sub_state"`    // running、exited、dead 等
+	Restarts    uint32 `json:"restarts"`     // systemd 自动重启的次数（NRestarts）
+	MemoryBytes uint64 `json:"memory_bytes"` // 当前内存占用（MemoryCurrent），未开启内存统计时为 0
+}
+
+// UnitCollector 通过 systemctl 采集匹配的 systemd 单元
+type UnitCollector struct {
+	patterns []string
+}
+
+// NewUnitCollector 创建单元采集器，patterns 为单元名称或通配符
+func NewUnitCollector(patterns []string) *UnitCollector {
+	return &UnitCollector{patterns: patterns}
+}
+
+// Collect 采集匹配的单元状态，按名称排序。
+// 不含通配符的名称总是上报（不存在时 load_state 为 not-found），通配符只匹配 systemd 已加载的单元
+func (u *UnitCollector) Collect(ctx context.Context) ([]UnitStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, systemctlTimeout)
+	defer cancel()
+
+	var names, globs []string
+	for _, p := range u.patterns {
+		if strings.ContainsAny(p, "*?[") {
+			globs = append(globs, p)
+		} else {
+			names = append(names, p)
+		}
+	}
+	if len(globs) > 0 {
+		args := append([]string{"list-units", "--all", "--plain", "--no-legend", "--full", "--no-pager", "--"}, globs...)
+		out, err := systemctl(ctx, args...)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(out), "\n") {
+			if fields := strings.Fields(line); len(fields) > 0 {
+				names = append(names, fields[0])
+			}
+		}
+	}
+
+	units := []UnitStatus{}
+	if len(names) == 0 {
+		return units, nil
+	}
+	out, err := systemctl(ctx, append([]string{"show", "--property=" + unitProperties, "--"}, names...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// 每个单元一段属性，以空行分隔；别名和重复匹配的单元只保留一次
+	seen := map[string]bool{}
+	for _, block := range strings.Split(string(out), "\n\n") {
+		unit, ok := parseUnit(block)
+		if !ok || seen[unit.Name] {
+			continue
+		}
+		seen[unit.Name] = true
+		units = append(units, unit)
+	}
+	sort.Slice(units, func(i, j int) bool { return units[i].Name < units[j].Name })
+	return units, nil
+}
+
+// parseUnit 解析 systemctl show 输出的一段 key=value 属性
+func parseUnit(block string) (UnitStatus, bool) {
+	var unit UnitStatus
+	for _, line := range strings.Split(block, "\n") {
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Id":
+			unit.Name = val
+		case "LoadState":
+			unit.LoadState = val
+		case "ActiveState":
+			unit.ActiveState = val
+		case "SubState":
+			unit.SubState = val
+		case "NRestarts":
+			n, _ := strconv.ParseUint(val, 10, 32)
+			unit.Restarts = uint32(n)
+		case "MemoryCurrent":
+			// 未开启内存统计时为 [not set] 或 uint64 最大值
+			if n, err := strconv.ParseUint(val, 10, 64); err == nil && n != math.MaxUint64 {
+				unit.MemoryBytes = n
+			}
+		}
+	}
+	return unit, unit.Name != ""
+}
+
+func systemctl(ctx context.Context, args ...string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, "systemctl", args...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("systemctl %s: %s", args[0], bytes.TrimSpace(exitErr.Stderr))
+		}
+		return nil, fmt.Errorf("systemctl %s: %v", args[0], err)
+	}
+	return out, nil
+}
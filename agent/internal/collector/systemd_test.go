@@ -0,0 +1,123 @@
+package collector
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestParseUnit(t *testing.T) {
+	tests := []struct {
+		name   string
+		block  string
+		want   UnitStatus
+		wantOK bool
+	}{
+		{
+			name:   "running service",
+			block:  "Id=nginx.service\nLoadState=loaded\nActiveState=active\nSubState=running\nNRestarts=2\nMemoryCurrent=52428800",
+			want:   UnitStatus{Name: "nginx.service", LoadState: "loaded", ActiveState: "active", SubState: "running", Restarts: 2, MemoryBytes: 52428800},
+			wantOK: true,
+		},
+		{
+			name:   "memory accounting off",
+			block:  "Id=cron.service\nLoadState=loaded\nActiveState=active\nSubState=running\nNRestarts=0\nMemoryCurrent=[not set]",
+			want:   UnitStatus{Name: "cron.service", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+			wantOK: true,
+		},
+		{
+			name:   "memory max uint64",
+			block:  "Id=a.service\nMemoryCurrent=18446744073709551615",
+			want:   UnitStatus{Name: "a.service"},
+			wantOK: true,
+		},
+		{
+			name:   "not found",
+			block:  "Id=missing.service\nLoadState=not-found\nActiveState=inactive\nSubState=dead\nNRestarts=\nMemoryCurrent=",
+			want:   UnitStatus{Name: "missing.service", LoadState: "not-found", ActiveState: "inactive", SubState: "dead"},
+			wantOK: true,
+		},
+		{
+			name:   "value containing =",
+			block:  "Id=app@x=1.service\nActiveState=failed",
+			want:   UnitStatus{Name: "app@x=1.service", ActiveState: "failed"},
+			wantOK: true,
+		},
+		{
+			name:   "unknown properties and noise",
+			block:  "\nDescription=ignored\ngarbage line\nId=b.service\n",
+			want:   UnitStatus{Name: "b.service"},
+			wantOK: true,
+		},
+		{name: "no id", block: "LoadState=loaded\nActiveState=active"},
+		{name: "empty", block: ""},
+	}
+	for _, tt := range tests {
+		got, ok := parseUnit(tt.block)
+		if ok != tt.wantOK || (ok && got != tt.want) {
+			t.Errorf("%s: parseUnit = %+v, %v; want %+v, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+// fakeSystemctl 在 PATH 前面放一个输出固定内容的 systemctl
+func fakeSystemctl(t *testing.T, script string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a shell")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "systemctl"), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestUnitCollector(t *testing.T) {
+	fakeSystemctl(t, `case "$1" in
+list-units)
+	echo "miniPanel-agent.service loaded active running miniPanel Agent"
+	echo "miniPanel-backend.service loaded failed failed miniPanel Backend"
+	;;
+show)
+	printf 'Id=nginx.service\nLoadState=loaded\nActiveState=active\nSubState=running\nNRestarts=1\nMemoryCurrent=1024\n\n'
+	printf 'Id=miniPanel-agent.service\nLoadState=loaded\nActiveState=active\nSubState=running\nNRestarts=0\nMemoryCurrent=[not set]\n\n'
+	printf 'Id=miniPanel-backend.service\nLoadState=loaded\nActiveState=failed\nSubState=failed\nNRestarts=3\nMemoryCurrent=0\n\n'
+	printf 'Id=nginx.service\nLoadState=loaded\nActiveState=active\nSubState=running\nNRestarts=1\nMemoryCurrent=1024\n'
+	;;
+esac
+`)
+
+	units, err := NewUnitCollector([]string{"nginx.service", "miniPanel-*"}).Collect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 按名称排序，重复的单元只保留一次
+	want := []UnitStatus{
+		{Name: "miniPanel-agent.service", LoadState: "loaded", ActiveState: "active", SubState: "running"},
+		{Name: "miniPanel-backend.service", LoadState: "loaded", ActiveState: "failed", SubState: "failed", Restarts: 3},
+		{Name: "nginx.service", LoadState: "loaded", ActiveState: "active", SubState: "running", Restarts: 1, MemoryBytes: 1024},
+	}
+	if !reflect.DeepEqual(units, want) {
+		t.Errorf("Collect = %+v\nwant %+v", units, want)
+	}
+}
+
+func TestUnitCollectorNoMatch(t *testing.T) {
+	fakeSystemctl(t, "exit 0\n")
+	units, err := NewUnitCollector([]string{"nothing-*"}).Collect(context.Background())
+	if err != nil || units == nil || len(units) != 0 {
+		t.Errorf("Collect = %v, %v; want empty slice", units, err)
+	}
+}
+
+func TestUnitCollectorError(t *testing.T) {
+	fakeSystemctl(t, "echo 'System has not been booted with systemd' >&2\nexit 1\n")
+	_, err := NewUnitCollector([]string{"nginx.service"}).Collect(context.Background())
+	if err == nil || err.Error() != "systemctl show: System has not been booted with systemd" {
+		t.Errorf("Collect err = %v", err)
+	}
+}
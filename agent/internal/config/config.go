@@ -1,23 +1,222 @@
 package config
 
 import (
+	"crypto/ed25519"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"path"
+	"regexp"
 )
 
 type Config struct {
-	Server   ServerConfig   `json:"server"`
-	Agent    AgentConfig    `json:"agent"`
-	Collector CollectorConfig `json:"collector"`
+	Server     ServerConfig    `json:"server"`
+	Servers    []ServerConfig  `json:"servers"`     // 多个上报服务器，设置后忽略 server
+	ServerMode string          `json:"server_mode"` // 多个服务器时的发送方式: failover 或 fanout
+	Agent      AgentConfig     `json:"agent"`
+	Collector  CollectorConfig `json:"collector"`
+	Pull       PullConfig      `json:"pull"`
+	Status     StatusConfig    `json:"status"`
+	Remote     RemoteConfig    `json:"remote"`
+	Update     UpdateConfig    `json:"update"`
+	Checks     []CheckConfig   `json:"checks"` // 服务检查，每次采集时执行
+	Systemd    SystemdConfig   `json:"systemd"`
 }
 
+// 多个服务器时的发送方式
+const (
+	ServerModeFailover = "failover" // 按顺序发送到第一个可用的服务器
+	ServerModeFanout   = "fanout"   // 发送到所有服务器
+)
+
+// 上报数据格式
+const (
+	FormatAuto     = "auto"     // 服务器声明支持时使用 protobuf，否则使用 JSON
+	FormatJSON     = "json"     // 始终使用 JSON，兼容所有版本的服务器
+	FormatProtobuf = "protobuf" // 始终使用 protobuf
+)
+
 type ServerConfig struct {
-	URL string `json:"url"`
+	Name   string    `json:"name"` // 日志和状态接口中显示的名称，为空时使用 URL
+	URL    string    `json:"url"`  // 上报地址，为空时不主动上报（仅拉取模式）
+	TLS    TLSConfig `json:"tls"`
+	Format string    `json:"format"` // 上报数据格式: auto、json 或 protobuf
+	GRPC   string    `json:"grpc"`   // 服务器 gRPC 流地址 host:port，为空时只使用 HTTP 上报
+
+	Timeout          int `json:"timeout"`            // 单次请求超时时间（秒）
+	RetryCount       int `json:"retry_count"`        // 发送失败后的重试次数，0 表示不重试
+	RetryInterval    int `json:"retry_interval"`     // 首次重试前的等待时间（秒），之后每次翻倍
+	RetryMaxInterval int `json:"retry_max_interval"` // 重试等待时间上限（秒），也是接受的 Retry-After 上限
+	BreakerThreshold int `json:"breaker_threshold"`  // 连续多少次发送失败后熔断，0 表示不熔断
+	BreakerCooldown  int `json:"breaker_cooldown"`   // 熔断后暂停发送的时间（秒）
+}
+
+// defaultServerConfig 服务器配置中超时、重试和熔断的默认值
+func defaultServerConfig() ServerConfig {
+	return ServerConfig{
+		Format:           FormatAuto,
+		Timeout:          10,
+		RetryCount:       3,
+		RetryInterval:    1,
+		RetryMaxInterval: 30,
+		BreakerThreshold: 5,
+		BreakerCooldown:  60,
+	}
+}
+
+// UnmarshalJSON 在默认值基础上覆盖，servers 列表中未填写的项同样使用默认值
+func (s *ServerConfig) UnmarshalJSON(data []byte) error {
+	type plain ServerConfig
+	if *s == (ServerConfig{}) {
+		*s = defaultServerConfig()
+	}
+	return json.Unmarshal(data, (*plain)(s))
+}
+
+// Label 日志和状态接口中显示的名称
+func (s ServerConfig) Label() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.URL
+}
+
+// Destinations 返回上报服务器列表，未配置服务器地址时为空
+func (c *Config) Destinations() []ServerConfig {
+	if len(c.Servers) > 0 {
+		return c.Servers
+	}
+	if c.Server.URL != "" {
+		return []ServerConfig{c.Server}
+	}
+	return nil
+}
+
+// 服务检查类型
+const (
+	CheckTCP     = "tcp"     // target 为 host:port，能建立连接即正常
+	CheckHTTP    = "http"    // target 为 URL，检查状态码和响应内容
+	CheckProcess = "process" // target 为进程名
+	CheckFile    = "file"    // target 为文件路径，检查修改时间和大小
+)
+
+// CheckConfig 一项服务检查
+type CheckConfig struct {
+	Name    string `json:"name"` // 在服务器上区分检查，同一节点内不能重复
+	Type    string `json:"type"`
+	Target  string `json:"target"`
+	Timeout int    `json:"timeout"` // 超时时间（秒）
+
+	ExpectStatus       int    `json:"expect_status"`        // http: 期望的状态码，0 表示 2xx 或 3xx
+	ExpectBody         string `json:"expect_body"`          // http: 响应内容需要匹配的正则表达式
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"` // http: 跳过证书校验
+
+	MaxAge  int   `json:"max_age"`  // file: 距最后修改的最长时间（秒），0 表示不检查
+	MinSize int64 `json:"min_size"` // file: 最小字节数，0 表示不检查
+	MaxSize int64 `json:"max_size"` // file: 最大字节数，0 表示不检查
+}
+
+// UnmarshalJSON 未填写的超时时间使用默认值
+func (c *CheckConfig) UnmarshalJSON(data []byte) error {
+	type plain CheckConfig
+	if c.Timeout == 0 {
+		c.Timeout = 5
+	}
+	return json.Unmarshal(data, (*plain)(c))
+}
+
+// validateCheck 校验一项服务检查
+func validateCheck(prefix string, c CheckConfig) []error {
+	var errs []error
+	if c.Name == "" {
+		errs = append(errs, fmt.Errorf("%s.name is required", prefix))
+	}
+	if c.Target == "" {
+		errs = append(errs, fmt.Errorf("%s.target is required", prefix))
+	}
+	if c.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("%s.timeout must be positive", prefix))
+	}
+
+	switch c.Type {
+	case CheckTCP:
+		if _, _, err := net.SplitHostPort(c.Target); c.Target != "" && err != nil {
+			errs = append(errs, fmt.Errorf("%s.target must be host:port: %s", prefix, c.Target))
+		}
+	case CheckHTTP:
+		if u, err := url.Parse(c.Target); c.Target != "" && (err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "") {
+			errs = append(errs, fmt.Errorf("%s.target is not a valid http(s) URL: %s", prefix, c.Target))
+		}
+		if c.ExpectStatus != 0 && (c.ExpectStatus < 100 || c.ExpectStatus > 599) {
+			errs = append(errs, fmt.Errorf("%s.expect_status is not a valid status code: %d", prefix, c.ExpectStatus))
+		}
+		if _, err := regexp.Compile(c.ExpectBody); err != nil {
+			errs = append(errs, fmt.Errorf("%s.expect_body: %v", prefix, err))
+		}
+	case CheckProcess, CheckFile:
+	default:
+		errs = append(errs, fmt.Errorf("%s.type must be %s, %s, %s or %s", prefix, CheckTCP, CheckHTTP, CheckProcess, CheckFile))
+	}
+
+	if c.MaxAge < 0 || c.MinSize < 0 || c.MaxSize < 0 {
+		errs = append(errs, fmt.Errorf("%s.max_age, min_size and max_size must not be negative", prefix))
+	}
+	if c.MaxSize > 0 && c.MinSize > c.MaxSize {
+		errs = append(errs, fmt.Errorf("%s.min_size must not be greater than max_size", prefix))
+	}
+	return errs
+}
+
+// TLSConfig 与服务器通信的TLS配置
+type TLSConfig struct {
+	CAFile             string `json:"ca_file"`              // 自定义CA证书，用于校验服务器
+	CertFile           string `json:"cert_file"`            // 客户端证书（mTLS）
+	KeyFile            string `json:"key_file"`             // 客户端私钥（mTLS）
+	ServerName         string `json:"server_name"`          // 覆盖校验使用的服务器名称
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"` // 跳过服务器证书校验，仅用于测试
 }
 
 type AgentConfig struct {
-	NodeName string `json:"node_name"`
-	Interval int    `json:"interval"` // 数据采集间隔（秒）
+	NodeName  string            `json:"node_name"`
+	Interval  int               `json:"interval"`   // 数据采集间隔（秒）
+	Labels    map[string]string `json:"labels"`     // 节点标签，如 {"env": "prod", "rack": "a3"}
+	SpoolSize int               `json:"spool_size"` // 发送失败时最多暂存的数据条数
+}
+
+// PullConfig 拉取模式，Agent提供HTTP接口由服务器定时拉取数据
+type PullConfig struct {
+	Enabled bool   `json:"enabled"`
+	Listen  string `json:"listen"` // 监听地址，如 0.0.0.0:9101
+	Token   string `json:"token"`  // 服务器拉取时需要携带的令牌，为空时不校验
+}
+
+// StatusConfig 本地状态接口，只允许监听本机地址
+type StatusConfig struct {
+	Enabled bool   `json:"enabled"`
+	Listen  string `json:"listen"` // 如 127.0.0.1:9102
+}
+
+// RemoteConfig 服务器集中下发的配置
+type RemoteConfig struct {
+	Enabled bool     `json:"enabled"`
+	Pinned  []string `json:"pinned"` // 以本地配置为准的配置项，如 "agent.interval" 或整个 "collector"
+}
+
+// UpdateConfig 从服务器下载新版本自动升级，需要在 systemd 等会自动重启的环境中运行
+type UpdateConfig struct {
+	Enabled   bool   `json:"enabled"`
+	Version   string `json:"version"`    // 目标版本，通常由服务器集中配置下发，为空时不升级
+	PublicKey string `json:"public_key"` // 发布文件签名公钥（base64），签名校验失败时不升级
+	Deadline  int    `json:"deadline"`   // 新版本需要在多少秒内上报成功，否则回滚到旧版本
+}
+
+// SystemdConfig 采集 systemd 单元的状态
+type SystemdConfig struct {
+	Units []string `json:"units"` // 单元名称，支持通配符（如 "nginx.service"、"miniPanel-*"），为空时不采集
 }
 
 type CollectorConfig struct {
@@ -32,28 +231,211 @@ func LoadConfig(configPath string) (*Config, error) {
 		return nil, err
 	}
 
-	var config Config
-	err = json.Unmarshal(data, &config)
+	// 在默认配置基础上覆盖，未配置的项保持默认值
+	config := DefaultConfig()
+	err = json.Unmarshal(data, config)
 	if err != nil {
 		return nil, err
 	}
 
-	return &config, nil
+	return config, nil
 }
 
 func DefaultConfig() *Config {
+	server := defaultServerConfig()
+	server.URL = "http://localhost:8080/api/metrics"
+
 	return &Config{
-		Server: ServerConfig{
-			URL: "http://localhost:8080/api/metrics",
-		},
+		Server:     server,
+		ServerMode: ServerModeFailover,
 		Agent: AgentConfig{
-			NodeName: "default-node",
-			Interval: 30,
+			NodeName:  "default-node",
+			Interval:  30,
+			Labels:    map[string]string{},
+			SpoolSize: 100,
 		},
 		Collector: CollectorConfig{
 			CPU:    true,
 			Memory: true,
 			Temp:   true,
 		},
+		Pull: PullConfig{
+			Listen: "0.0.0.0:9101",
+		},
+		Status: StatusConfig{
+			Listen: "127.0.0.1:9102",
+		},
+		Remote: RemoteConfig{
+			Enabled: true,
+		},
+		Update: UpdateConfig{
+			Deadline: 300,
+		},
+	}
+}
+
+// Redacted 返回隐藏了密钥的配置副本，用于展示
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	if redacted.Pull.Token != "" {
+		redacted.Pull.Token = "******"
+	}
+	return &redacted
+}
+
+// Validate 检查配置是否有效，返回全部错误
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Agent.NodeName == "" {
+		errs = append(errs, fmt.Errorf("agent.node_name is required"))
+	}
+	if c.Agent.Interval <= 0 {
+		errs = append(errs, fmt.Errorf("agent.interval must be positive"))
+	}
+	if c.Agent.SpoolSize < 0 {
+		errs = append(errs, fmt.Errorf("agent.spool_size must not be negative"))
 	}
-}
\ No newline at end of file
+	for key := range c.Agent.Labels {
+		if key == "" {
+			errs = append(errs, fmt.Errorf("agent.labels contains an empty key"))
+		}
+	}
+
+	if len(c.Destinations()) == 0 && !c.Pull.Enabled {
+		errs = append(errs, fmt.Errorf("server.url is required unless pull mode is enabled"))
+	}
+	if len(c.Servers) == 0 {
+		if c.Server.URL != "" {
+			errs = append(errs, validateServer("server", c.Server)...)
+		}
+	} else {
+		seen := map[string]bool{}
+		for i, s := range c.Servers {
+			prefix := fmt.Sprintf("servers[%d]", i)
+			if s.URL == "" {
+				errs = append(errs, fmt.Errorf("%s.url is required", prefix))
+				continue
+			}
+			if seen[s.URL] {
+				errs = append(errs, fmt.Errorf("%s.url is duplicated: %s", prefix, s.URL))
+			}
+			seen[s.URL] = true
+			errs = append(errs, validateServer(prefix, s)...)
+		}
+	}
+	if c.ServerMode != ServerModeFailover && c.ServerMode != ServerModeFanout {
+		errs = append(errs, fmt.Errorf("server_mode must be %s or %s", ServerModeFailover, ServerModeFanout))
+	}
+
+	names := map[string]bool{}
+	for i, check := range c.Checks {
+		prefix := fmt.Sprintf("checks[%d]", i)
+		if names[check.Name] {
+			errs = append(errs, fmt.Errorf("%s.name is duplicated: %s", prefix, check.Name))
+		}
+		names[check.Name] = true
+		errs = append(errs, validateCheck(prefix, check)...)
+	}
+
+	for i, unit := range c.Systemd.Units {
+		if _, err := path.Match(unit, ""); err != nil || unit == "" || unit[0] == '-' {
+			errs = append(errs, fmt.Errorf("systemd.units[%d] is not a valid unit pattern: %q", i, unit))
+		}
+	}
+
+	if c.Pull.Enabled {
+		if _, _, err := net.SplitHostPort(c.Pull.Listen); err != nil {
+			errs = append(errs, fmt.Errorf("pull.listen: %v", err))
+		}
+	}
+	if c.Status.Enabled {
+		if err := CheckLoopback(c.Status.Listen); err != nil {
+			errs = append(errs, fmt.Errorf("status.listen: %v", err))
+		}
+	}
+
+	if c.Update.Enabled {
+		if key, err := base64.StdEncoding.DecodeString(c.Update.PublicKey); err != nil || len(key) != ed25519.PublicKeySize {
+			errs = append(errs, fmt.Errorf("update.public_key must be a base64 ed25519 public key"))
+		}
+		if c.Update.Deadline <= 0 {
+			errs = append(errs, fmt.Errorf("update.deadline must be positive"))
+		}
+	}
+
+	for _, key := range c.Remote.Pinned {
+		if !isRemoteKey(key) {
+			errs = append(errs, fmt.Errorf("remote.pinned: %s cannot be set by the server", key))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateServer 检查一个服务器的配置，prefix 为错误信息中的配置项名称
+func validateServer(prefix string, s ServerConfig) []error {
+	var errs []error
+
+	u, err := url.Parse(s.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("%s.url is not a valid http(s) URL: %s", prefix, s.URL))
+	}
+	if s.Format != FormatAuto && s.Format != FormatJSON && s.Format != FormatProtobuf {
+		errs = append(errs, fmt.Errorf("%s.format must be %s, %s or %s", prefix, FormatAuto, FormatJSON, FormatProtobuf))
+	}
+	if s.GRPC != "" {
+		if host, port, err := net.SplitHostPort(s.GRPC); err != nil || host == "" || port == "" {
+			errs = append(errs, fmt.Errorf("%s.grpc must be host:port: %s", prefix, s.GRPC))
+		}
+	}
+	if s.Timeout <= 0 {
+		errs = append(errs, fmt.Errorf("%s.timeout must be positive", prefix))
+	}
+	if s.RetryCount < 0 {
+		errs = append(errs, fmt.Errorf("%s.retry_count must not be negative", prefix))
+	}
+	if s.RetryCount > 0 {
+		if s.RetryInterval <= 0 {
+			errs = append(errs, fmt.Errorf("%s.retry_interval must be positive", prefix))
+		}
+		if s.RetryMaxInterval < s.RetryInterval {
+			errs = append(errs, fmt.Errorf("%s.retry_max_interval must not be less than retry_interval", prefix))
+		}
+	}
+	if s.BreakerThreshold < 0 {
+		errs = append(errs, fmt.Errorf("%s.breaker_threshold must not be negative", prefix))
+	}
+	if s.BreakerThreshold > 0 && s.BreakerCooldown <= 0 {
+		errs = append(errs, fmt.Errorf("%s.breaker_cooldown must be positive", prefix))
+	}
+
+	tls := s.TLS
+	if (tls.CertFile == "") != (tls.KeyFile == "") {
+		errs = append(errs, fmt.Errorf("%s.tls.cert_file and key_file must be set together", prefix))
+	}
+	for _, file := range []string{tls.CAFile, tls.CertFile, tls.KeyFile} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			errs = append(errs, fmt.Errorf("%s.tls: %v", prefix, err))
+		}
+	}
+	return errs
+}
+
+// CheckLoopback 检查监听地址是否为本机地址
+func CheckLoopback(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("must use a loopback address, got %s", addr)
+}
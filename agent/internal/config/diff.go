@@ -0,0 +1,70 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// secretKeys 差异中不显示具体值的配置项
+var secretKeys = map[string]bool{
+	"pull.token": true,
+}
+
+// Diff 返回两份配置之间变化的配置项，如 "agent.interval: 30 -> 10"，按名称排序
+func Diff(old, new *Config) []string {
+	before, after := flatten(old), flatten(new)
+
+	keys := map[string]bool{}
+	for key := range before {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+
+	var changes []string
+	for key := range keys {
+		a, aok := before[key]
+		b, bok := after[key]
+		if aok == bok && a == b {
+			continue
+		}
+		switch {
+		case secretKeys[key]:
+			changes = append(changes, key+": (changed)")
+		case !aok:
+			changes = append(changes, fmt.Sprintf("%s: added %s", key, b))
+		case !bok:
+			changes = append(changes, fmt.Sprintf("%s: removed (was %s)", key, a))
+		default:
+			changes = append(changes, fmt.Sprintf("%s: %s -> %s", key, a, b))
+		}
+	}
+	sort.Strings(changes)
+	return changes
+}
+
+// flatten 把配置展开为 "section.key" 到 JSON 值的映射
+func flatten(c *Config) map[string]string {
+	data, _ := json.Marshal(c)
+	var tree map[string]any
+	json.Unmarshal(data, &tree)
+
+	out := map[string]string{}
+	var walk func(prefix string, v any)
+	walk = func(prefix string, v any) {
+		if m, ok := v.(map[string]any); ok {
+			for key, child := range m {
+				walk(prefix+"."+key, child)
+			}
+			return
+		}
+		value, _ := json.Marshal(v)
+		out[prefix] = string(value)
+	}
+	for key, v := range tree {
+		walk(key, v)
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+package config
+
+import "strings"
+
+// Remote 服务器下发的配置项，未设置的项使用本地配置
+type Remote struct {
+	Agent     *RemoteAgent     `json:"agent,omitempty"`
+	Collector *RemoteCollector `json:"collector,omitempty"`
+	Update    *RemoteUpdate    `json:"update,omitempty"`
+
+	// Checks 替换本地的全部服务检查，为空列表时不执行检查
+	Checks *[]CheckConfig `json:"checks,omitempty"`
+}
+
+// RemoteAgent 可下发的 agent 配置项
+type RemoteAgent struct {
+	Interval  *int `json:"interval,omitempty"`
+	SpoolSize *int `json:"spool_size,omitempty"`
+}
+
+// RemoteCollector 可下发的 collector 配置项
+type RemoteCollector struct {
+	CPU    *bool `json:"cpu,omitempty"`
+	Memory *bool `json:"memory,omitempty"`
+	Temp   *bool `json:"temp,omitempty"`
+}
+
+// RemoteUpdate 可下发的 update 配置项
+type RemoteUpdate struct {
+	Version *string `json:"version,omitempty"`
+}
+
+// remoteKeys 可由服务器下发的配置项
+var remoteKeys = []string{
+	"agent.interval",
+	"agent.spool_size",
+	"collector.cpu",
+	"collector.memory",
+	"collector.temp",
+	"update.version",
+	"checks",
+}
+
+// isRemoteKey 判断 key 是可下发的配置项或包含可下发配置项的配置段
+func isRemoteKey(key string) bool {
+	for _, k := range remoteKeys {
+		if k == key || strings.HasPrefix(k, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// pinned 判断配置项是否以本地配置为准
+func (c *Config) pinned(key string) bool {
+	for _, p := range c.Remote.Pinned {
+		if p == key || strings.HasPrefix(key, p+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// WithRemote 返回应用服务器配置后的副本，remote.pinned 中的配置项保持本地值
+func (c *Config) WithRemote(r *Remote) *Config {
+	out := *c
+	if r == nil || !c.Remote.Enabled {
+		return &out
+	}
+
+	setInt := func(key string, dst *int, v *int) {
+		if v != nil && !c.pinned(key) {
+			*dst = *v
+		}
+	}
+	setBool := func(key string, dst *bool, v *bool) {
+		if v != nil && !c.pinned(key) {
+			*dst = *v
+		}
+	}
+
+	if a := r.Agent; a != nil {
+		setInt("agent.interval", &out.Agent.Interval, a.Interval)
+		setInt("agent.spool_size", &out.Agent.SpoolSize, a.SpoolSize)
+	}
+	if col := r.Collector; col != nil {
+		setBool("collector.cpu", &out.Collector.CPU, col.CPU)
+		setBool("collector.memory", &out.Collector.Memory, col.Memory)
+		setBool("collector.temp", &out.Collector.Temp, col.Temp)
+	}
+	if u := r.Update; u != nil && u.Version != nil && !c.pinned("update.version") {
+		out.Update.Version = *u.Version
+	}
+	if r.Checks != nil && !c.pinned("checks") {
+		out.Checks = *r.Checks
+	}
+	return &out
+}
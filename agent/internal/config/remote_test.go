@@ -0,0 +1,46 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestWithRemoteChecks(t *testing.T) {
+	local := []CheckConfig{{Name: "nginx", Type: CheckTCP, Target: "127.0.0.1:80", Timeout: 5}}
+
+	tests := []struct {
+		name   string
+		remote string
+		pinned []string
+		want   []CheckConfig
+	}{
+		{"not set", `{"agent": {"interval": 10}}`, nil, local},
+		// 下发的检查整体替换本地检查，未填写的超时时间使用默认值
+		{"replaced", `{"checks": [{"name": "db", "type": "tcp", "target": "10.0.0.5:5432"}]}`, nil,
+			[]CheckConfig{{Name: "db", Type: CheckTCP, Target: "10.0.0.5:5432", Timeout: 5}}},
+		{"cleared", `{"checks": []}`, nil, []CheckConfig{}},
+		{"pinned", `{"checks": []}`, []string{"checks"}, local},
+	}
+	for _, tt := range tests {
+		var r Remote
+		if err := json.Unmarshal([]byte(tt.remote), &r); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		cfg := DefaultConfig()
+		cfg.Checks = local
+		cfg.Remote.Enabled = true
+		cfg.Remote.Pinned = tt.pinned
+
+		got := cfg.WithRemote(&r)
+		if !reflect.DeepEqual(got.Checks, tt.want) {
+			t.Errorf("%s: checks = %+v, want %+v", tt.name, got.Checks, tt.want)
+		}
+		if !reflect.DeepEqual(cfg.Checks, local) {
+			t.Errorf("%s: local config modified: %+v", tt.name, cfg.Checks)
+		}
+		if err := got.Validate(); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+}
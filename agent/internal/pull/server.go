@@ -0,0 +1,118 @@
+// Package pull 提供拉取模式的HTTP接口，服务器主动从Agent获取监控数据
+package pull
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"miniPanel-agent/internal/collector"
+	"miniPanel-agent/internal/version"
+	"miniPanel-agent/internal/wire"
+)
+
+// Server 保存最近一次采集的数据，供服务器拉取
+type Server struct {
+	mu       sync.RWMutex
+	nodeName string
+	token    string
+	latest   *collector.MetricsData
+	host     *collector.HostInfo
+	served   time.Time // 最近一次成功返回数据的时间
+}
+
+// NewServer 创建拉取接口，token 不为空时要求请求携带 Authorization: Bearer <token>
+func NewServer(nodeName, token string) *Server {
+	return &Server{nodeName: nodeName, token: token}
+}
+
+// SetAuth 更新节点名称和令牌，用于重新加载配置
+func (s *Server) SetAuth(nodeName, token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nodeName = nodeName
+	s.token = token
+}
+
+// Update 保存最新的采集数据，主机信息只在有变化时随数据提供，这里保留最近一次的
+func (s *Server) Update(metrics *collector.MetricsData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latest = metrics
+	if metrics.Host != nil {
+		s.host = metrics.Host
+	}
+}
+
+// ServeHTTP 返回最新的采集数据，始终携带主机信息
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	s.mu.RLock()
+	nodeName, token := s.nodeName, s.token
+	s.mu.RUnlock()
+
+	if token != "" {
+		expected := "Bearer " + token
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(expected)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	s.mu.RLock()
+	var metrics collector.MetricsData
+	ready := s.latest != nil
+	if ready {
+		metrics = *s.latest
+		metrics.Host = s.host
+	}
+	s.mu.RUnlock()
+
+	if !ready {
+		http.Error(w, "no metrics collected yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	// 与推送相同，附带协议版本和发送时间，服务器据此补全默认值和测量时钟偏差
+	now := time.Now()
+	data, err := wire.EncodeJSON(&metrics, now)
+	if err != nil {
+		http.Error(w, "failed to encode metrics", http.StatusInternalServerError)
+		return
+	}
+
+	s.mu.Lock()
+	s.served = now
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", wire.ContentTypeJSON)
+	w.Header().Set("Node-Name", nodeName)
+	w.Header().Set("Server", version.UserAgent())
+	w.Write(data)
+}
+
+// LastServed 最近一次成功返回数据的时间
+func (s *Server) LastServed() time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.served
+}
+
+// ListenAndServe 在 addr 上提供 /metrics 接口，出错时只记录日志
+func (s *Server) ListenAndServe(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", s)
+
+	log.Printf("拉取接口监听: %s", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Printf("拉取接口启动失败: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+// Package spool 在内存中暂存发送失败的监控数据，服务器恢复后补发
+package spool
+
+import (
+	"sync"
+
+	"miniPanel-agent/internal/collector"
+)
+
+// Spool 有界队列，写满后丢弃最旧的数据
+type Spool struct {
+	mu       sync.Mutex
+	items    []*collector.MetricsData
+	capacity int
+	dropped  uint64
+}
+
+// New 创建容量为 capacity 的队列，capacity 为 0 时不暂存
+func New(capacity int) *Spool {
+	return &Spool{capacity: capacity}
+}
+
+// Push 暂存一条数据，队列已满时丢弃最旧的一条
+func (s *Spool) Push(metrics *collector.MetricsData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.capacity <= 0 {
+		s.dropped++
+		return
+	}
+	if len(s.items) >= s.capacity {
+		s.items = s.items[1:]
+		s.dropped++
+	}
+	s.items = append(s.items, metrics)
+}
+
+// Peek 返回最旧的一条数据，队列为空时返回 nil
+func (s *Spool) Peek() *collector.MetricsData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.items) == 0 {
+		return nil
+	}
+	return s.items[0]
+}
+
+// Pop 移除最旧的一条数据
+func (s *Spool) Pop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.items) > 0 {
+		s.items[0] = nil
+		s.items = s.items[1:]
+	}
+}
+
+// Len 当前暂存的数据条数
+func (s *Spool) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.items)
+}
+
+// Capacity 队列容量
+func (s *Spool) Capacity() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.capacity
+}
+
+// Dropped 因队列已满被丢弃的数据条数
+func (s *Spool) Dropped() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.dropped
+}
+
+// Resize 修改容量，超出新容量的最旧数据被丢弃
+func (s *Spool) Resize(capacity int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.capacity = capacity
+	if capacity < 0 {
+		capacity = 0
+	}
+	if over := len(s.items) - capacity; over > 0 {
+		s.items = s.items[over:]
+		s.dropped += uint64(over)
+	}
+}
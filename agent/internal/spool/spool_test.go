@@ -0,0 +1,92 @@
+package spool
+
+import (
+	"testing"
+
+	"miniPanel-agent/internal/collector"
+)
+
+func item(mark float64) *collector.MetricsData {
+	return &collector.MetricsData{CPUPercent: mark}
+}
+
+// drain 依次取出全部数据的标记
+func drain(s *Spool) []float64 {
+	var out []float64
+	for m := s.Peek(); m != nil; m = s.Peek() {
+		out = append(out, m.CPUPercent)
+		s.Pop()
+	}
+	return out
+}
+
+func equal(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSpool(t *testing.T) {
+	tests := []struct {
+		name        string
+		capacity    int
+		push        int
+		want        []float64
+		wantDropped uint64
+	}{
+		{"empty", 3, 0, nil, 0},
+		{"below capacity", 3, 2, []float64{1, 2}, 0},
+		{"full drops oldest", 3, 5, []float64{3, 4, 5}, 2},
+		{"disabled", 0, 2, nil, 2},
+	}
+	for _, tt := range tests {
+		s := New(tt.capacity)
+		for i := 1; i <= tt.push; i++ {
+			s.Push(item(float64(i)))
+		}
+		if s.Len() != len(tt.want) || s.Dropped() != tt.wantDropped {
+			t.Errorf("%s: len/dropped = %d/%d, want %d/%d", tt.name, s.Len(), s.Dropped(), len(tt.want), tt.wantDropped)
+		}
+		if got := drain(s); !equal(got, tt.want) {
+			t.Errorf("%s: items = %v, want %v", tt.name, got, tt.want)
+		}
+		// Pop 空队列不出错
+		s.Pop()
+		if s.Peek() != nil || s.Len() != 0 {
+			t.Errorf("%s: not empty after drain", tt.name)
+		}
+	}
+}
+
+func TestResize(t *testing.T) {
+	tests := []struct {
+		name        string
+		capacity    int
+		want        []float64
+		wantDropped uint64
+	}{
+		{"grow", 10, []float64{1, 2, 3, 4}, 0},
+		{"shrink drops oldest", 2, []float64{3, 4}, 2},
+		{"disable", 0, nil, 4},
+		{"negative", -1, nil, 4},
+	}
+	for _, tt := range tests {
+		s := New(4)
+		for i := 1; i <= 4; i++ {
+			s.Push(item(float64(i)))
+		}
+		s.Resize(tt.capacity)
+		if s.Capacity() != tt.capacity || s.Dropped() != tt.wantDropped {
+			t.Errorf("%s: capacity/dropped = %d/%d, want %d/%d", tt.name, s.Capacity(), s.Dropped(), tt.capacity, tt.wantDropped)
+		}
+		if got := drain(s); !equal(got, tt.want) {
+			t.Errorf("%s: items = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+// Package update 下载、校验并替换Agent二进制文件。
+// 替换前保留旧版本，新版本未能在期限内上报成功或反复重启时回滚
+package update
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"miniPanel-agent/internal/client"
+)
+
+// 升级状态
+const (
+	StatePending = "pending" // 已替换为新版本，等待新版本上报成功
+	StateFailed  = "failed"  // 新版本已回滚，不再升级到该版本
+)
+
+const (
+	maxStarts   = 3         // 新版本在确认前最多启动的次数
+	maxSize     = 256 << 20 // 发布文件的最大大小
+	restartCode = 75        // 退出后由 systemd 重启时使用的退出码
+)
+
+// Marker 升级标记文件，保存在二进制文件旁边，新版本启动时读取
+type Marker struct {
+	State    string    `json:"state"`
+	From     string    `json:"from"`
+	To       string    `json:"to"`
+	Deadline time.Time `json:"deadline"`
+	Starts   int       `json:"starts"` // 新版本已启动的次数
+}
+
+// Updater 管理当前二进制文件的升级和回滚
+type Updater struct {
+	exe    string
+	marker *Marker
+}
+
+// New 创建升级管理，读取上次升级留下的标记
+func New() (*Updater, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return nil, err
+	}
+
+	u := &Updater{exe: exe}
+	data, err := os.ReadFile(u.markerPath())
+	if err == nil {
+		var m Marker
+		if err := json.Unmarshal(data, &m); err != nil {
+			log.Printf("忽略无效的升级标记 %s: %v", u.markerPath(), err)
+		} else {
+			u.marker = &m
+		}
+	}
+	return u, nil
+}
+
+func (u *Updater) markerPath() string { return u.exe + ".update" }
+func (u *Updater) prevPath() string   { return u.exe + ".prev" }
+
+// Marker 返回当前的升级标记，没有时为 nil
+func (u *Updater) Marker() *Marker {
+	if u.marker == nil {
+		return nil
+	}
+	m := *u.marker
+	return &m
+}
+
+// Startup 在启动时检查上次升级的状态，新版本反复重启时回滚，返回 true 表示需要重启
+func (u *Updater) Startup(current string) (bool, error) {
+	m := u.marker
+	if m == nil || m.State != StatePending {
+		return false, nil
+	}
+
+	// 运行的不是新版本，说明已被替换或回滚
+	if m.To != current {
+		m.State = StateFailed
+		return false, u.writeMarker()
+	}
+
+	m.Starts++
+	if m.Starts > maxStarts {
+		log.Printf("版本 %s 已启动 %d 次仍未上报成功", m.To, m.Starts-1)
+		if err := u.Rollback(); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return false, u.writeMarker()
+}
+
+// Pending 新版本正在等待确认
+func (u *Updater) Pending() bool {
+	return u.marker != nil && u.marker.State == StatePending
+}
+
+// Expired 新版本未能在期限内上报成功
+func (u *Updater) Expired(now time.Time) bool {
+	return u.Pending() && now.After(u.marker.Deadline)
+}
+
+// Failed 返回回滚过的版本，不再升级到该版本
+func (u *Updater) Failed() string {
+	if u.marker != nil && u.marker.State == StateFailed {
+		return u.marker.To
+	}
+	return ""
+}
+
+// Confirm 新版本已上报成功，删除旧版本和升级标记
+func (u *Updater) Confirm() error {
+	if err := os.Remove(u.prevPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(u.markerPath()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	u.marker = nil
+	return nil
+}
+
+// Rollback 记录失败的版本并恢复旧版本，成功后需要重启
+func (u *Updater) Rollback() error {
+	u.marker.State = StateFailed
+	if err := u.writeMarker(); err != nil {
+		return err
+	}
+	if err := os.Rename(u.prevPath(), u.exe); err != nil {
+		return fmt.Errorf("rollback: %v", err)
+	}
+	log.Printf("已回滚到版本 %s", u.marker.From)
+	return nil
+}
+
+// Apply 下载并校验目标版本，替换当前二进制文件并写入升级标记，之后需要重启
+func (u *Updater) Apply(c *client.Client, current, target, publicKey string, deadline time.Duration) error {
+	rel, err := c.FetchRelease(target, runtime.GOOS, runtime.GOARCH)
+	if err != nil {
+		return err
+	}
+	if rel.Size <= 0 || rel.Size > maxSize {
+		return fmt.Errorf("invalid release size: %d", rel.Size)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(u.exe), ".miniPanel-agent-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	var buf bytes.Buffer
+	if err := c.DownloadRelease(target, runtime.GOOS, runtime.GOARCH, rel.Size+1, io.MultiWriter(tmp, &buf)); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := verify(buf.Bytes(), rel, publicKey); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+
+	// 确认新文件能在本机运行并且版本正确
+	out, err := exec.Command(tmp.Name(), "-version").Output()
+	if err != nil {
+		return fmt.Errorf("new binary failed to run: %v", err)
+	}
+	if got := strings.TrimSpace(string(out)); got != target {
+		return fmt.Errorf("new binary reports version %q, expected %q", got, target)
+	}
+
+	// 保留旧版本用于回滚，再用重命名原子地替换
+	os.Remove(u.prevPath())
+	if err := os.Link(u.exe, u.prevPath()); err != nil {
+		if err := copyFile(u.exe, u.prevPath()); err != nil {
+			return fmt.Errorf("failed to keep previous binary: %v", err)
+		}
+	}
+
+	u.marker = &Marker{
+		State:    StatePending,
+		From:     current,
+		To:       target,
+		Deadline: time.Now().Add(deadline),
+	}
+	if err := u.writeMarker(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), u.exe); err != nil {
+		os.Remove(u.markerPath())
+		u.marker = nil
+		return err
+	}
+	return nil
+}
+
+// Restart 重启Agent：由 systemd 启动时退出并由 systemd 重启，否则直接执行新的二进制文件
+func (u *Updater) Restart() {
+	if os.Getenv("INVOCATION_ID") != "" {
+		log.Printf("退出以便 systemd 重启Agent")
+		os.Exit(restartCode)
+	}
+
+	log.Printf("重新执行 %s", u.exe)
+	if err := syscall.Exec(u.exe, os.Args, os.Environ()); err != nil {
+		log.Fatalf("重新执行失败: %v", err)
+	}
+}
+
+func (u *Updater) writeMarker() error {
+	data, err := json.MarshalIndent(u.marker, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := u.markerPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, u.markerPath())
+}
+
+// verify 校验大小、SHA256 摘要和 ed25519 签名
+func verify(data []byte, rel *client.Release, publicKey string) error {
+	if int64(len(data)) != rel.Size {
+		return fmt.Errorf("size mismatch: got %d bytes, expected %d", len(data), rel.Size)
+	}
+
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != strings.ToLower(rel.SHA256) {
+		return fmt.Errorf("sha256 mismatch")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid public key")
+	}
+	sig, err := base64.StdEncoding.DecodeString(rel.Signature)
+	if err != nil || !ed25519.Verify(ed25519.PublicKey(key), data, sig) {
+		return fmt.Errorf("signature verification failed")
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,162 @@
+package update
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"miniPanel-agent/internal/client"
+)
+
+func TestVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("miniPanel-agent binary")
+	sum := sha256.Sum256(data)
+	release := func() *client.Release {
+		return &client.Release{
+			SHA256:    hex.EncodeToString(sum[:]),
+			Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(priv, data)),
+			Size:      int64(len(data)),
+		}
+	}
+	key := base64.StdEncoding.EncodeToString(pub)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		modify  func(*client.Release)
+		key     string
+		wantErr string
+	}{
+		{"valid", data, nil, key, ""},
+		{"uppercase sha256", data, func(r *client.Release) { r.SHA256 = strings.ToUpper(r.SHA256) }, key, ""},
+		{"size mismatch", data, func(r *client.Release) { r.Size++ }, key, "size mismatch"},
+		{"tampered data", []byte("miniPanel-agent binarY"), nil, key, "sha256 mismatch"},
+		{"wrong sha256", data, func(r *client.Release) { r.SHA256 = strings.Repeat("0", 64) }, key, "sha256 mismatch"},
+		{"empty public key", data, nil, "", "invalid public key"},
+		{"short public key", data, nil, base64.StdEncoding.EncodeToString(pub[:16]), "invalid public key"},
+		{"other public key", data, nil, base64.StdEncoding.EncodeToString(otherPub), "signature verification failed"},
+		{"missing signature", data, func(r *client.Release) { r.Signature = "" }, key, "signature verification failed"},
+		{"malformed signature", data, func(r *client.Release) { r.Signature = "not base64!" }, key, "signature verification failed"},
+	}
+	for _, tt := range tests {
+		rel := release()
+		if tt.modify != nil {
+			tt.modify(rel)
+		}
+		err := verify(tt.data, rel, tt.key)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: unexpected error: %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+// newTestUpdater 在临时目录中模拟已替换为新版本的二进制文件和旧版本
+func newTestUpdater(t *testing.T, marker *Marker) *Updater {
+	t.Helper()
+	exe := filepath.Join(t.TempDir(), "miniPanel-agent")
+	if err := os.WriteFile(exe, []byte("new"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	u := &Updater{exe: exe, marker: marker}
+	if err := os.WriteFile(u.prevPath(), []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestStartup(t *testing.T) {
+	deadline := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		marker      *Marker
+		current     string
+		wantRestart bool
+		wantState   string
+		wantStarts  int
+		wantExe     string
+	}{
+		{"no marker", nil, "1.1.0", false, "", 0, "new"},
+		{"already failed", &Marker{State: StateFailed, From: "1.0.0", To: "1.1.0"}, "1.0.0", false, StateFailed, 0, "new"},
+		{"first start of new version", &Marker{State: StatePending, From: "1.0.0", To: "1.1.0", Deadline: deadline}, "1.1.0", false, StatePending, 1, "new"},
+		{"running another version", &Marker{State: StatePending, From: "1.0.0", To: "1.1.0", Starts: 1}, "1.0.0", false, StateFailed, 1, "new"},
+		{"crash loop rolls back", &Marker{State: StatePending, From: "1.0.0", To: "1.1.0", Starts: maxStarts}, "1.1.0", true, StateFailed, maxStarts + 1, "old"},
+	}
+	for _, tt := range tests {
+		u := newTestUpdater(t, tt.marker)
+		restart, err := u.Startup(tt.current)
+		if err != nil {
+			t.Fatalf("%s: Startup: %v", tt.name, err)
+		}
+		if restart != tt.wantRestart {
+			t.Errorf("%s: restart = %v, want %v", tt.name, restart, tt.wantRestart)
+		}
+		if m := u.Marker(); m != nil && (m.State != tt.wantState || m.Starts != tt.wantStarts) {
+			t.Errorf("%s: marker state/starts = %s/%d, want %s/%d", tt.name, m.State, m.Starts, tt.wantState, tt.wantStarts)
+		}
+		if data, _ := os.ReadFile(u.exe); string(data) != tt.wantExe {
+			t.Errorf("%s: binary = %q, want %q", tt.name, data, tt.wantExe)
+		}
+	}
+}
+
+func TestPendingAndConfirm(t *testing.T) {
+	deadline := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	u := newTestUpdater(t, &Marker{State: StatePending, From: "1.0.0", To: "1.1.0", Deadline: deadline})
+	if err := u.writeMarker(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !u.Pending() || u.Failed() != "" {
+		t.Fatalf("pending = %v, failed = %q", u.Pending(), u.Failed())
+	}
+	if u.Expired(deadline.Add(-time.Second)) || !u.Expired(deadline.Add(time.Second)) {
+		t.Error("Expired does not follow the deadline")
+	}
+
+	if err := u.Confirm(); err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+	if u.Pending() || u.Marker() != nil {
+		t.Error("marker kept after Confirm")
+	}
+	for _, path := range []string{u.prevPath(), u.markerPath()} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s not removed: %v", filepath.Base(path), err)
+		}
+	}
+}
+
+func TestRollback(t *testing.T) {
+	u := newTestUpdater(t, &Marker{State: StatePending, From: "1.0.0", To: "1.1.0"})
+	if err := u.Rollback(); err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if u.Failed() != "1.1.0" {
+		t.Errorf("Failed() = %q, want 1.1.0", u.Failed())
+	}
+	if data, _ := os.ReadFile(u.exe); string(data) != "old" {
+		t.Errorf("binary = %q, want old", data)
+	}
+	// 标记文件保留，重启后不再升级到失败的版本
+	if _, err := os.Stat(u.markerPath()); err != nil {
+		t.Errorf("marker file: %v", err)
+	}
+}
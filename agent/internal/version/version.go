@@ -0,0 +1,9 @@
+package version
+
+// Version Agent版本号，发布时通过 -ldflags "-X miniPanel-agent/internal/version.Version=x.y.z" 设置
+var Version = "1.0.0"
+
+// UserAgent 上报请求使用的 User-Agent
+func UserAgent() string {
+	return "MiniPanel-Agent/" + Version
+}
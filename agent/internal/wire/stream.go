@@ -0,0 +1,201 @@
+package wire
+
+import (
+	"fmt"
+	"time"
+
+	"miniPanel-agent/internal/collector"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// StreamMethod gRPC 流的完整方法名，格式定义见 proto/stream.proto
+const StreamMethod = "/minipanel.report.v1.AgentStream/Connect"
+
+// 服务器下发的命令
+const (
+	CommandCollectNow    = "collect_now"    // 立即采集并上报一次
+	CommandSendInventory = "send_inventory" // 下次上报时携带主机信息
+	CommandSyncConfig    = "sync_config"    // 集中配置已变化
+)
+
+// Codec gRPC 编解码器，直接收发已编码的消息：发送 []byte，接收到 *[]byte
+type Codec struct{}
+
+func (Codec) Marshal(v any) ([]byte, error) {
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("wire: cannot marshal %T", v)
+	}
+	return b, nil
+}
+
+func (Codec) Unmarshal(data []byte, v any) error {
+	p, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("wire: cannot unmarshal into %T", v)
+	}
+	*p = append((*p)[:0], data...)
+	return nil
+}
+
+// Name 与 protobuf 编解码器同名，服务器按 application/grpc+proto 处理
+func (Codec) Name() string {
+	return "proto"
+}
+
+// EncodeHello 编码连接后的第一条消息
+func EncodeHello(nodeName, agentVersion string) []byte {
+	var b []byte
+	b = appendString(b, 1, nodeName)
+	b = appendVarint(b, 2, ProtocolVersion)
+	b = appendString(b, 3, agentVersion)
+	return appendMessage(nil, 1, b)
+}
+
+// EncodeReportRequest 编码一条上报数据，服务器按 seq 回复
+func EncodeReportRequest(seq uint64, m *collector.MetricsData, sentAt time.Time) []byte {
+	var b []byte
+	b = appendVarint(b, 1, seq)
+	b = appendMessage(b, 2, EncodeReport(m, sentAt))
+	return appendMessage(nil, 2, b)
+}
+
+// ServerMessage 服务器发送的消息，Welcome、Ack 和 Command 只有一个不为 nil
+type ServerMessage struct {
+	Welcome *Welcome
+	Ack     *Ack
+	Command *Command
+}
+
+// Welcome 服务器接受连接后的第一条消息
+type Welcome struct {
+	NodeID        int
+	NodeName      string
+	ConfigVersion string
+}
+
+// Ack 服务器对一条上报数据的回复
+type Ack struct {
+	Seq           uint64
+	Status        int // 与 POST /api/metrics 的状态码相同
+	Message       string
+	RetryAfter    int // 秒
+	ConfigVersion string
+}
+
+// Command 服务器下发的命令
+type Command struct {
+	Type          string
+	ConfigVersion string // sync_config 命令携带的配置版本
+}
+
+// DecodeServerMessage 解析服务器发送的消息，不认识的消息返回空的 ServerMessage
+func DecodeServerMessage(b []byte) (*ServerMessage, error) {
+	msg := &ServerMessage{}
+	err := walk(b, func(num protowire.Number, typ protowire.Type, v value) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		var err error
+		switch num {
+		case 1:
+			msg.Welcome, err = decodeWelcome(v.bytes)
+		case 2:
+			msg.Ack, err = decodeAck(v.bytes)
+		case 3:
+			msg.Command, err = decodeCommand(v.bytes)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func decodeWelcome(b []byte) (*Welcome, error) {
+	w := &Welcome{}
+	err := walk(b, func(num protowire.Number, typ protowire.Type, v value) error {
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			w.NodeID = int(v.varint)
+		case num == 2 && typ == protowire.BytesType:
+			w.NodeName = string(v.bytes)
+		case num == 3 && typ == protowire.BytesType:
+			w.ConfigVersion = string(v.bytes)
+		}
+		return nil
+	})
+	return w, err
+}
+
+func decodeAck(b []byte) (*Ack, error) {
+	ack := &Ack{}
+	err := walk(b, func(num protowire.Number, typ protowire.Type, v value) error {
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			ack.Seq = v.varint
+		case num == 2 && typ == protowire.VarintType:
+			ack.Status = int(v.varint)
+		case num == 3 && typ == protowire.BytesType:
+			ack.Message = string(v.bytes)
+		case num == 4 && typ == protowire.VarintType:
+			ack.RetryAfter = int(v.varint)
+		case num == 5 && typ == protowire.BytesType:
+			ack.ConfigVersion = string(v.bytes)
+		}
+		return nil
+	})
+	return ack, err
+}
+
+func decodeCommand(b []byte) (*Command, error) {
+	cmd := &Command{}
+	err := walk(b, func(num protowire.Number, typ protowire.Type, v value) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			cmd.Type = string(v.bytes)
+		case num == 2 && typ == protowire.BytesType:
+			cmd.ConfigVersion = string(v.bytes)
+		}
+		return nil
+	})
+	return cmd, err
+}
+
+// value 一个字段的值，varint 类型使用 varint，bytes 类型使用 bytes
+type value struct {
+	varint uint64
+	bytes  []byte
+}
+
+// walk 依次处理消息中的字段，不认识的字段类型跳过
+func walk(b []byte, fn func(num protowire.Number, typ protowire.Type, v value) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		var v value
+		switch typ {
+		case protowire.VarintType:
+			v.varint, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			v.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if err := fn(num, typ, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
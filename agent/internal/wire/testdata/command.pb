@@ -0,0 +1,2 @@
+
+sync_configdef456
\ No newline at end of file
@@ -0,0 +1,2 @@
+
+web-01abc123
\ No newline at end of file
@@ -0,0 +1,193 @@
+// Package wire 上报数据和 gRPC 流消息的 protobuf 编码，格式定义见 proto/report.proto 和 proto/stream.proto
+package wire
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+
+	"miniPanel-agent/internal/collector"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ProtocolVersion 上报协议版本，JSON 和 protobuf 格式都携带。
+// 版本 1 起总是携带全部标签，版本 2 起总是携带全部服务检查结果，版本 3 起总是携带全部 systemd 单元
+const ProtocolVersion = 3
+
+// 上报数据的 Content-Type
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+)
+
+// Report 字段编号
+const (
+	reportProtocolVersion  = 1
+	reportCPUPercent       = 2
+	reportMemoryTotal      = 3
+	reportMemoryUsed       = 4
+	reportMemoryPercent    = 5
+	reportCPUTemp          = 6
+	reportTimestamp        = 7
+	reportLabels           = 8
+	reportHost             = 9
+	reportSentAt           = 10
+	reportChecks           = 11
+	reportUnits            = 12
+	reportUnitsUnavailable = 13
+)
+
+// CheckResult 字段编号
+const (
+	checkName      = 1
+	checkType      = 2
+	checkStatus    = 3
+	checkLatencyMs = 4
+	checkMessage   = 5
+)
+
+// UnitStatus 字段编号
+const (
+	unitName        = 1
+	unitLoadState   = 2
+	unitActiveState = 3
+	unitSubState    = 4
+	unitRestarts    = 5
+	unitMemoryBytes = 6
+)
+
+// HostInfo 字段编号
+const (
+	hostHostname        = 1
+	hostOS              = 2
+	hostPlatform        = 3
+	hostPlatformVersion = 4
+	hostKernelVersion   = 5
+	hostArch            = 6
+	hostCPUModel        = 7
+	hostCPUCores        = 8
+	hostMemoryTotal     = 9
+	hostBootTime        = 10
+	hostAgentVersion    = 11
+)
+
+// EncodeJSON 把监控数据编码为 JSON，附带协议版本和发送时间。推送和拉取接口使用同一格式
+func EncodeJSON(m *collector.MetricsData, sentAt time.Time) ([]byte, error) {
+	return json.Marshal(struct {
+		ProtocolVersion int       `json:"protocol_version"`
+		SentAt          time.Time `json:"sent_at"`
+		*collector.MetricsData
+	}{ProtocolVersion, sentAt, m})
+}
+
+// EncodeReport 把监控数据编码为 protobuf 格式，零值字段按 proto3 规则省略。
+// sentAt 为发送时间，服务器据此测量时钟偏差
+func EncodeReport(m *collector.MetricsData, sentAt time.Time) []byte {
+	var b []byte
+	b = appendVarint(b, reportProtocolVersion, ProtocolVersion)
+	b = appendDouble(b, reportCPUPercent, m.CPUPercent)
+	b = appendVarint(b, reportMemoryTotal, m.MemoryTotal)
+	b = appendVarint(b, reportMemoryUsed, m.MemoryUsed)
+	b = appendDouble(b, reportMemoryPercent, m.MemoryPercent)
+	b = appendDouble(b, reportCPUTemp, m.CPUTemp)
+
+	b = appendTimestamp(b, reportTimestamp, m.Timestamp)
+
+	// map 的每一项编码为 {1: key, 2: value}，按键排序使输出稳定
+	keys := make([]string, 0, len(m.Labels))
+	for key := range m.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var entry []byte
+		entry = appendString(entry, 1, key)
+		entry = appendString(entry, 2, m.Labels[key])
+		b = appendMessage(b, reportLabels, entry)
+	}
+
+	if h := m.Host; h != nil {
+		var hb []byte
+		hb = appendString(hb, hostHostname, h.Hostname)
+		hb = appendString(hb, hostOS, h.OS)
+		hb = appendString(hb, hostPlatform, h.Platform)
+		hb = appendString(hb, hostPlatformVersion, h.PlatformVersion)
+		hb = appendString(hb, hostKernelVersion, h.KernelVersion)
+		hb = appendString(hb, hostArch, h.Arch)
+		hb = appendString(hb, hostCPUModel, h.CPUModel)
+		hb = appendVarint(hb, hostCPUCores, uint64(h.CPUCores))
+		hb = appendVarint(hb, hostMemoryTotal, h.MemoryTotal)
+		hb = appendVarint(hb, hostBootTime, h.BootTime)
+		hb = appendString(hb, hostAgentVersion, h.AgentVersion)
+		// 主机信息存在时即使所有字段为空也要携带
+		b = protowire.AppendTag(b, reportHost, protowire.BytesType)
+		b = protowire.AppendBytes(b, hb)
+	}
+	b = appendTimestamp(b, reportSentAt, sentAt)
+
+	for _, r := range m.Checks {
+		var cb []byte
+		cb = appendString(cb, checkName, r.Name)
+		cb = appendString(cb, checkType, r.Type)
+		cb = appendString(cb, checkStatus, r.Status)
+		cb = appendDouble(cb, checkLatencyMs, r.LatencyMs)
+		cb = appendString(cb, checkMessage, r.Message)
+		b = appendMessage(b, reportChecks, cb)
+	}
+	for _, u := range m.Units {
+		var ub []byte
+		ub = appendString(ub, unitName, u.Name)
+		ub = appendString(ub, unitLoadState, u.LoadState)
+		ub = appendString(ub, unitActiveState, u.ActiveState)
+		ub = appendString(ub, unitSubState, u.SubState)
+		ub = appendVarint(ub, unitRestarts, uint64(u.Restarts))
+		ub = appendVarint(ub, unitMemoryBytes, u.MemoryBytes)
+		b = appendMessage(b, reportUnits, ub)
+	}
+	if m.UnitsUnavailable {
+		b = appendVarint(b, reportUnitsUnavailable, 1)
+	}
+	return b
+}
+
+// appendTimestamp 编码 google.protobuf.Timestamp，零值时省略
+func appendTimestamp(b []byte, num protowire.Number, t time.Time) []byte {
+	if t.IsZero() {
+		return b
+	}
+	var ts []byte
+	ts = appendVarint(ts, 1, uint64(t.Unix()))
+	ts = appendVarint(ts, 2, uint64(t.Nanosecond()))
+	return appendMessage(b, num, ts)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendDouble(b []byte, num protowire.Number, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
+
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
@@ -0,0 +1,186 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"miniPanel-agent/internal/checks"
+	"miniPanel-agent/internal/collector"
+)
+
+// testdata 中的文件与服务器 backend/internal/wire/testdata 中的相同：
+// Agent编码的消息由服务器的测试解析，服务器编码的消息由这里解析，保证两端格式一致
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+var (
+	testTimestamp = time.Date(2024, 3, 1, 8, 30, 15, 250000000, time.UTC)
+	testSentAt    = time.Date(2024, 3, 1, 8, 30, 16, 0, time.UTC)
+)
+
+func testReport() *collector.MetricsData {
+	return &collector.MetricsData{
+		CPUPercent:    37.5,
+		MemoryTotal:   8 << 30,
+		MemoryUsed:    3 << 30,
+		MemoryPercent: 37.5,
+		CPUTemp:       51.25,
+		Timestamp:     testTimestamp,
+		Labels:        map[string]string{"role": "web", "env": "prod"},
+		Host: &collector.HostInfo{
+			Hostname:        "web-01",
+			OS:              "linux",
+			Platform:        "debian",
+			PlatformVersion: "12.5",
+			KernelVersion:   "6.1.0-18-amd64",
+			Arch:            "x86_64",
+			CPUModel:        "Intel(R) Xeon(R) CPU",
+			CPUCores:        4,
+			MemoryTotal:     8 << 30,
+			BootTime:        1709251200,
+			AgentVersion:    "1.4.0",
+		},
+		Checks: []checks.Result{
+			{Name: "nginx", Type: "http", Status: "ok", LatencyMs: 12.5},
+			{Name: "db", Type: "tcp", Status: "failed", Message: "connection refused"},
+		},
+		Units: []collector.UnitStatus{
+			{Name: "nginx.service", LoadState: "loaded", ActiveState: "active", SubState: "running", Restarts: 2, MemoryBytes: 52428800},
+			{Name: "backup.service", LoadState: "loaded", ActiveState: "failed", SubState: "failed"},
+		},
+	}
+}
+
+// golden 比较编码结果与 testdata 中的文件，-update 时重写文件
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s: encoding changed\n got %x\nwant %x", name, got, want)
+	}
+}
+
+func TestEncodeReport(t *testing.T) {
+	golden(t, "report.pb", EncodeReport(testReport(), testSentAt))
+
+	// 标签按键排序，多次编码结果相同
+	if !bytes.Equal(EncodeReport(testReport(), testSentAt), EncodeReport(testReport(), testSentAt)) {
+		t.Error("encoding is not deterministic")
+	}
+}
+
+func TestEncodeReportZero(t *testing.T) {
+	// 零值字段全部省略，只剩协议版本
+	got := EncodeReport(&collector.MetricsData{}, time.Time{})
+	want := []byte{0x08, ProtocolVersion}
+	if !bytes.Equal(got, want) {
+		t.Errorf("EncodeReport(zero) = %x, want %x", got, want)
+	}
+
+	// 主机信息存在时即使全部为空也要携带
+	got = EncodeReport(&collector.MetricsData{Host: &collector.HostInfo{}}, time.Time{})
+	want = []byte{0x08, ProtocolVersion, 0x4a, 0x00}
+	if !bytes.Equal(got, want) {
+		t.Errorf("EncodeReport(empty host) = %x, want %x", got, want)
+	}
+
+	got = EncodeReport(&collector.MetricsData{UnitsUnavailable: true}, time.Time{})
+	want = []byte{0x08, ProtocolVersion, 0x68, 0x01}
+	if !bytes.Equal(got, want) {
+		t.Errorf("EncodeReport(units unavailable) = %x, want %x", got, want)
+	}
+}
+
+func TestEncodeStream(t *testing.T) {
+	golden(t, "hello.pb", EncodeHello("web-01", "1.4.0"))
+	golden(t, "report_request.pb", EncodeReportRequest(42, testReport(), testSentAt))
+}
+
+func TestDecodeServerMessage(t *testing.T) {
+	tests := []struct {
+		file string
+		want ServerMessage
+	}{
+		{"welcome.pb", ServerMessage{Welcome: &Welcome{NodeID: 7, NodeName: "web-01", ConfigVersion: "abc123"}}},
+		{"ack.pb", ServerMessage{Ack: &Ack{Seq: 42, Status: 429, Message: "Server is busy, retry later", RetryAfter: 5, ConfigVersion: "abc123"}}},
+		{"command.pb", ServerMessage{Command: &Command{Type: CommandSyncConfig, ConfigVersion: "def456"}}},
+	}
+	for _, tt := range tests {
+		data, err := os.ReadFile(filepath.Join("testdata", tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg, err := DecodeServerMessage(data)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.file, err)
+		}
+		got, _ := json.Marshal(msg)
+		want, _ := json.Marshal(tt.want)
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: decoded %s, want %s", tt.file, got, want)
+		}
+	}
+}
+
+func TestDecodeServerMessageErrors(t *testing.T) {
+	// 不认识的字段被忽略
+	msg, err := DecodeServerMessage([]byte{0x20, 0x01})
+	if err != nil || msg.Welcome != nil || msg.Ack != nil || msg.Command != nil {
+		t.Errorf("unknown field: %+v, %v", msg, err)
+	}
+	// 截断的消息
+	if _, err := DecodeServerMessage([]byte{0x0a, 0x05, 0x08}); err == nil {
+		t.Error("truncated message decoded without error")
+	}
+}
+
+func TestEncodeJSON(t *testing.T) {
+	data, err := EncodeJSON(testReport(), testSentAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["protocol_version"] != float64(ProtocolVersion) {
+		t.Errorf("protocol_version = %v, want %d", got["protocol_version"], ProtocolVersion)
+	}
+	if got["sent_at"] != testSentAt.Format(time.RFC3339Nano) {
+		t.Errorf("sent_at = %v, want %s", got["sent_at"], testSentAt.Format(time.RFC3339Nano))
+	}
+	for _, key := range []string{"cpu_percent", "timestamp", "labels", "host", "checks", "units"} {
+		if _, ok := got[key]; !ok {
+			t.Errorf("missing field %s", key)
+		}
+	}
+}
+
+func TestCodec(t *testing.T) {
+	var c Codec
+	data, err := c.Marshal([]byte{1, 2, 3})
+	if err != nil || !bytes.Equal(data, []byte{1, 2, 3}) {
+		t.Errorf("Marshal = %x, %v", data, err)
+	}
+	if _, err := c.Marshal("not bytes"); err == nil {
+		t.Error("Marshal accepted a string")
+	}
+	var out []byte
+	if err := c.Unmarshal([]byte{4, 5}, &out); err != nil || !bytes.Equal(out, []byte{4, 5}) {
+		t.Errorf("Unmarshal = %x, %v", out, err)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"miniPanel/internal/backup"
+	"miniPanel/internal/config"
+	"miniPanel/internal/database"
+	"miniPanel/internal/release"
+)
+
+// runCommand 执行命令行子命令
+func runCommand(cfg *config.Config, args []string) error {
+	switch args[0] {
+	case "migrate":
+		return runMigrate(cfg, args[1:])
+	case "backup":
+		return runBackup(cfg, args[1:])
+	case "restore":
+		return runRestore(cfg, args[1:])
+	case "release":
+		return runRelease(args[1:])
+	default:
+		return fmt.Errorf("unknown command: %s", args[0])
+	}
+}
+
+// runMigrate 查看或执行数据库迁移：migrate status | migrate up
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: migrate status|up")
+	}
+
+	db, err := database.Connect(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "status":
+		status, err := db.MigrationStatus()
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, m := range status {
+			state := "pending"
+			if m.Applied {
+				state = "applied"
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", m.Version, m.Name, state, m.AppliedAt)
+		}
+		return w.Flush()
+
+	case "up":
+		if err := db.Migrate(cfg.Database.BackupBeforeMigrate); err != nil {
+			return err
+		}
+		version, err := db.SchemaVersion()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Database schema is at version %d\n", version)
+		return nil
+
+	default:
+		return fmt.Errorf("usage: migrate status|up")
+	}
+}
+
+// runBackup 生成数据库快照，未指定输出文件时写入备份目录并轮转
+func runBackup(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	output := fs.String("o", "", "输出文件路径，默认写入配置的备份目录")
+	compress := fs.Bool("gzip", cfg.Backup.Compress, "使用 gzip 压缩")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db, err := database.Connect(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if *output != "" {
+		if err := db.Backup(*output, *compress); err != nil {
+			return err
+		}
+		fmt.Printf("Backup written to %s\n", *output)
+		return nil
+	}
+
+	backupCfg := cfg.Backup
+	backupCfg.Compress = *compress
+	info, err := backup.NewManager(db, backupCfg).Create()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Backup written to %s (%d bytes)\n", info.Name, info.Size)
+	return nil
+}
+
+// runRestore 用备份替换数据库，需先停止后端服务
+func runRestore(cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: restore <backup file>")
+	}
+
+	version, err := database.Restore(cfg.Database, args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Database restored from %s (schema version %d)\n", args[0], version)
+	if version < database.LatestSchemaVersion() {
+		fmt.Printf("Schema will be migrated to version %d on next start or with 'migrate up'\n", database.LatestSchemaVersion())
+	}
+	return nil
+}
+
+// runRelease 生成签名密钥或对Agent发布文件签名：release keygen -o key | release sign -key key <file>
+func runRelease(args []string) error {
+	usage := fmt.Errorf("usage: release keygen -o <private key file> | release sign -key <private key file> <binary>")
+	if len(args) == 0 {
+		return usage
+	}
+
+	switch args[0] {
+	case "keygen":
+		fs := flag.NewFlagSet("release keygen", flag.ContinueOnError)
+		output := fs.String("o", "", "私钥输出文件")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *output == "" {
+			return usage
+		}
+
+		publicKey, privateKey, err := release.GenerateKey()
+		if err != nil {
+			return err
+		}
+		// O_EXCL 避免覆盖已有的私钥
+		f, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(f, privateKey); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		fmt.Printf("Private key written to %s\n", *output)
+		fmt.Printf("Public key: %s\n", publicKey)
+		return nil
+
+	case "sign":
+		fs := flag.NewFlagSet("release sign", flag.ContinueOnError)
+		keyFile := fs.String("key", "", "私钥文件")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *keyFile == "" || fs.NArg() != 1 {
+			return usage
+		}
+
+		privateKey, err := os.ReadFile(*keyFile)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		signature, err := release.Sign(string(privateKey), data)
+		if err != nil {
+			return err
+		}
+		fmt.Println(signature)
+		return nil
+
+	default:
+		return usage
+	}
+}
@@ -1,39 +1,251 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
 	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"miniPanel/internal/alerts"
+	"miniPanel/internal/backup"
 	"miniPanel/internal/config"
 	"miniPanel/internal/database"
 	"miniPanel/internal/handlers"
+	"miniPanel/internal/ingest"
+	"miniPanel/internal/liveness"
+	"miniPanel/internal/scrape"
+	"miniPanel/internal/tlsutil"
+	"miniPanel/internal/wire"
 
 	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
 )
 
 func main() {
+	// 命令行参数
+	configPath := flag.String("config", "/etc/miniPanel/backend.json", "配置文件路径")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-config path] [command]\n\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Commands:\n  migrate status   show database migration status\n  migrate up       apply pending migrations\n  backup [-o file] [-gzip]\n                   write a consistent database snapshot\n  restore <file>   replace the database with a backup (server must be stopped)\n  release keygen -o <key>\n                   generate an agent release signing key\n  release sign -key <key> <binary>\n                   print the signature of an agent binary\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
 	// 加载配置
-	cfg := config.DefaultConfig()
+	cfg := loadConfig(*configPath)
+
+	// 子命令，如 migrate status
+	if flag.NArg() > 0 {
+		if err := runCommand(cfg, flag.Args()); err != nil {
+			log.Fatalf("%v", err)
+		}
+		return
+	}
 
 	// 初始化数据库
-	db, err := database.NewDB(cfg.Database.Path)
+	db, err := database.Open(cfg.Database)
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer db.Close()
 
+	// 备份管理
+	backups := backup.NewManager(db, cfg.Backup)
+
+	// 监控数据异步写入
+	writer := ingest.NewWriter(db, cfg.Ingest)
+	go writer.Run()
+
+	// 告警规则
+	alerter, err := alerts.New(cfg.Alerts)
+	if err != nil {
+		log.Fatalf("Invalid alert rules: %v", err)
+	}
+
 	// 初始化处理器
-	h := handlers.NewHandler(db, cfg.Auth.JWTSecret)
+	h := handlers.NewHandler(db, cfg, backups, writer, alerter)
+
+	// 预热最近监控数据的缓存，失败时未加载的节点查询数据库
+	if err := h.WarmCache(); err != nil {
+		log.Printf("Failed to warm metrics cache: %v", err)
+	}
 
 	// 设置Gin模式
 	gin.SetMode(gin.ReleaseMode)
 
-	// 创建路由
+	r := setupRouter(h)
+
+	addr := cfg.Server.Host + ":" + cfg.Server.Port
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: r,
+	}
+
+	// 监听系统信号
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+
+	stop := make(chan struct{})
+	go backups.Run(stop)
+	go liveness.Run(db, cfg.Liveness, stop)
+
+	// 拉取模式
+	scraper, err := scrape.NewScraper(db, h, cfg.Scrape)
+	if err != nil {
+		log.Fatalf("Failed to create scraper: %v", err)
+	}
+	go scraper.Run(stop)
+
+	var reloader *tlsutil.CertReloader
+	var redirectSrv *http.Server
+
+	if cfg.Server.TLS.Enabled {
+		reloader, err = setupTLS(&cfg.Server.TLS)
+		if err != nil {
+			log.Fatalf("Failed to set up TLS: %v", err)
+		}
+		srv.TLSConfig, err = buildTLSConfig(&cfg.Server.TLS, reloader)
+		if err != nil {
+			log.Fatalf("Failed to set up TLS: %v", err)
+		}
+
+		if cfg.Server.TLS.ReloadInterval > 0 {
+			go reloader.Watch(time.Duration(cfg.Server.TLS.ReloadInterval)*time.Second, stop)
+		}
+
+		if cfg.Server.TLS.RedirectHTTP {
+			redirectSrv = newRedirectServer(cfg.Server.Host+":"+cfg.Server.TLS.HTTPPort, cfg.Server.Port)
+			go func() {
+				log.Printf("HTTP redirect server listening on %s", redirectSrv.Addr)
+				if err := redirectSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					log.Printf("HTTP redirect server stopped: %v", err)
+				}
+			}()
+		}
+	}
+
+	// 启动服务器
+	go func() {
+		log.Printf("MiniPanel server starting on %s (tls: %v)", addr, cfg.Server.TLS.Enabled)
+		log.Printf("Default admin credentials: admin/admin123")
+
+		var err error
+		if cfg.Server.TLS.Enabled {
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	// Agent gRPC 流
+	var grpcSrv *grpc.Server
+	if cfg.GRPC.Enabled {
+		lis, err := net.Listen("tcp", cfg.GRPC.Listen)
+		if err != nil {
+			log.Fatalf("Failed to listen for gRPC: %v", err)
+		}
+		grpcSrv = newGRPCServer(h, srv.TLSConfig)
+		go func() {
+			log.Printf("gRPC stream server listening on %s", cfg.GRPC.Listen)
+			if err := grpcSrv.Serve(lis); err != nil {
+				log.Printf("gRPC stream server stopped: %v", err)
+			}
+		}()
+	}
+
+	for sig := range sigChan {
+		if sig == syscall.SIGHUP {
+			if reloader == nil {
+				continue
+			}
+			if err := reloader.Reload(); err != nil {
+				log.Printf("TLS certificate reload failed, keeping previous certificate: %v", err)
+			} else {
+				log.Printf("TLS certificate reloaded")
+			}
+			continue
+		}
+
+		log.Printf("Received signal %v, shutting down...", sig)
+		break
+	}
+
+	close(stop)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if redirectSrv != nil {
+		redirectSrv.Shutdown(ctx)
+	}
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("Server shutdown error: %v", err)
+	}
+	if grpcSrv != nil {
+		// 流连接不会自行结束，直接断开，Agent重连或改用 HTTP 上报
+		grpcSrv.Stop()
+	}
+
+	// 写入队列中剩余的数据
+	writer.Close()
+}
+
+// newGRPCServer 创建Agent gRPC 流服务器，tlsConfig 为 nil 时不加密。
+// 服务器定期探测连接，Agent异常断开时及时将节点标记为离线
+func newGRPCServer(h *handlers.Handler, tlsConfig *tls.Config) *grpc.Server {
+	opts := []grpc.ServerOption{
+		grpc.ForceServerCodec(wire.Codec{}),
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:    30 * time.Second,
+			Timeout: 10 * time.Second,
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             10 * time.Second,
+			PermitWithoutStream: true,
+		}),
+	}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	s := grpc.NewServer(opts...)
+	s.RegisterService(h.StreamServiceDesc(), h)
+	return s
+}
+
+// loadConfig 加载配置文件，文件不存在时使用默认配置
+func loadConfig(path string) *config.Config {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		log.Printf("Config file %s not found, using defaults", path)
+		return config.DefaultConfig()
+	}
+
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	return cfg
+}
+
+// setupRouter 注册中间件和路由
+func setupRouter(h *handlers.Handler) *gin.Engine {
 	r := gin.Default()
 
 	// CORS中间件
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, Node-Name")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, Node-Name, X-API-Key")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -47,16 +259,69 @@ func main() {
 	public := r.Group("/api")
 	{
 		public.POST("/login", h.Login)
-		public.POST("/metrics", h.ReceiveMetrics) // Agent上报数据接口
+		public.POST("/metrics", h.ReceiveMetrics)                               // Agent上报数据接口
+		public.GET("/metrics", h.CheckAgent)                                    // Agent检查连接和认证
+		public.GET("/metrics/config", h.GetAgentConfig)                         // Agent获取集中配置
+		public.GET("/metrics/releases/:version", h.GetAgentRelease)             // Agent获取升级文件信息
+		public.GET("/metrics/releases/:version/binary", h.DownloadAgentRelease) // Agent下载升级文件
 	}
 
 	// 需要认证的路由
 	auth := r.Group("/api")
 	auth.Use(h.JWTMiddleware())
 	{
-		auth.GET("/nodes", h.GetNodes)
-		auth.GET("/metrics/realtime", h.GetRealTimeMetrics)
-		auth.GET("/metrics/history", h.GetHistoryMetrics)
+		read := auth.Group("", h.RequireScope(handlers.ScopeMetricsRead))
+		read.GET("/overview", h.GetOverview)
+		read.GET("/nodes", h.GetNodes)
+		read.GET("/nodes/:id", h.GetNode)
+		read.GET("/nodes/:id/events", h.GetNodeEvents)
+		read.GET("/nodes/:id/checks", h.GetNodeChecks)
+		read.GET("/nodes/:id/checks/events", h.GetCheckEvents)
+		read.GET("/checks", h.GetChecks)
+		read.GET("/nodes/:id/units", h.GetNodeUnits)
+		read.GET("/nodes/:id/units/events", h.GetUnitEvents)
+		read.GET("/units", h.GetUnits)
+		read.GET("/metrics/realtime", h.GetRealTimeMetrics)
+		read.GET("/metrics/history", h.GetHistoryMetrics)
+		read.GET("/groups", h.GetGroups)
+		read.GET("/targets", h.GetScrapeTargets)
+		read.GET("/profiles", h.GetConfigProfiles)
+		read.GET("/nodes/:id/config", h.GetNodeConfig)
+		read.GET("/streams", h.GetStreams)
+
+		// 节点管理
+		write := auth.Group("", h.RequireScope(handlers.ScopeNodesWrite))
+		write.PUT("/nodes/:id", h.RenameNode)
+		write.DELETE("/nodes/:id", h.DeleteNode)
+		write.PUT("/nodes/:id/labels", h.SetNodeLabels)
+		write.POST("/nodes/:id/archive", h.ArchiveNode)
+		write.POST("/nodes/:id/decommission", h.DecommissionNode)
+		write.POST("/nodes/:id/activate", h.ActivateNode)
+		write.POST("/nodes/:id/merge", h.MergeNodes)
+		write.POST("/nodes/:id/commands", h.SendNodeCommand)
+		write.POST("/groups", h.CreateGroup)
+		write.DELETE("/groups/:id", h.DeleteGroup)
+		write.POST("/targets", h.CreateScrapeTarget)
+		write.DELETE("/targets/:id", h.DeleteScrapeTarget)
+		write.POST("/profiles", h.CreateConfigProfile)
+		write.PUT("/profiles/:id", h.UpdateConfigProfile)
+		write.DELETE("/profiles/:id", h.DeleteConfigProfile)
+
+		// API密钥管理
+		keys := auth.Group("/keys", h.RequireScope(handlers.ScopeAdmin))
+		keys.GET("", h.GetAPIKeys)
+		keys.POST("", h.CreateAPIKey)
+		keys.DELETE("/:id", h.DeleteAPIKey)
+
+		// 管理接口
+		admin := auth.Group("/admin", h.RequireScope(handlers.ScopeAdmin))
+		admin.GET("/backups", h.GetBackups)
+		admin.POST("/backups", h.CreateBackup)
+		admin.GET("/backups/:name", h.DownloadBackup)
+		admin.GET("/ingest", h.GetIngestStats)
+		admin.GET("/releases", h.GetReleases)
+		admin.POST("/releases", h.UploadRelease)
+		admin.DELETE("/releases/:id", h.DeleteRelease)
 	}
 
 	// 静态文件服务（用于前端）
@@ -64,12 +329,62 @@ func main() {
 	r.StaticFile("/", "./static/index.html")
 	r.StaticFile("/favicon.ico", "./static/favicon.ico")
 
-	// 启动服务器
-	addr := cfg.Server.Host + ":" + cfg.Server.Port
-	log.Printf("MiniPanel server starting on %s", addr)
-	log.Printf("Default admin credentials: admin/admin123")
+	return r
+}
+
+// setupTLS 准备证书，需要时生成自签名证书
+func setupTLS(cfg *config.TLSConfig) (*tlsutil.CertReloader, error) {
+	if _, err := os.Stat(cfg.CertFile); os.IsNotExist(err) && cfg.SelfSigned {
+		log.Printf("Certificate %s not found, generating self-signed certificate", cfg.CertFile)
+		if err := tlsutil.GenerateSelfSigned(cfg.CertFile, cfg.KeyFile, cfg.SelfSignedHost); err != nil {
+			return nil, err
+		}
+	}
+
+	return tlsutil.NewCertReloader(cfg.CertFile, cfg.KeyFile)
+}
+
+// buildTLSConfig 构建服务端TLS配置，包括可选的客户端证书校验
+func buildTLSConfig(cfg *config.TLSConfig, reloader *tlsutil.CertReloader) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	clientAuth, err := tlsutil.ClientAuthType(cfg.ClientAuth)
+	if err != nil {
+		return nil, err
+	}
+	if clientAuth == tls.NoClientCert {
+		return tlsConfig, nil
+	}
+
+	if cfg.ClientCAFile == "" {
+		return nil, fmt.Errorf("client_ca_file is required when client_auth is %s", cfg.ClientAuth)
+	}
+	pool, err := tlsutil.LoadCAPool(cfg.ClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig.ClientAuth = clientAuth
+	tlsConfig.ClientCAs = pool
+	return tlsConfig, nil
+}
 
-	if err := r.Run(addr); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+// newRedirectServer 创建将HTTP请求重定向到HTTPS端口的服务
+func newRedirectServer(addr, httpsPort string) *http.Server {
+	return &http.Server{
+		Addr: addr,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			host := req.Host
+			if h, _, err := net.SplitHostPort(host); err == nil {
+				host = h
+			}
+			if httpsPort != "443" {
+				host = net.JoinHostPort(host, httpsPort)
+			}
+			http.Redirect(w, req, "https://"+host+req.URL.RequestURI(), http.StatusMovedPermanently)
+		}),
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,150 @@
+// Package alerts 告警规则：服务检查和 systemd 单元状态变化时，按节点标签和名称匹配规则并发送通知
+package alerts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"miniPanel/internal/config"
+	"miniPanel/internal/labels"
+)
+
+// 规则类型
+const (
+	KindCheck = "check"
+	KindUnit  = "unit"
+)
+
+// 告警条件
+const (
+	ConditionFailed    = "failed"    // 检查失败或单元进入 failed 状态
+	ConditionRecovered = "recovered" // 检查恢复正常
+	ConditionRestarted = "restarted" // 单元被 systemd 自动重启
+)
+
+// Event 一次状态变化，规则匹配后作为告警内容发送到 webhook
+type Event struct {
+	Rule      string            `json:"rule"`
+	NodeID    int               `json:"node_id"`
+	NodeName  string            `json:"node_name"`
+	Labels    map[string]string `json:"labels"`
+	Kind      string            `json:"kind"`
+	Name      string            `json:"name"`
+	Condition string            `json:"condition"`
+	Message   string            `json:"message"`
+	Time      time.Time         `json:"time"`
+}
+
+type rule struct {
+	config.AlertRule
+	selector *labels.Selector
+}
+
+// Engine 已校验的告警规则
+type Engine struct {
+	rules  []rule
+	client *http.Client
+}
+
+// New 校验并创建告警规则，规则有误时返回错误
+func New(cfg config.AlertsConfig) (*Engine, error) {
+	e := &Engine{
+		client: &http.Client{Timeout: time.Duration(cfg.WebhookTimeout) * time.Second},
+	}
+	names := make(map[string]bool)
+	for i, r := range cfg.Rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("alert rule %d: name is required", i)
+		}
+		if names[r.Name] {
+			return nil, fmt.Errorf("alert rule %s: duplicate name", r.Name)
+		}
+		names[r.Name] = true
+
+		switch {
+		case r.Kind == KindCheck && (r.Condition == ConditionFailed || r.Condition == ConditionRecovered):
+		case r.Kind == KindUnit && (r.Condition == ConditionFailed || r.Condition == ConditionRestarted):
+		case r.Kind != KindCheck && r.Kind != KindUnit:
+			return nil, fmt.Errorf("alert rule %s: invalid kind %q", r.Name, r.Kind)
+		default:
+			return nil, fmt.Errorf("alert rule %s: invalid condition %q for kind %s", r.Name, r.Condition, r.Kind)
+		}
+
+		if _, err := path.Match(r.Match, ""); err != nil {
+			return nil, fmt.Errorf("alert rule %s: invalid match pattern %q", r.Name, r.Match)
+		}
+		sel, err := labels.Parse(r.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("alert rule %s: %v", r.Name, err)
+		}
+		if r.Webhook != "" {
+			u, err := url.Parse(r.Webhook)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return nil, fmt.Errorf("alert rule %s: invalid webhook %q", r.Name, r.Webhook)
+			}
+		}
+		e.rules = append(e.rules, rule{AlertRule: r, selector: sel})
+	}
+	return e, nil
+}
+
+// Empty 是否没有配置任何规则
+func (e *Engine) Empty() bool {
+	return e == nil || len(e.rules) == 0
+}
+
+// Evaluate 返回匹配 ev 的全部规则对应的告警，并异步发送配置了 webhook 的告警。
+// ev.Labels 为节点的当前标签
+func (e *Engine) Evaluate(ev Event) []Event {
+	if e.Empty() {
+		return nil
+	}
+
+	var fired []Event
+	for _, r := range e.rules {
+		if r.Kind != ev.Kind || r.Condition != ev.Condition {
+			continue
+		}
+		if r.Match != "" {
+			if ok, _ := path.Match(r.Match, ev.Name); !ok {
+				continue
+			}
+		}
+		if !r.selector.Matches(ev.Labels) {
+			continue
+		}
+
+		alert := ev
+		alert.Rule = r.Name
+		fired = append(fired, alert)
+		if r.Webhook != "" {
+			go e.send(r.Webhook, alert)
+		}
+	}
+	return fired
+}
+
+// send 把告警以 JSON 格式 POST 到 webhook，失败只记录日志
+func (e *Engine) send(webhook string, alert Event) {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		log.Printf("Failed to encode alert %s: %v", alert.Rule, err)
+		return
+	}
+
+	resp, err := e.client.Post(webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Failed to send alert %s to webhook: %v", alert.Rule, err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		log.Printf("Webhook for alert %s returned %s", alert.Rule, resp.Status)
+	}
+}
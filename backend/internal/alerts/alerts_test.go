@@ -0,0 +1,189 @@
+package alerts
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"miniPanel/internal/config"
+)
+
+func TestNewValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []config.AlertRule
+		wantErr string
+	}{
+		{
+			name: "valid",
+			rules: []config.AlertRule{
+				{Name: "prod-checks", Selector: "env=prod", Kind: KindCheck, Match: "http-*", Condition: ConditionFailed},
+				{Name: "check-recovered", Kind: KindCheck, Condition: ConditionRecovered},
+				{Name: "unit-restarts", Kind: KindUnit, Condition: ConditionRestarted, Webhook: "https://hooks.example.com/alert"},
+			},
+		},
+		{
+			name:    "missing name",
+			rules:   []config.AlertRule{{Kind: KindCheck, Condition: ConditionFailed}},
+			wantErr: "name is required",
+		},
+		{
+			name: "duplicate name",
+			rules: []config.AlertRule{
+				{Name: "a", Kind: KindCheck, Condition: ConditionFailed},
+				{Name: "a", Kind: KindUnit, Condition: ConditionFailed},
+			},
+			wantErr: "duplicate name",
+		},
+		{
+			name:    "unknown kind",
+			rules:   []config.AlertRule{{Name: "a", Kind: "disk", Condition: ConditionFailed}},
+			wantErr: "invalid kind",
+		},
+		{
+			name:    "restarted is unit only",
+			rules:   []config.AlertRule{{Name: "a", Kind: KindCheck, Condition: ConditionRestarted}},
+			wantErr: "invalid condition",
+		},
+		{
+			name:    "recovered is check only",
+			rules:   []config.AlertRule{{Name: "a", Kind: KindUnit, Condition: ConditionRecovered}},
+			wantErr: "invalid condition",
+		},
+		{
+			name:    "bad pattern",
+			rules:   []config.AlertRule{{Name: "a", Kind: KindCheck, Condition: ConditionFailed, Match: "[nginx"}},
+			wantErr: "invalid match pattern",
+		},
+		{
+			name:    "bad selector",
+			rules:   []config.AlertRule{{Name: "a", Kind: KindCheck, Condition: ConditionFailed, Selector: "env=prod=x"}},
+			wantErr: "alert rule a",
+		},
+		{
+			name:    "bad webhook",
+			rules:   []config.AlertRule{{Name: "a", Kind: KindCheck, Condition: ConditionFailed, Webhook: "ftp://example.com"}},
+			wantErr: "invalid webhook",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(config.AlertsConfig{Rules: tt.rules})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("New: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("New error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	e, err := New(config.AlertsConfig{Rules: []config.AlertRule{
+		{Name: "prod-http", Selector: "env=prod", Kind: KindCheck, Match: "http-*", Condition: ConditionFailed},
+		{Name: "any-check", Kind: KindCheck, Condition: ConditionFailed},
+		{Name: "units", Selector: "!legacy", Kind: KindUnit, Condition: ConditionFailed},
+	}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		event Event
+		want  []string
+	}{
+		{
+			name:  "selector and pattern match",
+			event: Event{Kind: KindCheck, Name: "http-api", Condition: ConditionFailed, Labels: map[string]string{"env": "prod"}},
+			want:  []string{"prod-http", "any-check"},
+		},
+		{
+			name:  "selector does not match",
+			event: Event{Kind: KindCheck, Name: "http-api", Condition: ConditionFailed, Labels: map[string]string{"env": "dev"}},
+			want:  []string{"any-check"},
+		},
+		{
+			name:  "pattern does not match",
+			event: Event{Kind: KindCheck, Name: "tcp-db", Condition: ConditionFailed, Labels: map[string]string{"env": "prod"}},
+			want:  []string{"any-check"},
+		},
+		{
+			name:  "condition does not match",
+			event: Event{Kind: KindCheck, Name: "http-api", Condition: ConditionRecovered, Labels: map[string]string{"env": "prod"}},
+		},
+		{
+			name:  "unit rule",
+			event: Event{Kind: KindUnit, Name: "nginx.service", Condition: ConditionFailed},
+			want:  []string{"units"},
+		},
+		{
+			name:  "unit excluded by label",
+			event: Event{Kind: KindUnit, Name: "nginx.service", Condition: ConditionFailed, Labels: map[string]string{"legacy": ""}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fired := e.Evaluate(tt.event)
+			var got []string
+			for _, a := range fired {
+				got = append(got, a.Rule)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("fired %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluateNoRules(t *testing.T) {
+	var e *Engine
+	if !e.Empty() {
+		t.Fatal("nil engine should be empty")
+	}
+	if fired := e.Evaluate(Event{Kind: KindCheck, Condition: ConditionFailed}); fired != nil {
+		t.Fatalf("nil engine fired %v", fired)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	received := make(chan Event, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Content-Type = %q", ct)
+		}
+		var ev Event
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		received <- ev
+	}))
+	defer srv.Close()
+
+	e, err := New(config.AlertsConfig{WebhookTimeout: 5, Rules: []config.AlertRule{
+		{Name: "web", Kind: KindUnit, Condition: ConditionRestarted, Webhook: srv.URL},
+	}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	e.Evaluate(Event{NodeID: 3, NodeName: "web-1", Kind: KindUnit, Name: "nginx.service", Condition: ConditionRestarted, Message: "restarted"})
+
+	select {
+	case ev := <-received:
+		if ev.Rule != "web" || ev.NodeName != "web-1" || ev.Name != "nginx.service" || ev.Condition != ConditionRestarted {
+			t.Fatalf("webhook got %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not called")
+	}
+}
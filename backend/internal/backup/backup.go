@@ -0,0 +1,170 @@
+package backup
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"miniPanel/internal/config"
+	"miniPanel/internal/database"
+)
+
+// filePrefix 备份文件名前缀，只有符合该格式的文件才会被列出和轮转
+const filePrefix = "miniPanel-"
+
+// nameLayout 备份文件名中的时间格式，精确到毫秒，连续手动备份或与定时备份同时执行时不会重名
+const nameLayout = "20060102-150405.000"
+
+// Info 备份文件信息
+type Info struct {
+	Name      string `json:"name"`
+	Size      int64  `json:"size"`
+	CreatedAt string `json:"created_at"`
+}
+
+// Manager 管理备份目录，负责创建、列出、轮转和定时备份
+type Manager struct {
+	store database.Store
+	cfg   config.BackupConfig
+	mu    sync.Mutex
+}
+
+// NewManager 创建备份管理器
+func NewManager(store database.Store, cfg config.BackupConfig) *Manager {
+	return &Manager{
+		store: store,
+		cfg:   cfg,
+	}
+}
+
+// Create 在备份目录中生成一份新备份，并按保留数量删除旧备份
+func (m *Manager) Create() (*Info, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(m.cfg.Dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create backup dir: %v", err)
+	}
+
+	name := filePrefix + time.Now().Format(nameLayout) + ".db"
+	if m.cfg.Compress {
+		name += ".gz"
+	}
+	path := filepath.Join(m.cfg.Dir, name)
+	// 例如另一个进程同时执行了 backup 命令，不覆盖已有的备份
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("backup %s already exists", name)
+	}
+
+	if err := m.store.Backup(path, m.cfg.Compress); err != nil {
+		return nil, err
+	}
+
+	if err := m.rotate(); err != nil {
+		log.Printf("Backup rotation failed: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Info{Name: name, Size: info.Size(), CreatedAt: info.ModTime().UTC().Format(time.RFC3339)}, nil
+}
+
+// List 按时间倒序列出备份
+func (m *Manager) List() ([]Info, error) {
+	entries, err := os.ReadDir(m.cfg.Dir)
+	if os.IsNotExist(err) {
+		return []Info{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []Info{}
+	for _, entry := range entries {
+		if entry.IsDir() || !isBackupName(entry.Name()) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Info{
+			Name:      entry.Name(),
+			Size:      info.Size(),
+			CreatedAt: info.ModTime().UTC().Format(time.RFC3339),
+		})
+	}
+
+	// 文件名包含时间戳，按名称倒序即按时间倒序
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Name > backups[j].Name })
+	return backups, nil
+}
+
+// Path 返回备份文件的完整路径，拒绝不属于备份目录的名称
+func (m *Manager) Path(name string) (string, error) {
+	if !isBackupName(name) || filepath.Base(name) != name {
+		return "", fmt.Errorf("invalid backup name: %s", name)
+	}
+
+	path := filepath.Join(m.cfg.Dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Run 按配置的间隔定时备份，直到 stop 被关闭。间隔为0时不启动
+func (m *Manager) Run(stop <-chan struct{}) {
+	if m.cfg.IntervalHours <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(m.cfg.IntervalHours) * time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			info, err := m.Create()
+			if err != nil {
+				log.Printf("Scheduled backup failed: %v", err)
+				continue
+			}
+			log.Printf("Scheduled backup created: %s", info.Name)
+
+		case <-stop:
+			return
+		}
+	}
+}
+
+// rotate 只保留最新的 Keep 份备份
+func (m *Manager) rotate() error {
+	if m.cfg.Keep <= 0 {
+		return nil
+	}
+
+	backups, err := m.List()
+	if err != nil {
+		return err
+	}
+
+	for i := m.cfg.Keep; i < len(backups); i++ {
+		if err := os.Remove(filepath.Join(m.cfg.Dir, backups[i].Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isBackupName(name string) bool {
+	return strings.HasPrefix(name, filePrefix) &&
+		(strings.HasSuffix(name, ".db") || strings.HasSuffix(name, ".db.gz"))
+}
@@ -0,0 +1,137 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"miniPanel/internal/config"
+	"miniPanel/internal/database"
+	"miniPanel/internal/database/dbtest"
+)
+
+func TestCreateUniqueNames(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		m := NewManager(dbtest.OpenSQLite(t), config.BackupConfig{Dir: t.TempDir(), Compress: compress})
+
+		// 同一秒内连续备份不能重名或互相覆盖
+		seen := map[string]bool{}
+		for i := 0; i < 3; i++ {
+			info, err := m.Create()
+			if err != nil {
+				t.Fatalf("compress=%v: Create #%d: %v", compress, i, err)
+			}
+			if seen[info.Name] {
+				t.Fatalf("compress=%v: duplicate backup name %s", compress, info.Name)
+			}
+			seen[info.Name] = true
+		}
+		backups, err := m.List()
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(backups) != 3 {
+			t.Errorf("compress=%v: listed %d backups, want 3", compress, len(backups))
+		}
+	}
+}
+
+func TestBackupExistingFile(t *testing.T) {
+	store := dbtest.OpenSQLite(t)
+	dest := filepath.Join(t.TempDir(), "panel.db")
+	if err := os.WriteFile(dest, []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, compress := range []bool{false, true} {
+		err := store.Backup(dest, compress)
+		if err == nil || !strings.Contains(err.Error(), "already exists") {
+			t.Errorf("compress=%v: Backup over existing file = %v, want already exists error", compress, err)
+		}
+	}
+	if data, _ := os.ReadFile(dest); string(data) != "keep" {
+		t.Errorf("existing file was overwritten: %q", data)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{
+		"miniPanel-20240101-030000.db",
+		"miniPanel-20240102-030000.000.db.gz",
+		"miniPanel-20240103-030000.500.db",
+		"other.db",
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := NewManager(nil, config.BackupConfig{Dir: dir, Keep: 2})
+	if err := m.rotate(); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	backups, err := m.List()
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	var got []string
+	for _, b := range backups {
+		got = append(got, b.Name)
+	}
+	want := "miniPanel-20240103-030000.500.db,miniPanel-20240102-030000.000.db.gz"
+	if strings.Join(got, ",") != want {
+		t.Errorf("after rotate: %v, want %s", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "other.db")); err != nil {
+		t.Errorf("unrelated file removed: %v", err)
+	}
+}
+
+func TestPath(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "miniPanel-20240101-030000.000.db"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(nil, config.BackupConfig{Dir: dir})
+
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"miniPanel-20240101-030000.000.db", false},
+		{"miniPanel-20240101-030001.000.db", true},
+		{"../miniPanel-20240101-030000.000.db", true},
+		{"miniPanel-20240101-030000.000.txt", true},
+		{"panel.db", true},
+	}
+	for _, tt := range tests {
+		_, err := m.Path(tt.name)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Path(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRestoreValidation(t *testing.T) {
+	store := dbtest.OpenSQLite(t)
+	dir := t.TempDir()
+	snapshot := filepath.Join(dir, "snapshot.db.gz")
+	if err := store.Backup(snapshot, true); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	garbage := filepath.Join(dir, "garbage.db")
+	if err := os.WriteFile(garbage, []byte("not a database"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	target := config.DatabaseConfig{Driver: database.DriverSQLite, Path: filepath.Join(dir, "restored.db")}
+	if version, err := database.Restore(target, snapshot); err != nil || version == 0 {
+		t.Errorf("Restore(snapshot) = %d, %v", version, err)
+	}
+	if _, err := database.Restore(target, garbage); err == nil {
+		t.Error("Restore accepted a file that is not a database")
+	}
+}
@@ -0,0 +1,260 @@
+// Package cache 在内存中保存节点最近一段时间的监控数据，实时查询和短时间范围的历史查询不再访问数据库
+package cache
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"miniPanel/internal/models"
+)
+
+type entry struct {
+	ts     time.Time
+	sample models.SystemMetrics
+}
+
+// ring 单个节点的环形缓冲区，按时间先后保存，写满后覆盖最旧的数据
+type ring struct {
+	entries []entry
+	start   int
+	n       int
+}
+
+func newRing(capacity int) *ring {
+	return &ring{entries: make([]entry, capacity)}
+}
+
+func (r *ring) at(i int) entry {
+	return r.entries[(r.start+i)%len(r.entries)]
+}
+
+func (r *ring) full() bool {
+	return r.n == len(r.entries)
+}
+
+// all 按时间先后返回全部数据
+func (r *ring) all() []entry {
+	list := make([]entry, r.n)
+	for i := range list {
+		list[i] = r.at(i)
+	}
+	return list
+}
+
+// reset 用按时间排序的数据重建缓冲区，超出容量时保留最新的
+func (r *ring) reset(list []entry) {
+	if len(list) > len(r.entries) {
+		list = list[len(list)-len(r.entries):]
+	}
+	copy(r.entries, list)
+	r.start = 0
+	r.n = len(list)
+}
+
+func (r *ring) push(e entry) {
+	// 乱序到达的数据插入到正确位置
+	if r.n > 0 && e.ts.Before(r.at(r.n-1).ts) {
+		list := r.all()
+		i := sort.Search(len(list), func(i int) bool { return list[i].ts.After(e.ts) })
+		list = append(list[:i], append([]entry{e}, list[i:]...)...)
+		r.reset(list)
+		return
+	}
+
+	if r.full() {
+		r.entries[r.start] = e
+		r.start = (r.start + 1) % len(r.entries)
+		return
+	}
+	r.entries[(r.start+r.n)%len(r.entries)] = e
+	r.n++
+}
+
+// Window 每个节点最近一段时间的监控数据。
+// 只有从数据库加载过历史数据的节点才用缓存回答时间范围查询，预热失败时其余节点查询数据库
+type Window struct {
+	mu       sync.RWMutex
+	window   time.Duration
+	capacity int
+	rings    map[int]*ring
+
+	loaded    map[int]bool // 已加载历史数据的节点
+	allLoaded bool         // 预热已完成，之后新增的节点在数据库中也没有更早的数据
+}
+
+// NewWindow 创建缓存，window 为保存的时间范围，capacity 为每个节点最多保存的数据条数
+func NewWindow(window time.Duration, capacity int) *Window {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &Window{
+		window:   window,
+		capacity: capacity,
+		rings:    make(map[int]*ring),
+		loaded:   make(map[int]bool),
+	}
+}
+
+// MarkLoaded 记录节点最近的历史数据已全部加载到缓存
+func (w *Window) MarkLoaded(nodeID int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.loaded[nodeID] = true
+}
+
+// MarkAllLoaded 记录预热已完成，全部节点最近的历史数据都已加载到缓存
+func (w *Window) MarkAllLoaded() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.allLoaded = true
+}
+
+// Add 保存一条监控数据，ts 为数据的采集时间
+func (w *Window) Add(sample models.SystemMetrics, ts time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	r, ok := w.rings[sample.NodeID]
+	if !ok {
+		r = newRing(w.capacity)
+		w.rings[sample.NodeID] = r
+	}
+	r.push(entry{ts: ts, sample: sample})
+}
+
+// Latest 获取节点最新一条数据
+func (w *Window) Latest(nodeID int) (models.SystemMetrics, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	r, ok := w.rings[nodeID]
+	if !ok || r.n == 0 {
+		return models.SystemMetrics{}, false
+	}
+	return r.at(r.n - 1).sample, true
+}
+
+// covers 判断缓存是否包含 since 之后的全部数据：
+// since 必须在缓存时间范围内，且缓冲区写满时最旧的数据不晚于 since
+func (w *Window) covers(r *ring, since time.Time) bool {
+	if since.Before(time.Now().Add(-w.window)) {
+		return false
+	}
+	return !r.full() || !r.at(0).ts.After(since)
+}
+
+// Since 返回节点 since 之后的数据，按时间倒序；缓存不能完整覆盖该时间范围，
+// 或节点的历史数据没有加载过时 ok 为 false
+func (w *Window) Since(nodeID int, since time.Time) (samples []models.SystemMetrics, ok bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if !w.allLoaded && !w.loaded[nodeID] {
+		return nil, false
+	}
+	r, exists := w.rings[nodeID]
+	if !exists {
+		// 没有数据的节点：时间范围在缓存内时结果为空
+		return nil, !since.Before(time.Now().Add(-w.window))
+	}
+	if !w.covers(r, since) {
+		return nil, false
+	}
+
+	for i := r.n - 1; i >= 0; i-- {
+		e := r.at(i)
+		if e.ts.Before(since) {
+			break
+		}
+		samples = append(samples, e.sample)
+	}
+	return samples, true
+}
+
+// Stats 统计节点 since 之后的数据，缓存不能完整覆盖该时间范围时 ok 为 false；
+// 没有数据时返回 nil
+func (w *Window) Stats(nodeID int, since time.Time) (stats *models.MetricsStats, ok bool) {
+	samples, ok := w.Since(nodeID, since)
+	if !ok || len(samples) == 0 {
+		return nil, ok
+	}
+
+	s := &models.MetricsStats{
+		CPUMin:    samples[0].CPUPercent,
+		CPUMax:    samples[0].CPUPercent,
+		MemoryMin: samples[0].MemoryPercent,
+		MemoryMax: samples[0].MemoryPercent,
+		TempMin:   samples[0].CPUTemp,
+		TempMax:   samples[0].CPUTemp,
+	}
+	for _, m := range samples {
+		s.Samples++
+		s.CPUMin, s.CPUMax = min(s.CPUMin, m.CPUPercent), max(s.CPUMax, m.CPUPercent)
+		s.MemoryMin, s.MemoryMax = min(s.MemoryMin, m.MemoryPercent), max(s.MemoryMax, m.MemoryPercent)
+		s.TempMin, s.TempMax = min(s.TempMin, m.CPUTemp), max(s.TempMax, m.CPUTemp)
+		s.CPUAvg += m.CPUPercent
+		s.MemoryAvg += m.MemoryPercent
+		s.TempAvg += m.CPUTemp
+	}
+	n := float64(s.Samples)
+	s.CPUAvg /= n
+	s.MemoryAvg /= n
+	s.TempAvg /= n
+	return s, true
+}
+
+// Delete 删除节点的缓存数据，节点被删除时调用
+func (w *Window) Delete(nodeID int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	delete(w.rings, nodeID)
+	delete(w.loaded, nodeID)
+}
+
+// Merge 把源节点的缓存数据并入目标节点，与数据库中合并节点的操作对应
+func (w *Window) Merge(targetID, sourceID int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// 任一节点的历史数据没有加载过时，合并后的节点也不完整
+	w.loaded[targetID] = w.loaded[targetID] && w.loaded[sourceID]
+	delete(w.loaded, sourceID)
+
+	source, ok := w.rings[sourceID]
+	if !ok {
+		return
+	}
+	delete(w.rings, sourceID)
+
+	var list []entry
+	target, ok := w.rings[targetID]
+	if ok {
+		list = target.all()
+	} else {
+		target = newRing(w.capacity)
+		w.rings[targetID] = target
+	}
+	for _, e := range source.all() {
+		e.sample.NodeID = targetID
+		list = append(list, e)
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].ts.Before(list[j].ts) })
+	target.reset(list)
+}
+
+// FromAgent 把上报数据转换为与数据库查询结果相同格式的监控数据
+func FromAgent(metrics *models.AgentMetrics) models.SystemMetrics {
+	return models.SystemMetrics{
+		NodeID:        metrics.NodeID,
+		CPUPercent:    metrics.CPUPercent,
+		MemoryTotal:   metrics.MemoryTotal,
+		MemoryUsed:    metrics.MemoryUsed,
+		MemoryPercent: metrics.MemoryPercent,
+		CPUTemp:       metrics.CPUTemp,
+		Timestamp:     metrics.Timestamp.UTC().Format(time.RFC3339),
+	}
+}
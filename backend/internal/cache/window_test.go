@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"miniPanel/internal/models"
+)
+
+// sample 用 CPUPercent 标记数据，便于比较顺序
+func sample(nodeID int, mark float64) models.SystemMetrics {
+	return models.SystemMetrics{NodeID: nodeID, CPUPercent: mark, MemoryPercent: mark * 2, CPUTemp: mark + 40}
+}
+
+func marks(samples []models.SystemMetrics) []float64 {
+	list := []float64{}
+	for _, s := range samples {
+		list = append(list, s.CPUPercent)
+	}
+	return list
+}
+
+func TestRing(t *testing.T) {
+	base := time.Now()
+	tests := []struct {
+		name    string
+		offsets []int // 按到达顺序，数据时间（秒）同时作为标记
+		want    []float64
+	}{
+		{"in order", []int{1, 2, 3}, []float64{1, 2, 3}},
+		{"wraps around", []int{1, 2, 3, 4, 5, 6}, []float64{3, 4, 5, 6}},
+		{"out of order", []int{1, 3, 2}, []float64{1, 2, 3}},
+		{"late sample after wrap", []int{1, 2, 3, 4, 5, 6, 4}, []float64{4, 4, 5, 6}},
+		{"older than everything when full", []int{2, 3, 4, 5, 1}, []float64{2, 3, 4, 5}},
+		{"equal timestamps keep arrival order", []int{1, 1, 1}, []float64{1, 1, 1}},
+	}
+	for _, tt := range tests {
+		r := newRing(4)
+		for _, off := range tt.offsets {
+			r.push(entry{ts: base.Add(time.Duration(off) * time.Second), sample: sample(1, float64(off))})
+		}
+		var got []float64
+		for _, e := range r.all() {
+			got = append(got, e.sample.CPUPercent)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ring = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWindowSince(t *testing.T) {
+	now := time.Now()
+	w := NewWindow(time.Hour, 3)
+	w.MarkAllLoaded()
+	for i := 1; i <= 5; i++ {
+		w.Add(sample(1, float64(i)), now.Add(time.Duration(i-10)*time.Minute))
+	}
+
+	tests := []struct {
+		name   string
+		nodeID int
+		since  time.Time
+		want   []float64
+		wantOK bool
+	}{
+		{"within buffer", 1, now.Add(-6 * time.Minute), []float64{5, 4}, true},
+		{"oldest kept sample", 1, now.Add(-7 * time.Minute), []float64{5, 4, 3}, true},
+		// 缓冲区写满后更早的数据已被覆盖，需要查询数据库
+		{"before buffer", 1, now.Add(-8 * time.Minute), nil, false},
+		{"outside window", 1, now.Add(-2 * time.Hour), nil, false},
+		{"unknown node in window", 2, now.Add(-time.Minute), []float64{}, true},
+		{"unknown node outside window", 2, now.Add(-2 * time.Hour), nil, false},
+	}
+	for _, tt := range tests {
+		samples, ok := w.Since(tt.nodeID, tt.since)
+		if ok != tt.wantOK {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, tt.wantOK)
+			continue
+		}
+		if ok && !reflect.DeepEqual(marks(samples), tt.want) {
+			t.Errorf("%s: samples = %v, want %v", tt.name, marks(samples), tt.want)
+		}
+	}
+
+	latest, ok := w.Latest(1)
+	if !ok || latest.CPUPercent != 5 {
+		t.Errorf("Latest = %v, %v; want 5", latest.CPUPercent, ok)
+	}
+	if _, ok := w.Latest(2); ok {
+		t.Error("Latest of unknown node returned data")
+	}
+}
+
+func TestWindowNotLoaded(t *testing.T) {
+	now := time.Now()
+	since := now.Add(-10 * time.Minute)
+	w := NewWindow(time.Hour, 10)
+	w.Add(sample(1, 1), now.Add(-5*time.Minute))
+	w.MarkLoaded(1)
+	// 预热失败：节点 2 只有启动后上报的数据，节点 3 没有加载
+	w.Add(sample(2, 2), now.Add(-time.Minute))
+
+	if samples, ok := w.Since(1, since); !ok || !reflect.DeepEqual(marks(samples), []float64{1}) {
+		t.Errorf("loaded node: %v, %v; want [1]", marks(samples), ok)
+	}
+	for _, id := range []int{2, 3} {
+		if _, ok := w.Since(id, since); ok {
+			t.Errorf("node %d not loaded but served from cache", id)
+		}
+	}
+
+	// 并入没有加载过的节点后不再完整
+	w.Merge(1, 2)
+	if _, ok := w.Since(1, since); ok {
+		t.Error("merged node served from cache")
+	}
+
+	w.MarkAllLoaded()
+	if samples, ok := w.Since(3, since); !ok || len(samples) != 0 {
+		t.Errorf("after warm-up: %v, %v; want empty", marks(samples), ok)
+	}
+}
+
+func TestWindowStats(t *testing.T) {
+	now := time.Now()
+	w := NewWindow(time.Hour, 10)
+	w.MarkAllLoaded()
+	for i, mark := range []float64{10, 30, 20} {
+		w.Add(sample(1, mark), now.Add(time.Duration(i-3)*time.Minute))
+	}
+
+	stats, ok := w.Stats(1, now.Add(-10*time.Minute))
+	if !ok || stats == nil {
+		t.Fatalf("Stats = %v, %v", stats, ok)
+	}
+	want := models.MetricsStats{
+		Samples: 3,
+		CPUAvg:  20, CPUMin: 10, CPUMax: 30,
+		MemoryAvg: 40, MemoryMin: 20, MemoryMax: 60,
+		TempAvg: 60, TempMin: 50, TempMax: 70,
+	}
+	if *stats != want {
+		t.Errorf("Stats = %+v, want %+v", *stats, want)
+	}
+
+	if stats, ok := w.Stats(2, now.Add(-10*time.Minute)); !ok || stats != nil {
+		t.Errorf("Stats of node without data = %v, %v; want nil, true", stats, ok)
+	}
+}
+
+func TestWindowMergeAndDelete(t *testing.T) {
+	now := time.Now()
+	w := NewWindow(time.Hour, 4)
+	w.MarkAllLoaded()
+	w.Add(sample(1, 1), now.Add(-5*time.Minute))
+	w.Add(sample(1, 3), now.Add(-3*time.Minute))
+	w.Add(sample(2, 2), now.Add(-4*time.Minute))
+	w.Add(sample(2, 4), now.Add(-2*time.Minute))
+	w.Add(sample(2, 5), now.Add(-1*time.Minute))
+
+	w.Merge(1, 2)
+	samples, ok := w.Since(1, now.Add(-30*time.Minute))
+	// 合并后超出容量，只保留最新的 4 条
+	if ok {
+		t.Errorf("merged ring is full and no longer covers the range, got %v", marks(samples))
+	}
+	samples, ok = w.Since(1, now.Add(-4*time.Minute))
+	if !ok || !reflect.DeepEqual(marks(samples), []float64{5, 4, 3, 2}) {
+		t.Errorf("after merge: %v, %v; want [5 4 3 2]", marks(samples), ok)
+	}
+	for _, s := range samples {
+		if s.NodeID != 1 {
+			t.Errorf("merged sample has node_id %d, want 1", s.NodeID)
+		}
+	}
+	if _, ok := w.Latest(2); ok {
+		t.Error("source node still cached after merge")
+	}
+
+	// 目标节点没有缓存时直接接收源节点的数据
+	w.Merge(3, 1)
+	if latest, ok := w.Latest(3); !ok || latest.CPUPercent != 5 || latest.NodeID != 3 {
+		t.Errorf("Latest(3) = %+v, %v", latest, ok)
+	}
+
+	w.Delete(3)
+	if _, ok := w.Latest(3); ok {
+		t.Error("node still cached after Delete")
+	}
+}
+
+func TestFromAgent(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 20, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	got := FromAgent(&models.AgentMetrics{NodeID: 7, CPUPercent: 12.5, MemoryTotal: 100, MemoryUsed: 40, MemoryPercent: 40, CPUTemp: 55, Timestamp: ts})
+	want := models.SystemMetrics{NodeID: 7, CPUPercent: 12.5, MemoryTotal: 100, MemoryUsed: 40, MemoryPercent: 40, CPUTemp: 55, Timestamp: "2024-03-01T12:00:00Z"}
+	if got != want {
+		t.Errorf("FromAgent = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"miniPanel/internal/models"
+)
+
+// API密钥相关操作
+func (db *DB) CreateAPIKey(key *models.APIKey) error {
+	var expiresAt interface{}
+	if key.ExpiresAt != "" {
+		expiresAt = key.ExpiresAt
+	}
+
+	id, err := db.insert(`
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		key.UserID, key.Name, key.Prefix, key.KeyHash, strings.Join(key.Scopes, ","), expiresAt, utcNow())
+	if err != nil {
+		return err
+	}
+	key.ID = int(id)
+	return nil
+}
+
+func (db *DB) GetAPIKeyByHash(keyHash string) (*models.APIKey, error) {
+	row := db.queryRow(`
+		SELECT id, user_id, name, prefix, key_hash, scopes, created_at, last_used, expires_at
+		FROM api_keys WHERE key_hash = ?`, keyHash)
+	return scanAPIKey(row)
+}
+
+func (db *DB) GetAPIKeysByUser(userID int) ([]models.APIKey, error) {
+	rows, err := db.query(`
+		SELECT id, user_id, name, prefix, key_hash, scopes, created_at, last_used, expires_at
+		FROM api_keys WHERE user_id = ? ORDER BY id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+
+	return keys, rows.Err()
+}
+
+// DeleteAPIKey 删除密钥，只允许删除属于该用户的密钥
+func (db *DB) DeleteAPIKey(id, userID int) (bool, error) {
+	result, err := db.exec("DELETE FROM api_keys WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// TouchAPIKey 记录密钥最后使用时间，一分钟内重复使用不再写库
+func (db *DB) TouchAPIKey(id int, now time.Time) error {
+	nowStr := now.UTC().Format(timeFormat)
+	threshold := now.UTC().Add(-time.Minute).Format(timeFormat)
+	_, err := db.exec(`
+		UPDATE api_keys SET last_used = ?
+		WHERE id = ? AND (last_used IS NULL OR last_used < ?)`,
+		nowStr, id, threshold)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	key := &models.APIKey{}
+	var scopes string
+	var lastUsed, expiresAt sql.NullString
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.KeyHash, &scopes,
+		&key.CreatedAt, &lastUsed, &expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	key.LastUsed = lastUsed.String
+	key.ExpiresAt = expiresAt.String
+	return key, nil
+}
@@ -0,0 +1,186 @@
+package database
+
+import (
+	"compress/gzip"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"miniPanel/internal/config"
+)
+
+// Backup 生成数据库的一致性快照，服务运行期间也可以安全执行。
+// compress 为 true 时输出 gzip 压缩文件
+func (db *DB) Backup(dest string, compress bool) error {
+	if db.driver != DriverSQLite {
+		return fmt.Errorf("online backup is only supported for sqlite, use pg_dump for %s", db.driver)
+	}
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("backup file already exists: %s", dest)
+	}
+
+	if !compress {
+		return db.vacuumInto(dest)
+	}
+
+	// 先生成未压缩的快照，再压缩到目标文件
+	tmp := fmt.Sprintf("%s.tmp-%d", dest, time.Now().UnixNano())
+	if err := db.vacuumInto(tmp); err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	return gzipFile(tmp, dest)
+}
+
+// Restore 用备份文件替换SQLite数据库，必须在服务停止时执行。
+// 恢复前会校验备份的完整性和结构版本，原数据库被保留为 .pre-restore 文件。
+// 返回备份的结构版本，旧版本会在下次启动或 migrate up 时升级
+func Restore(cfg config.DatabaseConfig, src string) (int, error) {
+	if cfg.Driver != "" && cfg.Driver != DriverSQLite {
+		return 0, fmt.Errorf("restore is only supported for sqlite, use pg_restore for %s", cfg.Driver)
+	}
+
+	dir := filepath.Dir(cfg.Path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, err
+	}
+
+	// 在数据库同目录下准备待替换的文件，保证最后的 rename 是原子的
+	staged := filepath.Join(dir, fmt.Sprintf(".%s.restore-%d", filepath.Base(cfg.Path), time.Now().UnixNano()))
+	var err error
+	if strings.HasSuffix(src, ".gz") {
+		err = gunzipFile(src, staged)
+	} else {
+		err = copyFile(src, staged)
+	}
+	if err != nil {
+		os.Remove(staged)
+		return 0, fmt.Errorf("failed to stage backup: %v", err)
+	}
+
+	version, err := validateSnapshot(staged)
+	if err != nil {
+		os.Remove(staged)
+		return 0, err
+	}
+
+	if _, err := os.Stat(cfg.Path); err == nil {
+		previous := fmt.Sprintf("%s.pre-restore-%s", cfg.Path, time.Now().Format("20060102150405"))
+		if err := os.Rename(cfg.Path, previous); err != nil {
+			os.Remove(staged)
+			return 0, fmt.Errorf("failed to move current database aside: %v", err)
+		}
+	}
+	// 旧的WAL文件属于被替换的数据库，必须删除
+	os.Remove(cfg.Path + "-wal")
+	os.Remove(cfg.Path + "-shm")
+
+	if err := os.Rename(staged, cfg.Path); err != nil {
+		return 0, fmt.Errorf("failed to move restored database into place: %v", err)
+	}
+
+	return version, nil
+}
+
+// validateSnapshot 校验快照文件完整并且结构版本受支持，返回其结构版本
+func validateSnapshot(path string) (int, error) {
+	conn, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var result string
+	if err := conn.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return 0, fmt.Errorf("backup is not a valid sqlite database: %v", err)
+	}
+	if result != "ok" {
+		return 0, fmt.Errorf("backup failed integrity check: %s", result)
+	}
+
+	var version int
+	if err := conn.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("backup has no schema_version table: %v", err)
+	}
+	if version == 0 {
+		return 0, fmt.Errorf("backup has no applied migrations")
+	}
+	if version > LatestSchemaVersion() {
+		return 0, fmt.Errorf("backup schema version %d is newer than supported version %d", version, LatestSchemaVersion())
+	}
+
+	return version, nil
+}
+
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func gzipFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		os.Remove(dest)
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		os.Remove(dest)
+		return err
+	}
+	return out.Close()
+}
+
+func gunzipFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	zr, err := gzip.NewReader(in)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, zr); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
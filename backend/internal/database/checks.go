@@ -0,0 +1,155 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"miniPanel/internal/models"
+)
+
+const checkColumns = "node_id, name, type, status, latency_ms, message, since, updated_at"
+
+func scanCheck(row rowScanner) (*models.NodeCheck, error) {
+	check := &models.NodeCheck{}
+	err := row.Scan(&check.NodeID, &check.Name, &check.Type, &check.Status, &check.LatencyMs,
+		&check.Message, &check.Since, &check.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
+// UpdateNodeChecks 用上报的结果替换节点的服务检查，不在结果中的检查被删除。
+// 新出现和状态变化的检查记录到 check_events，返回新记录的事件。
+// at 为数据时间，早于已保存状态的数据（如补发的旧数据）被忽略
+func (db *DB) UpdateNodeChecks(nodeID int, at time.Time, results []models.CheckResult) ([]models.CheckEvent, error) {
+	ts := at.UTC().Format(timeFormat)
+	var newer int
+	err := db.queryRow("SELECT COUNT(*) FROM node_checks WHERE node_id = ? AND updated_at > ?", nodeID, ts).Scan(&newer)
+	if err != nil {
+		return nil, err
+	}
+	if newer > 0 {
+		return nil, nil
+	}
+
+	checks, err := db.GetNodeChecks(nodeID)
+	if err != nil {
+		return nil, err
+	}
+	prev := map[string]models.NodeCheck{}
+	for _, check := range checks {
+		prev[check.Name] = check
+	}
+
+	var events []models.CheckEvent
+	err = db.withTx(func(tx *sql.Tx) error {
+		for _, r := range results {
+			old, seen := prev[r.Name]
+			delete(prev, r.Name)
+
+			// 状态未变化时保留进入该状态的时间
+			_, err := tx.Exec(db.rebind(`
+				INSERT INTO node_checks (node_id, name, type, status, latency_ms, message, since, updated_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+				ON CONFLICT (node_id, name) DO UPDATE SET
+					since = CASE WHEN node_checks.status = excluded.status THEN node_checks.since ELSE excluded.since END,
+					type = excluded.type, status = excluded.status, latency_ms = excluded.latency_ms,
+					message = excluded.message, updated_at = excluded.updated_at`),
+				nodeID, r.Name, r.Type, r.Status, r.LatencyMs, r.Message, ts, ts)
+			if err != nil {
+				return err
+			}
+			if seen && old.Status == r.Status {
+				continue
+			}
+
+			event := models.CheckEvent{
+				NodeID:         nodeID,
+				Name:           r.Name,
+				Status:         r.Status,
+				PreviousStatus: old.Status,
+				Message:        r.Message,
+				CreatedAt:      ts,
+			}
+			_, err = tx.Exec(db.rebind(`
+				INSERT INTO check_events (node_id, name, status, previous_status, message, created_at)
+				VALUES (?, ?, ?, ?, ?, ?)`),
+				nodeID, event.Name, event.Status, event.PreviousStatus, event.Message, ts)
+			if err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+
+		// Agent配置中已移除的检查
+		for name := range prev {
+			if _, err := tx.Exec(db.rebind("DELETE FROM node_checks WHERE node_id = ? AND name = ?"), nodeID, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// GetNodeChecks 获取节点全部服务检查的当前状态，按名称排序
+func (db *DB) GetNodeChecks(nodeID int) ([]models.NodeCheck, error) {
+	return db.queryChecks("SELECT "+checkColumns+" FROM node_checks WHERE node_id = ? ORDER BY name", nodeID)
+}
+
+// GetChecks 获取所有节点的服务检查，status 不为空时只返回该状态的检查
+func (db *DB) GetChecks(status string) ([]models.NodeCheck, error) {
+	if status == "" {
+		return db.queryChecks("SELECT " + checkColumns + " FROM node_checks ORDER BY node_id, name")
+	}
+	return db.queryChecks("SELECT "+checkColumns+" FROM node_checks WHERE status = ? ORDER BY node_id, name", status)
+}
+
+func (db *DB) queryChecks(query string, args ...interface{}) ([]models.NodeCheck, error) {
+	rows, err := db.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checks := []models.NodeCheck{}
+	for rows.Next() {
+		check, err := scanCheck(rows)
+		if err != nil {
+			return nil, err
+		}
+		checks = append(checks, *check)
+	}
+	return checks, rows.Err()
+}
+
+// GetCheckEvents 获取节点服务检查最近的状态变化，按时间倒序；name 不为空时只返回该检查的记录
+func (db *DB) GetCheckEvents(nodeID int, name string, limit int) ([]models.CheckEvent, error) {
+	query := "SELECT id, node_id, name, status, previous_status, message, created_at FROM check_events WHERE node_id = ?"
+	args := []interface{}{nodeID}
+	if name != "" {
+		query += " AND name = ?"
+		args = append(args, name)
+	}
+	rows, err := db.query(query+" ORDER BY created_at DESC, id DESC LIMIT ?", append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.CheckEvent{}
+	for rows.Next() {
+		var event models.CheckEvent
+		err := rows.Scan(&event.ID, &event.NodeID, &event.Name, &event.Status, &event.PreviousStatus,
+			&event.Message, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
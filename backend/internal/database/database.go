@@ -2,85 +2,117 @@ package database
 
 import (
 	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"miniPanel/internal/config"
 	"miniPanel/internal/models"
 
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type DB struct {
-	conn *sql.DB
+	conn   *sql.DB
+	driver string
+	path   string
 }
 
+// NewDB 打开SQLite数据库
 func NewDB(dbPath string) (*DB, error) {
-	conn, err := sql.Open("sqlite3", dbPath)
+	return Open(config.DatabaseConfig{Driver: DriverSQLite, Path: dbPath, AutoMigrate: true, BackupBeforeMigrate: true})
+}
+
+// Open 按配置打开数据库，默认使用SQLite，并按配置自动执行迁移
+func Open(cfg config.DatabaseConfig) (*DB, error) {
+	db, err := Connect(cfg)
 	if err != nil {
 		return nil, err
 	}
 
-	db := &DB{conn: conn}
-	err = db.createTables()
+	if cfg.AutoMigrate {
+		err = db.Migrate(cfg.BackupBeforeMigrate)
+	} else {
+		err = db.checkSchemaVersion()
+	}
 	if err != nil {
+		db.Close()
 		return nil, err
 	}
 
 	// 创建默认管理员用户
 	err = db.createDefaultAdmin()
 	if err != nil {
+		db.Close()
 		return nil, err
 	}
 
 	return db, nil
 }
 
-func (db *DB) createTables() error {
-	// 创建用户表
-	userTable := `
-	CREATE TABLE IF NOT EXISTS users (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		username TEXT UNIQUE NOT NULL,
-		password TEXT NOT NULL
-	);`
-
-	// 创建节点表
-	nodeTable := `
-	CREATE TABLE IF NOT EXISTS nodes (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		name TEXT NOT NULL,
-		ip TEXT UNIQUE NOT NULL,
-		status TEXT DEFAULT 'offline',
-		last_seen DATETIME DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	// 创建系统监控数据表
-	metricsTable := `
-	CREATE TABLE IF NOT EXISTS system_metrics (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		node_id INTEGER NOT NULL,
-		cpu_percent REAL NOT NULL,
-		memory_total INTEGER NOT NULL,
-		memory_used INTEGER NOT NULL,
-		memory_percent REAL NOT NULL,
-		cpu_temp REAL NOT NULL,
-		timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
-		FOREIGN KEY (node_id) REFERENCES nodes(id)
-	);`
-
-	tables := []string{userTable, nodeTable, metricsTable}
-	for _, table := range tables {
-		_, err := db.conn.Exec(table)
-		if err != nil {
-			return err
-		}
+// Connect 只建立数据库连接，不执行迁移，供迁移和备份命令使用
+func Connect(cfg config.DatabaseConfig) (*DB, error) {
+	var conn *sql.DB
+	var err error
+
+	switch cfg.Driver {
+	case "", DriverSQLite:
+		cfg.Driver = DriverSQLite
+		conn, err = sql.Open("sqlite3", sqliteDSN(cfg))
+	case DriverPostgres:
+		conn, err = sql.Open("postgres", cfg.DSN)
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", cfg.Driver)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &DB{conn: conn, driver: cfg.Driver, path: cfg.Path}, nil
+}
+
+// sqliteDSN 在数据库路径后附加连接参数，参数对连接池中的每个连接生效
+func sqliteDSN(cfg config.DatabaseConfig) string {
+	var params []string
+	if cfg.JournalMode != "" {
+		params = append(params, "_journal_mode="+cfg.JournalMode)
+	}
+	if cfg.BusyTimeoutMs > 0 {
+		params = append(params, fmt.Sprintf("_busy_timeout=%d", cfg.BusyTimeoutMs))
+	}
+	// WAL 模式下 NORMAL 同步已能保证一致性，且写入更快
+	if strings.EqualFold(cfg.JournalMode, "WAL") {
+		params = append(params, "_synchronous=NORMAL")
+	}
+	if len(params) == 0 {
+		return cfg.Path
 	}
 
+	sep := "?"
+	if strings.Contains(cfg.Path, "?") {
+		sep = "&"
+	}
+	return cfg.Path + sep + strings.Join(params, "&")
+}
+
+// checkSchemaVersion 未开启自动迁移时，确认数据库结构已是最新
+func (db *DB) checkSchemaVersion() error {
+	version, err := db.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if version != LatestSchemaVersion() {
+		return fmt.Errorf("database schema version %d does not match required version %d, run 'migrate up'", version, LatestSchemaVersion())
+	}
 	return nil
 }
 
 func (db *DB) createDefaultAdmin() error {
 	// 检查是否已存在管理员用户
 	var count int
-	err := db.conn.QueryRow("SELECT COUNT(*) FROM users WHERE username = ?", "admin").Scan(&count)
+	err := db.queryRow("SELECT COUNT(*) FROM users WHERE username = ?", "admin").Scan(&count)
 	if err != nil {
 		return err
 	}
@@ -95,14 +127,14 @@ func (db *DB) createDefaultAdmin() error {
 		return err
 	}
 
-	_, err = db.conn.Exec("INSERT INTO users (username, password) VALUES (?, ?)", "admin", string(hashedPassword))
+	_, err = db.exec("INSERT INTO users (username, password) VALUES (?, ?)", "admin", string(hashedPassword))
 	return err
 }
 
 // 用户相关操作
 func (db *DB) GetUserByUsername(username string) (*models.User, error) {
 	user := &models.User{}
-	err := db.conn.QueryRow("SELECT id, username, password FROM users WHERE username = ?", username).Scan(
+	err := db.queryRow("SELECT id, username, password FROM users WHERE username = ?", username).Scan(
 		&user.ID, &user.Username, &user.Password)
 	if err != nil {
 		return nil, err
@@ -111,8 +143,20 @@ func (db *DB) GetUserByUsername(username string) (*models.User, error) {
 }
 
 // 节点相关操作
+const nodeColumns = "id, name, agent_name, ip, status, lifecycle, last_seen, clock_skew_ms"
+
+func scanNode(row rowScanner) (*models.Node, error) {
+	node := &models.Node{}
+	err := row.Scan(&node.ID, &node.Name, &node.AgentName, &node.IP, &node.Status, &node.Lifecycle, &node.LastSeen,
+		&node.ClockSkewMs)
+	if err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
 func (db *DB) GetAllNodes() ([]models.Node, error) {
-	rows, err := db.conn.Query("SELECT id, name, ip, status, last_seen FROM nodes")
+	rows, err := db.query("SELECT " + nodeColumns + " FROM nodes")
 	if err != nil {
 		return nil, err
 	}
@@ -120,30 +164,79 @@ func (db *DB) GetAllNodes() ([]models.Node, error) {
 
 	var nodes []models.Node
 	for rows.Next() {
-		var node models.Node
-		err := rows.Scan(&node.ID, &node.Name, &node.IP, &node.Status, &node.LastSeen)
+		node, err := scanNode(rows)
 		if err != nil {
 			return nil, err
 		}
-		nodes = append(nodes, node)
+		nodes = append(nodes, *node)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	labels, err := db.getAllLabels()
+	if err != nil {
+		return nil, err
+	}
+	for i := range nodes {
+		nodes[i].Labels = labels[nodes[i].ID]
+		if nodes[i].Labels == nil {
+			nodes[i].Labels = map[string]string{}
+		}
 	}
 
 	return nodes, nil
 }
 
-func (db *DB) GetNodeByIP(ip string) (*models.Node, error) {
-	node := &models.Node{}
-	err := db.conn.QueryRow("SELECT id, name, ip, status, last_seen FROM nodes WHERE ip = ?", ip).Scan(
-		&node.ID, &node.Name, &node.IP, &node.Status, &node.LastSeen)
+func (db *DB) GetNodeByID(id int) (*models.Node, error) {
+	node, err := scanNode(db.queryRow("SELECT "+nodeColumns+" FROM nodes WHERE id = ?", id))
+	if err != nil {
+		return nil, err
+	}
+
+	node.Labels, err = db.GetNodeLabels(id)
 	if err != nil {
 		return nil, err
 	}
 	return node, nil
 }
 
+// 节点别名类型，合并节点后源节点的IP和名称作为目标节点的别名
+const (
+	aliasIP        = "ip"
+	aliasAgentName = "agent_name"
+)
+
+// GetNodeByIP 按IP查找节点，没有节点使用该IP时查找以该IP为别名的节点
+func (db *DB) GetNodeByIP(ip string) (*models.Node, error) {
+	node, err := scanNode(db.queryRow("SELECT "+nodeColumns+" FROM nodes WHERE ip = ?", ip))
+	if err == sql.ErrNoRows {
+		return db.getNodeByAlias(aliasIP, ip)
+	}
+	return node, err
+}
+
+func (db *DB) getNodeByAlias(kind, value string) (*models.Node, error) {
+	return scanNode(db.queryRow(`
+		SELECT `+nodeColumns+` FROM nodes
+		WHERE id = (SELECT node_id FROM node_aliases WHERE kind = ? AND value = ?)`,
+		kind, value))
+}
+
+// nodeAliveSQL 节点上报时更新的状态：已归档的节点重新上报后恢复为活跃，已退役的节点保持不变
+const nodeAliveSQL = `
+	status = CASE WHEN lifecycle = 'decommissioned' THEN status ELSE 'online' END,
+	lifecycle = CASE WHEN lifecycle = 'archived' THEN 'active' ELSE lifecycle END,
+	last_seen = ?`
+
+// nodeSeenSQL 在 nodeAliveSQL 之外同步Agent上报的名称，管理员改过的名称不被覆盖
+const nodeSeenSQL = `
+	name = CASE WHEN name = agent_name THEN ? ELSE name END,
+	agent_name = ?,` + nodeAliveSQL
+
 func (db *DB) CreateOrUpdateNode(name, ip string) error {
 	// 尝试更新现有节点
-	result, err := db.conn.Exec("UPDATE nodes SET name = ?, status = 'online', last_seen = CURRENT_TIMESTAMP WHERE ip = ?", name, ip)
+	result, err := db.exec("UPDATE nodes SET "+nodeSeenSQL+" WHERE ip = ?", name, name, utcNow(), ip)
 	if err != nil {
 		return err
 	}
@@ -152,29 +245,115 @@ func (db *DB) CreateOrUpdateNode(name, ip string) error {
 	if err != nil {
 		return err
 	}
+	if rowsAffected > 0 {
+		return nil
+	}
+
+	// 已合并到其他节点的IP：更新目标节点的状态，名称以目标节点为准
+	result, err = db.exec(`
+		UPDATE nodes SET `+nodeAliveSQL+`
+		WHERE id = (SELECT node_id FROM node_aliases WHERE kind = ? AND value = ?)`,
+		utcNow(), aliasIP, ip)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err = result.RowsAffected()
+	if err != nil {
+		return err
+	}
 
 	// 如果没有更新任何行，则创建新节点
 	if rowsAffected == 0 {
-		_, err = db.conn.Exec("INSERT INTO nodes (name, ip, status) VALUES (?, ?, 'online')", name, ip)
+		_, err = db.exec("INSERT INTO nodes (name, agent_name, ip, status, last_seen) VALUES (?, ?, ?, 'online', ?)",
+			name, name, ip, utcNow())
 		return err
 	}
 
 	return nil
 }
 
+// GetNodeByName 按Agent上报的名称（或证书身份）查找节点，也查找以该名称为别名的节点
+func (db *DB) GetNodeByName(name string) (*models.Node, error) {
+	node, err := scanNode(db.queryRow("SELECT "+nodeColumns+" FROM nodes WHERE agent_name = ?", name))
+	if err == sql.ErrNoRows {
+		return db.getNodeByAlias(aliasAgentName, name)
+	}
+	return node, err
+}
+
+// UpsertNodeByName 以名称（证书身份）作为节点标识创建或更新节点
+func (db *DB) UpsertNodeByName(name, ip string) (*models.Node, error) {
+	node, err := db.GetNodeByName(name)
+	if err == nil && node.AgentName != name {
+		// 通过别名找到的节点保留目标节点的名称
+		name = node.AgentName
+	}
+	if err == sql.ErrNoRows {
+		// 沿用以前按IP登记的同一台机器的记录
+		node, err = db.GetNodeByIP(ip)
+		if err == sql.ErrNoRows {
+			_, err = db.exec("INSERT INTO nodes (name, agent_name, ip, status, last_seen) VALUES (?, ?, ?, 'online', ?)",
+				name, name, ip, utcNow())
+			if err != nil {
+				return nil, err
+			}
+			return db.GetNodeByName(name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	// IP被其他节点占用时保留原IP
+	_, err = db.exec(`
+		UPDATE nodes SET `+nodeSeenSQL+`,
+			ip = CASE WHEN EXISTS (SELECT 1 FROM nodes WHERE ip = ? AND id <> ?) THEN ip ELSE ? END
+		WHERE id = ?`,
+		name, name, utcNow(), ip, node.ID, ip, node.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.GetNodeByID(node.ID)
+}
+
 // 监控数据相关操作
 func (db *DB) InsertMetrics(metrics *models.AgentMetrics) error {
-	_, err := db.conn.Exec(`
+	_, err := db.exec(`
 		INSERT INTO system_metrics (node_id, cpu_percent, memory_total, memory_used, memory_percent, cpu_temp, timestamp)
 		VALUES (?, ?, ?, ?, ?, ?, ?)`,
 		metrics.NodeID, metrics.CPUPercent, metrics.MemoryTotal, metrics.MemoryUsed,
-		metrics.MemoryPercent, metrics.CPUTemp, metrics.Timestamp.Format("2006-01-02 15:04:05"))
+		metrics.MemoryPercent, metrics.CPUTemp, metrics.Timestamp.UTC().Format(timeFormat))
 	return err
 }
 
+// InsertMetricsBatch 在一个事务中写入多条监控数据
+func (db *DB) InsertMetricsBatch(batch []*models.AgentMetrics) error {
+	return db.withTx(func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare(db.rebind(`
+			INSERT INTO system_metrics (node_id, cpu_percent, memory_total, memory_used, memory_percent, cpu_temp, timestamp)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`))
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, metrics := range batch {
+			_, err := stmt.Exec(metrics.NodeID, metrics.CPUPercent, metrics.MemoryTotal, metrics.MemoryUsed,
+				metrics.MemoryPercent, metrics.CPUTemp, metrics.Timestamp.UTC().Format(timeFormat))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
 func (db *DB) GetLatestMetrics(nodeID int) (*models.SystemMetrics, error) {
 	metrics := &models.SystemMetrics{}
-	err := db.conn.QueryRow(`
+	err := db.queryRow(`
 		SELECT id, node_id, cpu_percent, memory_total, memory_used, memory_percent, cpu_temp, timestamp
 		FROM system_metrics WHERE node_id = ? ORDER BY timestamp DESC LIMIT 1`,
 		nodeID).Scan(&metrics.ID, &metrics.NodeID, &metrics.CPUPercent, &metrics.MemoryTotal,
@@ -186,11 +365,16 @@ func (db *DB) GetLatestMetrics(nodeID int) (*models.SystemMetrics, error) {
 }
 
 func (db *DB) GetHistoryMetrics(nodeID int, days int) ([]models.SystemMetrics, error) {
-	rows, err := db.conn.Query(`
+	return db.GetMetricsSince(nodeID, time.Now().AddDate(0, 0, -days))
+}
+
+// GetMetricsSince 获取节点 since 之后的监控数据，按时间倒序
+func (db *DB) GetMetricsSince(nodeID int, since time.Time) ([]models.SystemMetrics, error) {
+	rows, err := db.query(`
 		SELECT id, node_id, cpu_percent, memory_total, memory_used, memory_percent, cpu_temp, timestamp
-		FROM system_metrics WHERE node_id = ? AND timestamp >= datetime('now', '-' || ? || ' days')
+		FROM system_metrics WHERE node_id = ? AND timestamp >= ?
 		ORDER BY timestamp DESC`,
-		nodeID, days)
+		nodeID, since.UTC().Format(timeFormat))
 	if err != nil {
 		return nil, err
 	}
@@ -210,6 +394,37 @@ func (db *DB) GetHistoryMetrics(nodeID int, days int) ([]models.SystemMetrics, e
 	return metrics, nil
 }
 
+// GetMetricsStats 按节点统计 since 之后的监控数据
+func (db *DB) GetMetricsStats(since time.Time) (map[int]models.MetricsStats, error) {
+	rows, err := db.query(`
+		SELECT node_id, COUNT(*),
+			MIN(cpu_percent), AVG(cpu_percent), MAX(cpu_percent),
+			MIN(memory_percent), AVG(memory_percent), MAX(memory_percent),
+			MIN(cpu_temp), AVG(cpu_temp), MAX(cpu_temp)
+		FROM system_metrics WHERE timestamp >= ?
+		GROUP BY node_id`,
+		since.UTC().Format(timeFormat))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stats := make(map[int]models.MetricsStats)
+	for rows.Next() {
+		var nodeID int
+		var s models.MetricsStats
+		err := rows.Scan(&nodeID, &s.Samples,
+			&s.CPUMin, &s.CPUAvg, &s.CPUMax,
+			&s.MemoryMin, &s.MemoryAvg, &s.MemoryMax,
+			&s.TempMin, &s.TempAvg, &s.TempMax)
+		if err != nil {
+			return nil, err
+		}
+		stats[nodeID] = s
+	}
+	return stats, rows.Err()
+}
+
 func (db *DB) Close() error {
 	return db.conn.Close()
-}
\ No newline at end of file
+}
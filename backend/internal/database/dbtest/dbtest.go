@@ -0,0 +1,955 @@
+// Package dbtest 提供存储实现的一致性检查，所有 database.Store 实现都应通过同一套检查。
+//
+// 用法与 testing/fstest 类似，传入一个空的存储实例：
+//
+//	store, _ := database.Open(config.DatabaseConfig{Driver: "postgres", DSN: dsn, AutoMigrate: true})
+//	if err := dbtest.TestStore(store); err != nil {
+//		t.Fatal(err)
+//	}
+package dbtest
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"miniPanel/internal/database"
+	"miniPanel/internal/models"
+)
+
+// TestStore 对一个空的存储实例运行全部一致性检查，返回所有失败项
+func TestStore(store database.Store) error {
+	checks := []struct {
+		name string
+		fn   func(database.Store) error
+	}{
+		{"default admin", checkDefaultAdmin},
+		{"nodes by ip", checkNodesByIP},
+		{"nodes by name", checkNodesByName},
+		{"metrics", checkMetrics},
+		{"api keys", checkAPIKeys},
+		{"labels and groups", checkLabels},
+		{"node lifecycle", checkLifecycle},
+		{"inventory and events", checkInventory},
+		{"scrape targets and liveness", checkScrape},
+		{"config profiles", checkConfigProfiles},
+		{"agent releases", checkReleases},
+		{"clock skew and timestamps", checkClockSkew},
+		{"service checks", checkServiceChecks},
+		{"systemd units", checkUnits},
+	}
+
+	var errs []error
+	for _, check := range checks {
+		if err := check.fn(store); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", check.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func checkDefaultAdmin(store database.Store) error {
+	user, err := store.GetUserByUsername("admin")
+	if err != nil {
+		return fmt.Errorf("GetUserByUsername: %v", err)
+	}
+	if user.Password == "" || user.Password == "admin123" {
+		return fmt.Errorf("admin password is not hashed")
+	}
+	if _, err := store.GetUserByUsername("no-such-user"); err == nil {
+		return fmt.Errorf("expected error for unknown user")
+	}
+	return nil
+}
+
+func checkNodesByIP(store database.Store) error {
+	if err := store.CreateOrUpdateNode("conf-a", "10.99.0.1"); err != nil {
+		return fmt.Errorf("CreateOrUpdateNode: %v", err)
+	}
+	if err := store.CreateOrUpdateNode("conf-a-renamed", "10.99.0.1"); err != nil {
+		return fmt.Errorf("CreateOrUpdateNode update: %v", err)
+	}
+
+	node, err := store.GetNodeByIP("10.99.0.1")
+	if err != nil {
+		return fmt.Errorf("GetNodeByIP: %v", err)
+	}
+	if node.Name != "conf-a-renamed" || node.Status != "online" {
+		return fmt.Errorf("unexpected node %+v", node)
+	}
+
+	nodes, err := store.GetAllNodes()
+	if err != nil {
+		return fmt.Errorf("GetAllNodes: %v", err)
+	}
+	count := 0
+	for _, n := range nodes {
+		if n.IP == "10.99.0.1" {
+			count++
+		}
+	}
+	if count != 1 {
+		return fmt.Errorf("expected 1 node with ip 10.99.0.1, got %d", count)
+	}
+	return nil
+}
+
+func checkNodesByName(store database.Store) error {
+	node, err := store.UpsertNodeByName("conf-cert", "10.99.0.2")
+	if err != nil {
+		return fmt.Errorf("UpsertNodeByName create: %v", err)
+	}
+	if node.Name != "conf-cert" || node.IP != "10.99.0.2" {
+		return fmt.Errorf("unexpected node %+v", node)
+	}
+
+	// IP变化后仍是同一节点
+	moved, err := store.UpsertNodeByName("conf-cert", "10.99.0.3")
+	if err != nil {
+		return fmt.Errorf("UpsertNodeByName move: %v", err)
+	}
+	if moved.ID != node.ID || moved.IP != "10.99.0.3" {
+		return fmt.Errorf("expected node %d at 10.99.0.3, got %+v", node.ID, moved)
+	}
+
+	// 已按IP登记的节点会被沿用
+	if err := store.CreateOrUpdateNode("legacy", "10.99.0.4"); err != nil {
+		return fmt.Errorf("CreateOrUpdateNode: %v", err)
+	}
+	legacy, err := store.GetNodeByIP("10.99.0.4")
+	if err != nil {
+		return fmt.Errorf("GetNodeByIP: %v", err)
+	}
+	adopted, err := store.UpsertNodeByName("conf-adopted", "10.99.0.4")
+	if err != nil {
+		return fmt.Errorf("UpsertNodeByName adopt: %v", err)
+	}
+	if adopted.ID != legacy.ID {
+		return fmt.Errorf("expected legacy node %d to be adopted, got %d", legacy.ID, adopted.ID)
+	}
+
+	// IP被其他节点占用时保留原IP
+	kept, err := store.UpsertNodeByName("conf-cert", "10.99.0.4")
+	if err != nil {
+		return fmt.Errorf("UpsertNodeByName conflict: %v", err)
+	}
+	if kept.IP != "10.99.0.3" {
+		return fmt.Errorf("expected ip to stay 10.99.0.3, got %s", kept.IP)
+	}
+	return nil
+}
+
+func checkMetrics(store database.Store) error {
+	if err := store.CreateOrUpdateNode("conf-metrics", "10.99.0.10"); err != nil {
+		return fmt.Errorf("CreateOrUpdateNode: %v", err)
+	}
+	node, err := store.GetNodeByIP("10.99.0.10")
+	if err != nil {
+		return fmt.Errorf("GetNodeByIP: %v", err)
+	}
+
+	now := time.Now().UTC()
+	samples := []time.Time{now.AddDate(0, 0, -3), now.Add(-time.Hour), now.Add(-time.Minute)}
+	for i, ts := range samples {
+		err := store.InsertMetrics(&models.AgentMetrics{
+			NodeID:        node.ID,
+			CPUPercent:    float64(10 * (i + 1)),
+			MemoryTotal:   8 << 30,
+			MemoryUsed:    uint64(i+1) << 30,
+			MemoryPercent: float64(i+1) * 12.5,
+			CPUTemp:       40,
+			Timestamp:     ts,
+		})
+		if err != nil {
+			return fmt.Errorf("InsertMetrics: %v", err)
+		}
+	}
+
+	latest, err := store.GetLatestMetrics(node.ID)
+	if err != nil {
+		return fmt.Errorf("GetLatestMetrics: %v", err)
+	}
+	if latest.CPUPercent != 30 || latest.MemoryTotal != 8<<30 {
+		return fmt.Errorf("unexpected latest sample %+v", latest)
+	}
+
+	history, err := store.GetHistoryMetrics(node.ID, 1)
+	if err != nil {
+		return fmt.Errorf("GetHistoryMetrics: %v", err)
+	}
+	if len(history) != 2 {
+		return fmt.Errorf("expected 2 samples in last day, got %d", len(history))
+	}
+	if history[0].CPUPercent != 30 {
+		return fmt.Errorf("expected newest sample first, got %+v", history[0])
+	}
+
+	// 批量写入
+	batch := []*models.AgentMetrics{
+		{NodeID: node.ID, CPUPercent: 1, Timestamp: now.AddDate(0, 0, -2)},
+		{NodeID: node.ID, CPUPercent: 2, Timestamp: now.AddDate(0, 0, -2).Add(time.Second)},
+	}
+	if err := store.InsertMetricsBatch(batch); err != nil {
+		return fmt.Errorf("InsertMetricsBatch: %v", err)
+	}
+	if history, err := store.GetHistoryMetrics(node.ID, 4); err != nil || len(history) != 5 {
+		return fmt.Errorf("expected 5 samples after batch insert, got %d, %v", len(history), err)
+	}
+
+	// 最近2小时内有两条数据
+	stats, err := store.GetMetricsStats(now.Add(-2 * time.Hour))
+	if err != nil {
+		return fmt.Errorf("GetMetricsStats: %v", err)
+	}
+	s := stats[node.ID]
+	if s.Samples != 2 || s.CPUMin != 20 || s.CPUMax != 30 || s.CPUAvg != 25 || s.TempMax != 40 {
+		return fmt.Errorf("unexpected stats %+v", s)
+	}
+
+	if _, err := store.GetLatestMetrics(node.ID + 1000); err == nil {
+		return fmt.Errorf("expected error for node without metrics")
+	}
+	return nil
+}
+
+func checkAPIKeys(store database.Store) error {
+	admin, err := store.GetUserByUsername("admin")
+	if err != nil {
+		return fmt.Errorf("GetUserByUsername: %v", err)
+	}
+
+	key := &models.APIKey{
+		UserID:    admin.ID,
+		Name:      "conformance",
+		Prefix:    "mpk_conform",
+		KeyHash:   "conformance-hash",
+		Scopes:    []string{"metrics:read", "nodes:write"},
+		ExpiresAt: time.Now().UTC().Add(time.Hour).Format("2006-01-02 15:04:05"),
+	}
+	if err := store.CreateAPIKey(key); err != nil {
+		return fmt.Errorf("CreateAPIKey: %v", err)
+	}
+	if key.ID == 0 {
+		return fmt.Errorf("CreateAPIKey did not set id")
+	}
+
+	got, err := store.GetAPIKeyByHash("conformance-hash")
+	if err != nil {
+		return fmt.Errorf("GetAPIKeyByHash: %v", err)
+	}
+	if got.ID != key.ID || len(got.Scopes) != 2 || got.ExpiresAt == "" || got.LastUsed != "" {
+		return fmt.Errorf("unexpected key %+v", got)
+	}
+
+	if err := store.TouchAPIKey(key.ID, time.Now()); err != nil {
+		return fmt.Errorf("TouchAPIKey: %v", err)
+	}
+	got, err = store.GetAPIKeyByHash("conformance-hash")
+	if err != nil {
+		return fmt.Errorf("GetAPIKeyByHash: %v", err)
+	}
+	if got.LastUsed == "" {
+		return fmt.Errorf("TouchAPIKey did not record last use")
+	}
+
+	keys, err := store.GetAPIKeysByUser(admin.ID)
+	if err != nil {
+		return fmt.Errorf("GetAPIKeysByUser: %v", err)
+	}
+	if len(keys) != 1 {
+		return fmt.Errorf("expected 1 key, got %d", len(keys))
+	}
+
+	if deleted, err := store.DeleteAPIKey(key.ID, admin.ID+1); err != nil || deleted {
+		return fmt.Errorf("deleting another user's key should fail, got deleted=%v err=%v", deleted, err)
+	}
+	if deleted, err := store.DeleteAPIKey(key.ID, admin.ID); err != nil || !deleted {
+		return fmt.Errorf("DeleteAPIKey: deleted=%v err=%v", deleted, err)
+	}
+	if _, err := store.GetAPIKeyByHash("conformance-hash"); err == nil {
+		return fmt.Errorf("expected deleted key to be gone")
+	}
+	return nil
+}
+
+func checkLabels(store database.Store) error {
+	if err := store.CreateOrUpdateNode("conf-labels", "10.99.0.20"); err != nil {
+		return fmt.Errorf("CreateOrUpdateNode: %v", err)
+	}
+	node, err := store.GetNodeByIP("10.99.0.20")
+	if err != nil {
+		return fmt.Errorf("GetNodeByIP: %v", err)
+	}
+
+	if changed, err := store.SetAgentLabels(node.ID, map[string]string{"env": "prod", "rack": "a3"}); err != nil || !changed {
+		return fmt.Errorf("SetAgentLabels: changed = %v, %v", changed, err)
+	}
+	// 标签未变化
+	if changed, err := store.SetAgentLabels(node.ID, map[string]string{"env": "prod", "rack": "a3"}); err != nil || changed {
+		return fmt.Errorf("SetAgentLabels unchanged: changed = %v, %v", changed, err)
+	}
+	if err := store.SetAdminLabels(node.ID, map[string]string{"env": "staging", "owner": "ops"}); err != nil {
+		return fmt.Errorf("SetAdminLabels: %v", err)
+	}
+	// Agent再次上报不会覆盖管理员设置的标签，删除的Agent标签会被移除
+	if changed, err := store.SetAgentLabels(node.ID, map[string]string{"env": "prod"}); err != nil || !changed {
+		return fmt.Errorf("SetAgentLabels: changed = %v, %v", changed, err)
+	}
+
+	got, err := store.GetNodeByID(node.ID)
+	if err != nil {
+		return fmt.Errorf("GetNodeByID: %v", err)
+	}
+	want := map[string]string{"env": "staging", "owner": "ops"}
+	if len(got.Labels) != len(want) {
+		return fmt.Errorf("expected labels %v, got %v", want, got.Labels)
+	}
+	for k, v := range want {
+		if got.Labels[k] != v {
+			return fmt.Errorf("expected labels %v, got %v", want, got.Labels)
+		}
+	}
+
+	// 清除管理员标签后Agent标签重新生效
+	if err := store.SetAdminLabels(node.ID, nil); err != nil {
+		return fmt.Errorf("SetAdminLabels: %v", err)
+	}
+	if changed, err := store.SetAgentLabels(node.ID, map[string]string{"env": "prod"}); err != nil || !changed {
+		return fmt.Errorf("SetAgentLabels: changed = %v, %v", changed, err)
+	}
+	labels, err := store.GetNodeLabels(node.ID)
+	if err != nil {
+		return fmt.Errorf("GetNodeLabels: %v", err)
+	}
+	if len(labels) != 1 || labels["env"] != "prod" {
+		return fmt.Errorf("expected only env=prod, got %v", labels)
+	}
+
+	group := &models.NodeGroup{Name: "conf-group", Selector: "env=prod"}
+	if err := store.CreateGroup(group); err != nil {
+		return fmt.Errorf("CreateGroup: %v", err)
+	}
+	if err := store.CreateGroup(&models.NodeGroup{Name: "conf-group", Selector: "x"}); err == nil {
+		return fmt.Errorf("expected duplicate group name to fail")
+	}
+	byName, err := store.GetGroupByName("conf-group")
+	if err != nil || byName.ID != group.ID || byName.Selector != "env=prod" {
+		return fmt.Errorf("GetGroupByName: %+v, %v", byName, err)
+	}
+	groups, err := store.GetGroups()
+	if err != nil || len(groups) != 1 {
+		return fmt.Errorf("GetGroups: %v, %v", groups, err)
+	}
+	if deleted, err := store.DeleteGroup(group.ID); err != nil || !deleted {
+		return fmt.Errorf("DeleteGroup: deleted=%v err=%v", deleted, err)
+	}
+	return nil
+}
+
+func checkLifecycle(store database.Store) error {
+	for _, ip := range []string{"10.99.0.30", "10.99.0.31"} {
+		if err := store.CreateOrUpdateNode("conf-life", ip); err != nil {
+			return fmt.Errorf("CreateOrUpdateNode: %v", err)
+		}
+	}
+	target, err := store.GetNodeByIP("10.99.0.30")
+	if err != nil {
+		return fmt.Errorf("GetNodeByIP: %v", err)
+	}
+	source, err := store.GetNodeByIP("10.99.0.31")
+	if err != nil {
+		return fmt.Errorf("GetNodeByIP: %v", err)
+	}
+
+	// 管理员改过的名称不被上报覆盖
+	if ok, err := store.RenameNode(target.ID, "Life Primary"); err != nil || !ok {
+		return fmt.Errorf("RenameNode: ok=%v err=%v", ok, err)
+	}
+	if err := store.CreateOrUpdateNode("conf-life", "10.99.0.30"); err != nil {
+		return fmt.Errorf("CreateOrUpdateNode: %v", err)
+	}
+	target, err = store.GetNodeByID(target.ID)
+	if err != nil || target.Name != "Life Primary" || target.AgentName != "conf-life" {
+		return fmt.Errorf("expected rename to stick, got %+v, %v", target, err)
+	}
+
+	for _, id := range []int{target.ID, source.ID} {
+		err := store.InsertMetrics(&models.AgentMetrics{NodeID: id, CPUPercent: 1, Timestamp: time.Now().UTC()})
+		if err != nil {
+			return fmt.Errorf("InsertMetrics: %v", err)
+		}
+	}
+	if _, err := store.SetAgentLabels(source.ID, map[string]string{"env": "prod"}); err != nil {
+		return fmt.Errorf("SetAgentLabels: %v", err)
+	}
+
+	if err := store.MergeNodes(target.ID, source.ID); err != nil {
+		return fmt.Errorf("MergeNodes: %v", err)
+	}
+	history, err := store.GetHistoryMetrics(target.ID, 1)
+	if err != nil || len(history) != 2 {
+		return fmt.Errorf("expected 2 merged samples, got %d, %v", len(history), err)
+	}
+	if _, err := store.GetNodeByID(source.ID); err == nil {
+		return fmt.Errorf("expected source node to be removed")
+	}
+	if labels, _ := store.GetNodeLabels(target.ID); labels["env"] != "prod" {
+		return fmt.Errorf("expected labels to be merged, got %v", labels)
+	}
+
+	// 源节点的Agent继续从原地址上报时计入目标节点，不会重新创建源节点
+	before, err := store.GetAllNodes()
+	if err != nil {
+		return fmt.Errorf("GetAllNodes: %v", err)
+	}
+	if err := store.CreateOrUpdateNode("conf-life", "10.99.0.31"); err != nil {
+		return fmt.Errorf("CreateOrUpdateNode: %v", err)
+	}
+	if after, err := store.GetAllNodes(); err != nil || len(after) != len(before) {
+		return fmt.Errorf("expected no new node after re-report from merged IP, got %d nodes (was %d), %v", len(after), len(before), err)
+	}
+	if node, err := store.GetNodeByIP("10.99.0.31"); err != nil || node.ID != target.ID || node.Name != "Life Primary" {
+		return fmt.Errorf("expected merged IP to resolve to target, got %+v, %v", node, err)
+	}
+
+	// 以证书身份标识的节点：源节点的名称成为目标节点的别名
+	primary, err := store.UpsertNodeByName("conf-alias-a", "10.99.0.32")
+	if err != nil {
+		return fmt.Errorf("UpsertNodeByName: %v", err)
+	}
+	duplicate, err := store.UpsertNodeByName("conf-alias-b", "10.99.0.33")
+	if err != nil {
+		return fmt.Errorf("UpsertNodeByName: %v", err)
+	}
+	if err := store.MergeNodes(primary.ID, duplicate.ID); err != nil {
+		return fmt.Errorf("MergeNodes: %v", err)
+	}
+	node, err := store.UpsertNodeByName("conf-alias-b", "10.99.0.34")
+	if err != nil || node.ID != primary.ID || node.AgentName != "conf-alias-a" {
+		return fmt.Errorf("expected merged name to resolve to target, got %+v, %v", node, err)
+	}
+	if ok, err := store.DeleteNode(primary.ID); err != nil || !ok {
+		return fmt.Errorf("DeleteNode: ok=%v err=%v", ok, err)
+	}
+	if _, err := store.GetNodeByName("conf-alias-b"); err == nil {
+		return fmt.Errorf("expected aliases to be deleted with node")
+	}
+
+	// 归档的节点重新上报后恢复，退役的节点不会恢复
+	if ok, err := store.SetNodeLifecycle(target.ID, models.NodeArchived); err != nil || !ok {
+		return fmt.Errorf("SetNodeLifecycle: ok=%v err=%v", ok, err)
+	}
+	if err := store.CreateOrUpdateNode("conf-life", "10.99.0.30"); err != nil {
+		return fmt.Errorf("CreateOrUpdateNode: %v", err)
+	}
+	if node, _ := store.GetNodeByID(target.ID); node == nil || node.Lifecycle != models.NodeActive {
+		return fmt.Errorf("expected archived node to become active after reporting")
+	}
+	if _, err := store.SetNodeLifecycle(target.ID, models.NodeDecommissioned); err != nil {
+		return fmt.Errorf("SetNodeLifecycle: %v", err)
+	}
+	if err := store.CreateOrUpdateNode("conf-life", "10.99.0.30"); err != nil {
+		return fmt.Errorf("CreateOrUpdateNode: %v", err)
+	}
+	if node, _ := store.GetNodeByID(target.ID); node == nil || node.Lifecycle != models.NodeDecommissioned || node.Status != "offline" {
+		return fmt.Errorf("expected decommissioned node to stay offline, got %+v", node)
+	}
+
+	if ok, err := store.DeleteNode(target.ID); err != nil || !ok {
+		return fmt.Errorf("DeleteNode: ok=%v err=%v", ok, err)
+	}
+	if history, _ := store.GetHistoryMetrics(target.ID, 1); len(history) != 0 {
+		return fmt.Errorf("expected metrics to be deleted with node")
+	}
+	return nil
+}
+
+func checkInventory(store database.Store) error {
+	if err := store.CreateOrUpdateNode("conf-inv", "10.99.0.40"); err != nil {
+		return fmt.Errorf("CreateOrUpdateNode: %v", err)
+	}
+	node, err := store.GetNodeByIP("10.99.0.40")
+	if err != nil {
+		return fmt.Errorf("GetNodeByIP: %v", err)
+	}
+	if _, err := store.GetNodeInventory(node.ID); err != sql.ErrNoRows {
+		return fmt.Errorf("expected sql.ErrNoRows before first report, got %v", err)
+	}
+
+	info := models.HostInfo{
+		Hostname: "conf-inv", OS: "linux", Platform: "debian", PlatformVersion: "12",
+		KernelVersion: "6.1.0", Arch: "x86_64", CPUModel: "Test CPU", CPUCores: 4,
+		MemoryTotal: 8 << 30, BootTime: uint64(time.Now().Add(-time.Hour).Unix()), AgentVersion: "1.0.0",
+	}
+	if err := store.UpdateNodeInventory(node.ID, &info); err != nil {
+		return fmt.Errorf("UpdateNodeInventory: %v", err)
+	}
+	inv, err := store.GetNodeInventory(node.ID)
+	if err != nil || inv.HostInfo != info || inv.Uptime < 3500 {
+		return fmt.Errorf("GetNodeInventory: %+v, %v", inv, err)
+	}
+	if events, _ := store.GetNodeEvents(node.ID, 10); len(events) != 0 {
+		return fmt.Errorf("expected no events on first report, got %v", events)
+	}
+
+	// 启动时间和Agent版本变化各记录一条事件
+	info.BootTime = uint64(time.Now().Unix())
+	info.AgentVersion = "1.1.0"
+	if err := store.UpdateNodeInventory(node.ID, &info); err != nil {
+		return fmt.Errorf("UpdateNodeInventory: %v", err)
+	}
+	events, err := store.GetNodeEvents(node.ID, 10)
+	if err != nil || len(events) != 2 {
+		return fmt.Errorf("expected 2 events, got %v, %v", events, err)
+	}
+	types := map[string]bool{events[0].Type: true, events[1].Type: true}
+	if !types[models.EventReboot] || !types[models.EventAgentUpdated] {
+		return fmt.Errorf("unexpected event types: %v", events)
+	}
+
+	event := &models.NodeEvent{NodeID: node.ID, Type: "custom", Message: "test"}
+	if err := store.AddNodeEvent(event); err != nil || event.ID == 0 {
+		return fmt.Errorf("AddNodeEvent: %+v, %v", event, err)
+	}
+	if events, _ := store.GetNodeEvents(node.ID, 1); len(events) != 1 || events[0].ID != event.ID {
+		return fmt.Errorf("expected latest event first, got %v", events)
+	}
+
+	if ok, err := store.DeleteNode(node.ID); err != nil || !ok {
+		return fmt.Errorf("DeleteNode: ok=%v err=%v", ok, err)
+	}
+	if _, err := store.GetNodeInventory(node.ID); err != sql.ErrNoRows {
+		return fmt.Errorf("expected inventory to be deleted with node, got %v", err)
+	}
+	return nil
+}
+
+func checkScrape(store database.Store) error {
+	target := &models.ScrapeTarget{Name: "conf-pull", URL: "http://10.99.0.50:9101/metrics"}
+	if err := store.CreateScrapeTarget(target); err != nil || target.ID == 0 {
+		return fmt.Errorf("CreateScrapeTarget: %+v, %v", target, err)
+	}
+	if err := store.CreateScrapeTarget(&models.ScrapeTarget{URL: target.URL}); err == nil {
+		return fmt.Errorf("expected duplicate url to fail")
+	}
+	if err := store.UpdateScrapeStatus(target.ID, time.Now(), "connection refused"); err != nil {
+		return fmt.Errorf("UpdateScrapeStatus: %v", err)
+	}
+	targets, err := store.GetScrapeTargets()
+	if err != nil || len(targets) != 1 || targets[0].LastScrape == "" || targets[0].LastError != "connection refused" {
+		return fmt.Errorf("GetScrapeTargets: %+v, %v", targets, err)
+	}
+	if deleted, err := store.DeleteScrapeTarget(target.ID); err != nil || !deleted {
+		return fmt.Errorf("DeleteScrapeTarget: deleted=%v err=%v", deleted, err)
+	}
+
+	// 刚上报的节点不受影响，以未来时间为界时被标记为离线
+	if err := store.CreateOrUpdateNode("conf-live", "10.99.0.51"); err != nil {
+		return fmt.Errorf("CreateOrUpdateNode: %v", err)
+	}
+	if _, err := store.MarkStaleNodesOffline(time.Now().Add(-time.Minute)); err != nil {
+		return fmt.Errorf("MarkStaleNodesOffline: %v", err)
+	}
+	if node, _ := store.GetNodeByIP("10.99.0.51"); node == nil || node.Status != "online" {
+		return fmt.Errorf("expected recently seen node to stay online, got %+v", node)
+	}
+	if n, err := store.MarkStaleNodesOffline(time.Now().Add(time.Minute)); err != nil || n == 0 {
+		return fmt.Errorf("MarkStaleNodesOffline: n=%d err=%v", n, err)
+	}
+	if node, _ := store.GetNodeByIP("10.99.0.51"); node == nil || node.Status != "offline" {
+		return fmt.Errorf("expected stale node to be offline, got %+v", node)
+	}
+
+	// 连接断开时立即标记为离线，已离线的节点不再重复标记
+	if err := store.CreateOrUpdateNode("conf-live", "10.99.0.51"); err != nil {
+		return fmt.Errorf("CreateOrUpdateNode: %v", err)
+	}
+	node, _ := store.GetNodeByIP("10.99.0.51")
+	if node == nil || node.Status != "online" {
+		return fmt.Errorf("expected reporting node to be online, got %+v", node)
+	}
+	if marked, err := store.MarkNodeOffline(node.ID); err != nil || !marked {
+		return fmt.Errorf("MarkNodeOffline: marked=%v err=%v", marked, err)
+	}
+	if marked, err := store.MarkNodeOffline(node.ID); err != nil || marked {
+		return fmt.Errorf("MarkNodeOffline on offline node: marked=%v err=%v", marked, err)
+	}
+	if node, _ := store.GetNodeByIP("10.99.0.51"); node == nil || node.Status != "offline" {
+		return fmt.Errorf("expected disconnected node to be offline, got %+v", node)
+	}
+	return nil
+}
+
+func checkConfigProfiles(store database.Store) error {
+	interval, temp := 10, false
+	profile := &models.ConfigProfile{
+		Name:     "conf-profile",
+		Selector: "env=prod",
+		Priority: 5,
+		Config: models.ManagedAgentConfig{
+			Agent:     &models.ManagedAgentSection{Interval: &interval},
+			Collector: &models.ManagedCollectorSection{Temp: &temp},
+		},
+	}
+	if err := store.CreateConfigProfile(profile); err != nil || profile.ID == 0 {
+		return fmt.Errorf("CreateConfigProfile: %+v, %v", profile, err)
+	}
+	if err := store.CreateConfigProfile(&models.ConfigProfile{Name: profile.Name}); err == nil {
+		return fmt.Errorf("expected duplicate profile name to fail")
+	}
+
+	got, err := store.GetConfigProfile(profile.ID)
+	if err != nil || got.Selector != "env=prod" || got.Config.Agent == nil || *got.Config.Agent.Interval != 10 ||
+		got.Config.Collector == nil || *got.Config.Collector.Temp {
+		return fmt.Errorf("GetConfigProfile: %+v, %v", got, err)
+	}
+
+	interval = 20
+	profile.Priority = 1
+	if updated, err := store.UpdateConfigProfile(profile); err != nil || !updated {
+		return fmt.Errorf("UpdateConfigProfile: updated=%v err=%v", updated, err)
+	}
+	profiles, err := store.GetConfigProfiles()
+	if err != nil || len(profiles) != 1 || *profiles[0].Config.Agent.Interval != 20 || profiles[0].Priority != 1 {
+		return fmt.Errorf("GetConfigProfiles: %+v, %v", profiles, err)
+	}
+
+	// 指定节点的配置随节点一起删除
+	if err := store.CreateOrUpdateNode("conf-profile-node", "10.99.0.60"); err != nil {
+		return fmt.Errorf("CreateOrUpdateNode: %v", err)
+	}
+	node, err := store.GetNodeByIP("10.99.0.60")
+	if err != nil {
+		return fmt.Errorf("GetNodeByIP: %v", err)
+	}
+	own := &models.ConfigProfile{Name: "conf-profile-node", NodeID: node.ID}
+	if err := store.CreateConfigProfile(own); err != nil {
+		return fmt.Errorf("CreateConfigProfile: %v", err)
+	}
+	if _, err := store.DeleteNode(node.ID); err != nil {
+		return fmt.Errorf("DeleteNode: %v", err)
+	}
+	if _, err := store.GetConfigProfile(own.ID); err != sql.ErrNoRows {
+		return fmt.Errorf("expected node profile to be deleted with the node, got %v", err)
+	}
+
+	if deleted, err := store.DeleteConfigProfile(profile.ID); err != nil || !deleted {
+		return fmt.Errorf("DeleteConfigProfile: deleted=%v err=%v", deleted, err)
+	}
+	return nil
+}
+
+func checkReleases(store database.Store) error {
+	rel := &models.AgentRelease{Version: "9.9.9", OS: "linux", Arch: "amd64", SHA256: "ab", Signature: "sig", Size: 3 << 30}
+	if err := store.CreateRelease(rel); err != nil || rel.ID == 0 {
+		return fmt.Errorf("CreateRelease: %+v, %v", rel, err)
+	}
+	if err := store.CreateRelease(&models.AgentRelease{Version: "9.9.9", OS: "linux", Arch: "amd64"}); err == nil {
+		return fmt.Errorf("expected duplicate release to fail")
+	}
+
+	got, err := store.GetRelease("9.9.9", "linux", "amd64")
+	if err != nil || got.ID != rel.ID || got.Size != 3<<30 || got.Signature != "sig" {
+		return fmt.Errorf("GetRelease: %+v, %v", got, err)
+	}
+	if _, err := store.GetRelease("9.9.9", "linux", "arm64"); err != sql.ErrNoRows {
+		return fmt.Errorf("expected sql.ErrNoRows for missing release, got %v", err)
+	}
+	releases, err := store.GetReleases()
+	if err != nil || len(releases) != 1 {
+		return fmt.Errorf("GetReleases: %+v, %v", releases, err)
+	}
+	if deleted, err := store.DeleteRelease(rel.ID); err != nil || !deleted {
+		return fmt.Errorf("DeleteRelease: deleted=%v err=%v", deleted, err)
+	}
+	if _, err := store.GetReleaseByID(rel.ID); err != sql.ErrNoRows {
+		return fmt.Errorf("expected deleted release to be gone, got %v", err)
+	}
+	return nil
+}
+
+func checkClockSkew(store database.Store) error {
+	if err := store.CreateOrUpdateNode("conf-clock", "10.99.0.80"); err != nil {
+		return fmt.Errorf("CreateOrUpdateNode: %v", err)
+	}
+	node, err := store.GetNodeByIP("10.99.0.80")
+	if err != nil || node.ClockSkewMs != 0 {
+		return fmt.Errorf("GetNodeByIP: %+v, %v", node, err)
+	}
+
+	if err := store.SetNodeClockSkew(node.ID, -90500); err != nil {
+		return fmt.Errorf("SetNodeClockSkew: %v", err)
+	}
+	if node, err := store.GetNodeByID(node.ID); err != nil || node.ClockSkewMs != -90500 {
+		return fmt.Errorf("expected clock skew to be saved, got %+v, %v", node, err)
+	}
+
+	// 不同时区的时间按 UTC 保存：西八区的30分钟前仍在最近1小时内
+	zone := time.FixedZone("UTC-8", -8*3600)
+	now := time.Now()
+	batch := []*models.AgentMetrics{
+		{NodeID: node.ID, CPUPercent: 1, Timestamp: now.Add(-30 * time.Minute).In(zone)},
+		{NodeID: node.ID, CPUPercent: 2, Timestamp: now.Add(-2 * time.Hour).In(zone)},
+	}
+	if err := store.InsertMetricsBatch(batch); err != nil {
+		return fmt.Errorf("InsertMetricsBatch: %v", err)
+	}
+	if err := store.InsertMetrics(&models.AgentMetrics{NodeID: node.ID, CPUPercent: 3, Timestamp: now.Add(-10 * time.Minute).In(zone)}); err != nil {
+		return fmt.Errorf("InsertMetrics: %v", err)
+	}
+	samples, err := store.GetMetricsSince(node.ID, now.Add(-time.Hour))
+	if err != nil {
+		return fmt.Errorf("GetMetricsSince: %v", err)
+	}
+	if len(samples) != 2 || samples[0].CPUPercent != 3 || samples[1].CPUPercent != 1 {
+		return fmt.Errorf("expected 2 samples in last hour ordered by UTC time, got %+v", samples)
+	}
+
+	// 数据库写入的其他时间也是 UTC，与数据库会话时区无关
+	if err := store.CreateOrUpdateNode("conf-clock", "10.99.0.80"); err != nil {
+		return fmt.Errorf("CreateOrUpdateNode: %v", err)
+	}
+	event := &models.NodeEvent{NodeID: node.ID, Type: models.EventClockSkew, Message: "test"}
+	if err := store.AddNodeEvent(event); err != nil {
+		return fmt.Errorf("AddNodeEvent: %v", err)
+	}
+	node, err = store.GetNodeByID(node.ID)
+	if err != nil {
+		return fmt.Errorf("GetNodeByID: %v", err)
+	}
+	events, err := store.GetNodeEvents(node.ID, 1)
+	if err != nil || len(events) != 1 {
+		return fmt.Errorf("GetNodeEvents: %+v, %v", events, err)
+	}
+	for name, value := range map[string]string{"last_seen": node.LastSeen, "event created_at": events[0].CreatedAt} {
+		if err := checkRecentUTC(value, now); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// checkRecentUTC 数据库返回的时间按 UTC 解释时应与 now 相差不超过一分钟
+func checkRecentUTC(value string, now time.Time) error {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		t, err = time.Parse("2006-01-02 15:04:05", value)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot parse %q: %v", value, err)
+	}
+	if d := t.Sub(now); d > time.Minute || d < -time.Minute {
+		return fmt.Errorf("%q is %v away from now, expected UTC", value, d.Round(time.Second))
+	}
+	return nil
+}
+
+func checkServiceChecks(store database.Store) error {
+	if err := store.CreateOrUpdateNode("conf-checks", "10.99.0.90"); err != nil {
+		return fmt.Errorf("CreateOrUpdateNode: %v", err)
+	}
+	node, err := store.GetNodeByIP("10.99.0.90")
+	if err != nil {
+		return fmt.Errorf("GetNodeByIP: %v", err)
+	}
+
+	// 第一次上报：每个检查都记录一条历史
+	reported := time.Now().UTC().Truncate(time.Second)
+	events, err := store.UpdateNodeChecks(node.ID, reported, []models.CheckResult{
+		{Name: "web", Type: "http", Status: models.CheckOK, LatencyMs: 12.5, Message: "status 200"},
+		{Name: "db", Type: "tcp", Status: models.CheckFail, LatencyMs: 5000, Message: "i/o timeout"},
+	})
+	if err != nil || len(events) != 2 {
+		return fmt.Errorf("UpdateNodeChecks: %+v, %v", events, err)
+	}
+	checks, err := store.GetNodeChecks(node.ID)
+	if err != nil || len(checks) != 2 || checks[0].Name != "db" || checks[1].LatencyMs != 12.5 {
+		return fmt.Errorf("GetNodeChecks: %+v, %v", checks, err)
+	}
+	since := checks[1].Since
+
+	// 状态不变时不记录历史，保留进入该状态的时间；状态变化时记录历史
+	events, err = store.UpdateNodeChecks(node.ID, reported.Add(10*time.Second), []models.CheckResult{
+		{Name: "web", Type: "http", Status: models.CheckOK, LatencyMs: 8, Message: "status 200"},
+		{Name: "db", Type: "tcp", Status: models.CheckOK, LatencyMs: 1},
+	})
+	if err != nil || len(events) != 1 || events[0].Name != "db" || events[0].PreviousStatus != models.CheckFail {
+		return fmt.Errorf("expected one status change, got %+v, %v", events, err)
+	}
+	checks, err = store.GetNodeChecks(node.ID)
+	if err != nil || len(checks) != 2 || checks[1].Since != since || checks[1].LatencyMs != 8 || checks[0].Since == since {
+		return fmt.Errorf("expected since to change only with status, got %+v, %v", checks, err)
+	}
+
+	// 补发的旧数据不覆盖较新的状态，也不记录历史
+	events, err = store.UpdateNodeChecks(node.ID, reported.Add(5*time.Second), []models.CheckResult{
+		{Name: "db", Type: "tcp", Status: models.CheckFail, Message: "i/o timeout"},
+	})
+	if err != nil || len(events) != 0 {
+		return fmt.Errorf("expected replayed report to be ignored, got %+v, %v", events, err)
+	}
+	if replayed, err := store.GetNodeChecks(node.ID); err != nil || len(replayed) != 2 || replayed[0].Status != models.CheckOK {
+		return fmt.Errorf("expected state to be kept after replay, got %+v, %v", replayed, err)
+	}
+
+	history, err := store.GetCheckEvents(node.ID, "db", 10)
+	if err != nil || len(history) != 2 || history[0].Status != models.CheckOK || history[1].Status != models.CheckFail {
+		return fmt.Errorf("GetCheckEvents: %+v, %v", history, err)
+	}
+	if history, err := store.GetCheckEvents(node.ID, "", 1); err != nil || len(history) != 1 {
+		return fmt.Errorf("expected limit to apply, got %+v, %v", history, err)
+	}
+
+	// 不再上报的检查被删除
+	if _, err := store.UpdateNodeChecks(node.ID, reported.Add(20*time.Second), []models.CheckResult{
+		{Name: "web", Type: "http", Status: models.CheckFail, Message: "unexpected status 502"},
+	}); err != nil {
+		return fmt.Errorf("UpdateNodeChecks: %v", err)
+	}
+	checks, err = store.GetNodeChecks(node.ID)
+	if err != nil || len(checks) != 1 || checks[0].Name != "web" {
+		return fmt.Errorf("expected removed check to be deleted, got %+v, %v", checks, err)
+	}
+
+	failing, err := store.GetChecks(models.CheckFail)
+	if err != nil {
+		return fmt.Errorf("GetChecks: %v", err)
+	}
+	found := false
+	for _, check := range failing {
+		if check.Status != models.CheckFail {
+			return fmt.Errorf("GetChecks returned %+v for status fail", check)
+		}
+		found = found || check.NodeID == node.ID && check.Name == "web"
+	}
+	if !found {
+		return fmt.Errorf("expected failing check in %+v", failing)
+	}
+
+	// 合并节点时保留目标节点的检查，历史并入目标节点
+	if err := store.CreateOrUpdateNode("conf-checks-old", "10.99.0.91"); err != nil {
+		return fmt.Errorf("CreateOrUpdateNode: %v", err)
+	}
+	old, err := store.GetNodeByIP("10.99.0.91")
+	if err != nil {
+		return fmt.Errorf("GetNodeByIP: %v", err)
+	}
+	if _, err := store.UpdateNodeChecks(old.ID, reported, []models.CheckResult{{Name: "cron", Type: "file", Status: models.CheckOK}}); err != nil {
+		return fmt.Errorf("UpdateNodeChecks: %v", err)
+	}
+	if err := store.MergeNodes(node.ID, old.ID); err != nil {
+		return fmt.Errorf("MergeNodes: %v", err)
+	}
+	if checks, err := store.GetNodeChecks(node.ID); err != nil || len(checks) != 1 || checks[0].Name != "web" {
+		return fmt.Errorf("expected target checks to be kept, got %+v, %v", checks, err)
+	}
+	if history, err := store.GetCheckEvents(node.ID, "cron", 10); err != nil || len(history) != 1 {
+		return fmt.Errorf("expected source history to be merged, got %+v, %v", history, err)
+	}
+
+	if _, err := store.DeleteNode(node.ID); err != nil {
+		return fmt.Errorf("DeleteNode: %v", err)
+	}
+	if checks, err := store.GetNodeChecks(node.ID); err != nil || len(checks) != 0 {
+		return fmt.Errorf("expected checks to be deleted with node, got %+v, %v", checks, err)
+	}
+	if history, err := store.GetCheckEvents(node.ID, "", 10); err != nil || len(history) != 0 {
+		return fmt.Errorf("expected check history to be deleted with node, got %+v, %v", history, err)
+	}
+	return nil
+}
+
+func checkUnits(store database.Store) error {
+	if err := store.CreateOrUpdateNode("conf-units", "10.99.0.100"); err != nil {
+		return fmt.Errorf("CreateOrUpdateNode: %v", err)
+	}
+	node, err := store.GetNodeByIP("10.99.0.100")
+	if err != nil {
+		return fmt.Errorf("GetNodeByIP: %v", err)
+	}
+
+	// 第一次上报：每个单元都记录一条历史
+	reported := time.Now().UTC().Truncate(time.Second)
+	events, err := store.UpdateNodeUnits(node.ID, reported, []models.UnitStatus{
+		{Name: "nginx.service", LoadState: "loaded", ActiveState: "active", SubState: "running", MemoryBytes: 8 << 20},
+		{Name: "backup.service", LoadState: "loaded", ActiveState: "failed", SubState: "failed", Restarts: 2},
+	})
+	if err != nil || len(events) != 2 {
+		return fmt.Errorf("UpdateNodeUnits: %+v, %v", events, err)
+	}
+	units, err := store.GetNodeUnits(node.ID)
+	if err != nil || len(units) != 2 || units[0].Name != "backup.service" || units[1].MemoryBytes != 8<<20 {
+		return fmt.Errorf("GetNodeUnits: %+v, %v", units, err)
+	}
+	since := units[1].Since
+
+	// 状态和重启次数不变时不记录历史，只更新内存；重启次数增加时即使状态不变也记录
+	events, err = store.UpdateNodeUnits(node.ID, reported.Add(10*time.Second), []models.UnitStatus{
+		{Name: "nginx.service", LoadState: "loaded", ActiveState: "active", SubState: "running", MemoryBytes: 9 << 20},
+		{Name: "backup.service", LoadState: "loaded", ActiveState: "failed", SubState: "failed", Restarts: 3},
+	})
+	if err != nil || len(events) != 1 || events[0].Name != "backup.service" || events[0].PreviousRestarts != 2 || events[0].Restarts != 3 {
+		return fmt.Errorf("expected one restart event, got %+v, %v", events, err)
+	}
+	units, err = store.GetNodeUnits(node.ID)
+	if err != nil || units[1].Since != since || units[1].MemoryBytes != 9<<20 {
+		return fmt.Errorf("expected since to be kept, got %+v, %v", units, err)
+	}
+
+	// 补发的旧数据不覆盖较新的状态，也不记录历史
+	events, err = store.UpdateNodeUnits(node.ID, reported.Add(5*time.Second), []models.UnitStatus{
+		{Name: "nginx.service", LoadState: "loaded", ActiveState: "failed", SubState: "failed", Restarts: 1},
+	})
+	if err != nil || len(events) != 0 {
+		return fmt.Errorf("expected replayed report to be ignored, got %+v, %v", events, err)
+	}
+	if replayed, err := store.GetNodeUnits(node.ID); err != nil || len(replayed) != 2 || replayed[1].ActiveState != "active" {
+		return fmt.Errorf("expected state to be kept after replay, got %+v, %v", replayed, err)
+	}
+
+	events, err = store.UpdateNodeUnits(node.ID, reported.Add(20*time.Second), []models.UnitStatus{
+		{Name: "nginx.service", LoadState: "loaded", ActiveState: "inactive", SubState: "dead"},
+	})
+	if err != nil || len(events) != 1 || events[0].PreviousActiveState != "active" || events[0].PreviousSubState != "running" {
+		return fmt.Errorf("expected one state change, got %+v, %v", events, err)
+	}
+	units, err = store.GetNodeUnits(node.ID)
+	if err != nil || len(units) != 1 || units[0].Since == since {
+		return fmt.Errorf("expected removed unit to be deleted and since to change, got %+v, %v", units, err)
+	}
+
+	history, err := store.GetUnitEvents(node.ID, "nginx.service", 10)
+	if err != nil || len(history) != 2 || history[0].ActiveState != "inactive" || history[1].ActiveState != "active" {
+		return fmt.Errorf("GetUnitEvents: %+v, %v", history, err)
+	}
+	if history, err := store.GetUnitEvents(node.ID, "", 10); err != nil || len(history) != 4 {
+		return fmt.Errorf("expected 4 unit events, got %+v, %v", history, err)
+	}
+
+	inactive, err := store.GetUnits("inactive")
+	if err != nil {
+		return fmt.Errorf("GetUnits: %v", err)
+	}
+	found := false
+	for _, unit := range inactive {
+		if unit.ActiveState != "inactive" {
+			return fmt.Errorf("GetUnits returned %+v for state inactive", unit)
+		}
+		found = found || unit.NodeID == node.ID && unit.Name == "nginx.service"
+	}
+	if !found {
+		return fmt.Errorf("expected inactive unit in %+v", inactive)
+	}
+
+	if _, err := store.DeleteNode(node.ID); err != nil {
+		return fmt.Errorf("DeleteNode: %v", err)
+	}
+	if units, err := store.GetNodeUnits(node.ID); err != nil || len(units) != 0 {
+		return fmt.Errorf("expected units to be deleted with node, got %+v, %v", units, err)
+	}
+	if history, err := store.GetUnitEvents(node.ID, "", 10); err != nil || len(history) != 0 {
+		return fmt.Errorf("expected unit history to be deleted with node, got %+v, %v", history, err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package dbtest
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"miniPanel/internal/config"
+	"miniPanel/internal/database"
+)
+
+// PostgresDSNEnv 设置后测试同时使用 PostgreSQL，每次打开使用新建的 schema，测试结束后删除
+const PostgresDSNEnv = "MINIPANEL_TEST_PG_DSN"
+
+// OpenSQLite 在临时目录中打开一个空的 SQLite 存储，测试结束后关闭
+func OpenSQLite(t testing.TB) *database.DB {
+	t.Helper()
+	store, err := database.Open(config.DatabaseConfig{
+		Driver:      database.DriverSQLite,
+		Path:        filepath.Join(t.TempDir(), "miniPanel.db"),
+		AutoMigrate: true,
+	})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// OpenPostgres 在新建的 schema 中打开 PostgreSQL 存储，params 为附加的连接参数（如 timezone）。
+// 没有设置 PostgresDSNEnv 时跳过测试
+func OpenPostgres(t testing.TB, params map[string]string) *database.DB {
+	t.Helper()
+	dsn := os.Getenv(PostgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", PostgresDSNEnv)
+	}
+
+	admin, err := sql.Open(database.DriverPostgres, dsn)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer admin.Close()
+	schema := fmt.Sprintf("minipanel_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		conn, err := sql.Open(database.DriverPostgres, dsn)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Exec("DROP SCHEMA " + schema + " CASCADE")
+	})
+
+	all := map[string]string{"search_path": schema}
+	for k, v := range params {
+		all[k] = v
+	}
+	store, err := database.Open(config.DatabaseConfig{
+		Driver:      database.DriverPostgres,
+		DSN:         withParams(dsn, all),
+		AutoMigrate: true,
+	})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// withParams 在 URL 或 key=value 形式的连接串后附加连接参数，lib/pq 把不认识的参数作为会话参数发送
+func withParams(dsn string, params map[string]string) string {
+	if strings.Contains(dsn, "://") {
+		u, err := url.Parse(dsn)
+		if err == nil {
+			q := u.Query()
+			for k, v := range params {
+				q.Set(k, v)
+			}
+			u.RawQuery = q.Encode()
+			return u.String()
+		}
+	}
+	for k, v := range params {
+		dsn += fmt.Sprintf(" %s=%s", k, v)
+	}
+	return dsn
+}
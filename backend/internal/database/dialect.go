@@ -0,0 +1,84 @@
+package database
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 支持的数据库驱动
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// timeFormat 写入数据库的时间格式，SQLite 和 PostgreSQL 都能正确解析
+const timeFormat = "2006-01-02 15:04:05"
+
+// utcNow 当前的 UTC 时间。写入的时间都由程序提供，不使用 CURRENT_TIMESTAMP 和列默认值：
+// PostgreSQL 的 TIMESTAMP 列按会话时区取值，与按 UTC 保存的监控数据时间不一致
+func utcNow() string {
+	return time.Now().UTC().Format(timeFormat)
+}
+
+// rebind 将 ? 占位符转换为当前驱动使用的格式
+func (db *DB) rebind(query string) string {
+	if db.driver != DriverPostgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$")
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (db *DB) exec(query string, args ...interface{}) (sql.Result, error) {
+	return db.conn.Exec(db.rebind(query), args...)
+}
+
+func (db *DB) query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.conn.Query(db.rebind(query), args...)
+}
+
+func (db *DB) queryRow(query string, args ...interface{}) *sql.Row {
+	return db.conn.QueryRow(db.rebind(query), args...)
+}
+
+// insert 执行INSERT并返回新记录ID，PostgreSQL 不支持 LastInsertId
+func (db *DB) insert(query string, args ...interface{}) (int64, error) {
+	if db.driver == DriverPostgres {
+		var id int64
+		err := db.queryRow(query+" RETURNING id", args...).Scan(&id)
+		return id, err
+	}
+
+	result, err := db.exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// withTx 在事务中执行 fn，fn 返回错误时回滚
+func (db *DB) withTx(fn func(tx *sql.Tx) error) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"miniPanel/internal/models"
+)
+
+// bootTimeTolerance 启动时间的允许误差（秒），部分系统上报的启动时间会有轻微抖动
+const bootTimeTolerance = 60
+
+const inventoryColumns = `node_id, hostname, os, platform, platform_version, kernel_version, arch,
+	cpu_model, cpu_cores, memory_total, boot_time, agent_version, updated_at`
+
+func scanInventory(row rowScanner) (*models.NodeInventory, error) {
+	inv := &models.NodeInventory{}
+	err := row.Scan(&inv.NodeID, &inv.Hostname, &inv.OS, &inv.Platform, &inv.PlatformVersion,
+		&inv.KernelVersion, &inv.Arch, &inv.CPUModel, &inv.CPUCores, &inv.MemoryTotal,
+		&inv.BootTime, &inv.AgentVersion, &inv.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if now := uint64(time.Now().Unix()); inv.BootTime > 0 && inv.BootTime < now {
+		inv.Uptime = now - inv.BootTime
+	}
+	return inv, nil
+}
+
+// GetNodeInventory 获取节点主机信息，从未上报时返回 sql.ErrNoRows
+func (db *DB) GetNodeInventory(nodeID int) (*models.NodeInventory, error) {
+	return scanInventory(db.queryRow("SELECT "+inventoryColumns+" FROM node_inventory WHERE node_id = ?", nodeID))
+}
+
+// UpdateNodeInventory 保存节点主机信息，并为重启、系统和Agent版本变化记录事件
+func (db *DB) UpdateNodeInventory(nodeID int, info *models.HostInfo) error {
+	prev, err := db.GetNodeInventory(nodeID)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	var events []string
+	var messages []string
+	if prev != nil {
+		if diff(prev.BootTime, info.BootTime) > bootTimeTolerance {
+			events = append(events, models.EventReboot)
+			messages = append(messages, fmt.Sprintf("Node rebooted at %s",
+				time.Unix(int64(info.BootTime), 0).UTC().Format(timeFormat)))
+		}
+		if prev.PlatformVersion != info.PlatformVersion || prev.KernelVersion != info.KernelVersion {
+			events = append(events, models.EventOSUpdated)
+			messages = append(messages, fmt.Sprintf("OS changed from %s %s (%s) to %s %s (%s)",
+				prev.Platform, prev.PlatformVersion, prev.KernelVersion,
+				info.Platform, info.PlatformVersion, info.KernelVersion))
+		}
+		if prev.AgentVersion != info.AgentVersion {
+			events = append(events, models.EventAgentUpdated)
+			messages = append(messages, fmt.Sprintf("Agent updated from %s to %s", prev.AgentVersion, info.AgentVersion))
+		}
+	}
+
+	return db.withTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec(db.rebind(`
+			INSERT INTO node_inventory (node_id, hostname, os, platform, platform_version, kernel_version, arch,
+				cpu_model, cpu_cores, memory_total, boot_time, agent_version, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (node_id) DO UPDATE SET
+				hostname = excluded.hostname, os = excluded.os, platform = excluded.platform,
+				platform_version = excluded.platform_version, kernel_version = excluded.kernel_version,
+				arch = excluded.arch, cpu_model = excluded.cpu_model, cpu_cores = excluded.cpu_cores,
+				memory_total = excluded.memory_total, boot_time = excluded.boot_time,
+				agent_version = excluded.agent_version, updated_at = excluded.updated_at`),
+			nodeID, info.Hostname, info.OS, info.Platform, info.PlatformVersion, info.KernelVersion, info.Arch,
+			info.CPUModel, info.CPUCores, info.MemoryTotal, info.BootTime, info.AgentVersion, utcNow())
+		if err != nil {
+			return err
+		}
+
+		for i := range events {
+			_, err := tx.Exec(db.rebind("INSERT INTO node_events (node_id, type, message, created_at) VALUES (?, ?, ?, ?)"),
+				nodeID, events[i], messages[i], utcNow())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func diff(a, b uint64) uint64 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+// AddNodeEvent 记录节点事件
+func (db *DB) AddNodeEvent(event *models.NodeEvent) error {
+	id, err := db.insert("INSERT INTO node_events (node_id, type, message, created_at) VALUES (?, ?, ?, ?)",
+		event.NodeID, event.Type, event.Message, utcNow())
+	if err != nil {
+		return err
+	}
+	event.ID = int(id)
+	return nil
+}
+
+// GetNodeEvents 获取节点最近的事件，按时间倒序
+func (db *DB) GetNodeEvents(nodeID int, limit int) ([]models.NodeEvent, error) {
+	rows, err := db.query(`
+		SELECT id, node_id, type, message, created_at FROM node_events
+		WHERE node_id = ? ORDER BY created_at DESC, id DESC LIMIT ?`,
+		nodeID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.NodeEvent
+	for rows.Next() {
+		var event models.NodeEvent
+		if err := rows.Scan(&event.ID, &event.NodeID, &event.Type, &event.Message, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,192 @@
+package database
+
+import (
+	"database/sql"
+
+	"miniPanel/internal/models"
+)
+
+// 标签来源，管理员设置的标签优先于Agent上报的同名标签
+const (
+	LabelSourceAgent = "agent"
+	LabelSourceAdmin = "admin"
+)
+
+// upsertLabelSQL 插入或覆盖标签，SQLite 和 PostgreSQL 都支持该语法
+const upsertLabelSQL = `
+	INSERT INTO node_labels (node_id, key, value, source) VALUES (?, ?, ?, ?)
+	ON CONFLICT (node_id, key) DO UPDATE SET value = excluded.value, source = excluded.source`
+
+// 标签相关操作
+func (db *DB) GetNodeLabels(nodeID int) (map[string]string, error) {
+	rows, err := db.query("SELECT key, value FROM node_labels WHERE node_id = ?", nodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	labels := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		labels[key] = value
+	}
+	return labels, rows.Err()
+}
+
+// getAllLabels 一次读取所有节点的标签
+func (db *DB) getAllLabels() (map[int]map[string]string, error) {
+	rows, err := db.query("SELECT node_id, key, value FROM node_labels")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	all := make(map[int]map[string]string)
+	for rows.Next() {
+		var nodeID int
+		var key, value string
+		if err := rows.Scan(&nodeID, &key, &value); err != nil {
+			return nil, err
+		}
+		if all[nodeID] == nil {
+			all[nodeID] = make(map[string]string)
+		}
+		all[nodeID][key] = value
+	}
+	return all, rows.Err()
+}
+
+// SetAgentLabels 同步Agent上报的标签，不覆盖管理员设置的同名标签，标签未变化时不写库。
+// 返回节点的标签是否因此变化
+func (db *DB) SetAgentLabels(nodeID int, labels map[string]string) (bool, error) {
+	rows, err := db.query("SELECT key, value, source FROM node_labels WHERE node_id = ?", nodeID)
+	if err != nil {
+		return false, err
+	}
+
+	agentLabels := make(map[string]string)
+	adminKeys := make(map[string]bool)
+	for rows.Next() {
+		var key, value, source string
+		if err := rows.Scan(&key, &value, &source); err != nil {
+			rows.Close()
+			return false, err
+		}
+		if source == LabelSourceAdmin {
+			adminKeys[key] = true
+		} else {
+			agentLabels[key] = value
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	var removed []string
+	for key := range agentLabels {
+		if _, ok := labels[key]; !ok {
+			removed = append(removed, key)
+		}
+	}
+	changed := make(map[string]string)
+	for key, value := range labels {
+		if adminKeys[key] {
+			continue
+		}
+		if current, ok := agentLabels[key]; !ok || current != value {
+			changed[key] = value
+		}
+	}
+	if len(removed) == 0 && len(changed) == 0 {
+		return false, nil
+	}
+
+	err = db.withTx(func(tx *sql.Tx) error {
+		for _, key := range removed {
+			_, err := tx.Exec(db.rebind("DELETE FROM node_labels WHERE node_id = ? AND key = ? AND source = ?"),
+				nodeID, key, LabelSourceAgent)
+			if err != nil {
+				return err
+			}
+		}
+		for key, value := range changed {
+			if _, err := tx.Exec(db.rebind(upsertLabelSQL), nodeID, key, value, LabelSourceAgent); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return err == nil, err
+}
+
+// SetAdminLabels 替换管理员设置的标签，同名的Agent标签被覆盖
+func (db *DB) SetAdminLabels(nodeID int, labels map[string]string) error {
+	return db.withTx(func(tx *sql.Tx) error {
+		_, err := tx.Exec(db.rebind("DELETE FROM node_labels WHERE node_id = ? AND source = ?"), nodeID, LabelSourceAdmin)
+		if err != nil {
+			return err
+		}
+		for key, value := range labels {
+			if _, err := tx.Exec(db.rebind(upsertLabelSQL), nodeID, key, value, LabelSourceAdmin); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// 分组相关操作
+func (db *DB) CreateGroup(group *models.NodeGroup) error {
+	id, err := db.insert("INSERT INTO node_groups (name, selector, created_at) VALUES (?, ?, ?)",
+		group.Name, group.Selector, utcNow())
+	if err != nil {
+		return err
+	}
+	group.ID = int(id)
+	return nil
+}
+
+func (db *DB) GetGroups() ([]models.NodeGroup, error) {
+	rows, err := db.query("SELECT id, name, selector, created_at FROM node_groups ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []models.NodeGroup{}
+	for rows.Next() {
+		var group models.NodeGroup
+		if err := rows.Scan(&group.ID, &group.Name, &group.Selector, &group.CreatedAt); err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	return groups, rows.Err()
+}
+
+func (db *DB) GetGroupByName(name string) (*models.NodeGroup, error) {
+	group := &models.NodeGroup{}
+	err := db.queryRow("SELECT id, name, selector, created_at FROM node_groups WHERE name = ?", name).Scan(
+		&group.ID, &group.Name, &group.Selector, &group.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func (db *DB) DeleteGroup(id int) (bool, error) {
+	result, err := db.exec("DELETE FROM node_groups WHERE id = ?", id)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
@@ -0,0 +1,179 @@
+package database
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// MigrationStatus 单个迁移的执行状态
+type MigrationStatus struct {
+	Version   int    `json:"version"`
+	Name      string `json:"name"`
+	Applied   bool   `json:"applied"`
+	AppliedAt string `json:"applied_at,omitempty"`
+}
+
+// LatestSchemaVersion 当前程序支持的最新结构版本
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+func (db *DB) ensureVersionTable() error {
+	_, err := db.exec(`
+	CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`)
+	return err
+}
+
+// SchemaVersion 返回已应用的最高迁移版本，未初始化的数据库返回0
+func (db *DB) SchemaVersion() (int, error) {
+	if err := db.ensureVersionTable(); err != nil {
+		return 0, err
+	}
+
+	var version int
+	err := db.queryRow("SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version)
+	return version, err
+}
+
+// MigrationStatus 列出所有迁移及其是否已应用
+func (db *DB) MigrationStatus() ([]MigrationStatus, error) {
+	if err := db.ensureVersionTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.query("SELECT version, applied_at FROM schema_version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]string)
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		appliedAt, ok := applied[m.version]
+		status = append(status, MigrationStatus{
+			Version:   m.version,
+			Name:      m.name,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+	}
+	return status, nil
+}
+
+// Migrate 应用所有未执行的迁移。backup 为 true 时，对已有数据的SQLite数据库先做备份
+func (db *DB) Migrate(backup bool) error {
+	current, err := db.SchemaVersion()
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %v", err)
+	}
+
+	latest := LatestSchemaVersion()
+	if current > latest {
+		return fmt.Errorf("database schema version %d is newer than supported version %d", current, latest)
+	}
+	if current == latest {
+		return nil
+	}
+
+	if backup {
+		if err := db.backupBeforeMigrate(current); err != nil {
+			return fmt.Errorf("failed to back up database before migration: %v", err)
+		}
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := db.applyMigration(m); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %v", m.version, m.name, err)
+		}
+		log.Printf("Applied database migration %d: %s", m.version, m.name)
+	}
+
+	return nil
+}
+
+// applyMigration 在一个事务中执行迁移并记录版本
+func (db *DB) applyMigration(m migration) error {
+	statements := m.sqlite
+	if db.driver == DriverPostgres {
+		statements = m.postgres
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(db.rebind("INSERT INTO schema_version (version, name, applied_at) VALUES (?, ?, ?)"),
+		m.version, m.name, utcNow())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// backupBeforeMigrate 迁移前备份已有数据，新建的空库不需要备份
+func (db *DB) backupBeforeMigrate(current int) error {
+	if db.driver != DriverSQLite {
+		log.Printf("Skipping automatic backup before migration for %s, back up the database manually", db.driver)
+		return nil
+	}
+
+	empty, err := db.isEmpty()
+	if err != nil || empty {
+		return err
+	}
+
+	dest := fmt.Sprintf("%s.v%d-%s.bak", db.path, current, time.Now().Format("20060102150405"))
+	if err := db.vacuumInto(dest); err != nil {
+		return err
+	}
+	log.Printf("Database backed up to %s before migration", dest)
+	return nil
+}
+
+// isEmpty 判断数据库中是否还没有业务表
+func (db *DB) isEmpty() (bool, error) {
+	var count int
+	err := db.queryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name NOT IN ('schema_version', 'sqlite_sequence')").Scan(&count)
+	return count == 0, err
+}
+
+// vacuumInto 使用 VACUUM INTO 生成一致的SQLite快照，目标文件必须不存在
+func (db *DB) vacuumInto(dest string) error {
+	if _, err := os.Stat(dest); err == nil {
+		return fmt.Errorf("backup file %s already exists", dest)
+	}
+	_, err := db.conn.Exec("VACUUM INTO '" + strings.ReplaceAll(dest, "'", "''") + "'")
+	return err
+}
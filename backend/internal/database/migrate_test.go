@@ -0,0 +1,150 @@
+package database
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"miniPanel/internal/config"
+)
+
+func connectSQLite(t *testing.T) (*DB, config.DatabaseConfig) {
+	t.Helper()
+	cfg := config.DatabaseConfig{Driver: DriverSQLite, Path: filepath.Join(t.TempDir(), "miniPanel.db")}
+	db, err := Connect(cfg)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, cfg
+}
+
+// withMigrations 在测试期间替换迁移列表
+func withMigrations(t *testing.T, list []migration) {
+	t.Helper()
+	saved := migrations
+	migrations = list
+	t.Cleanup(func() { migrations = saved })
+}
+
+func TestMigrationList(t *testing.T) {
+	for i, m := range migrations {
+		if m.version != i+1 {
+			t.Errorf("migration #%d has version %d, versions must be consecutive from 1", i, m.version)
+		}
+		if m.name == "" {
+			t.Errorf("migration %d has no name", m.version)
+		}
+		if m.sqlite == nil || m.postgres == nil {
+			t.Errorf("migration %d (%s) must provide statements for both drivers", m.version, m.name)
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	db, _ := connectSQLite(t)
+	if err := db.Migrate(false); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	version, err := db.SchemaVersion()
+	if err != nil || version != LatestSchemaVersion() {
+		t.Fatalf("SchemaVersion = %d, %v; want %d", version, err, LatestSchemaVersion())
+	}
+
+	status, err := db.MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status) != len(migrations) {
+		t.Fatalf("status has %d entries, want %d", len(status), len(migrations))
+	}
+	for _, s := range status {
+		if !s.Applied || s.AppliedAt == "" {
+			t.Errorf("migration %d: applied=%v at %q", s.Version, s.Applied, s.AppliedAt)
+		}
+	}
+
+	// 再次执行不做任何事
+	if err := db.Migrate(false); err != nil {
+		t.Errorf("second Migrate: %v", err)
+	}
+	if err := db.checkSchemaVersion(); err != nil {
+		t.Errorf("checkSchemaVersion: %v", err)
+	}
+}
+
+func TestMigrateFailureRollsBack(t *testing.T) {
+	db, _ := connectSQLite(t)
+	withMigrations(t, []migration{
+		{version: 1, name: "first", sqlite: []string{`CREATE TABLE a (id INTEGER)`}, postgres: []string{}},
+		{version: 2, name: "broken", sqlite: []string{`CREATE TABLE b (id INTEGER)`, `NOT VALID SQL`}, postgres: []string{}},
+	})
+
+	err := db.Migrate(false)
+	if err == nil || !strings.Contains(err.Error(), "migration 2 (broken) failed") {
+		t.Fatalf("Migrate = %v, want migration 2 failure", err)
+	}
+	if version, _ := db.SchemaVersion(); version != 1 {
+		t.Errorf("SchemaVersion = %d, want 1", version)
+	}
+	// 失败迁移中已执行的语句随事务回滚
+	var count int
+	if err := db.queryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'b'").Scan(&count); err != nil || count != 0 {
+		t.Errorf("table b exists after failed migration (count=%d, err=%v)", count, err)
+	}
+	status, _ := db.MigrationStatus()
+	if !status[0].Applied || status[1].Applied {
+		t.Errorf("status = %+v", status)
+	}
+}
+
+func TestMigrateVersionChecks(t *testing.T) {
+	db, _ := connectSQLite(t)
+	withMigrations(t, migrations[:2])
+	if err := db.Migrate(false); err != nil {
+		t.Fatal(err)
+	}
+
+	// 数据库版本低于程序时，不自动迁移的启动被拒绝
+	migrations = append(migrations[:2:2], migration{version: 3, name: "next", sqlite: []string{}, postgres: []string{}})
+	if err := db.checkSchemaVersion(); err == nil || !strings.Contains(err.Error(), "run 'migrate up'") {
+		t.Errorf("checkSchemaVersion on old schema = %v", err)
+	}
+	if err := db.Migrate(false); err != nil {
+		t.Fatal(err)
+	}
+
+	// 数据库版本高于程序时拒绝迁移（例如降级后的旧版本程序）
+	migrations = migrations[:2]
+	if err := db.Migrate(false); err == nil || !strings.Contains(err.Error(), "newer than supported") {
+		t.Errorf("Migrate on newer schema = %v", err)
+	}
+}
+
+func TestBackupBeforeMigrate(t *testing.T) {
+	db, cfg := connectSQLite(t)
+	withMigrations(t, migrations[:1])
+
+	// 新建的空库迁移前不备份
+	if err := db.Migrate(true); err != nil {
+		t.Fatal(err)
+	}
+	matches, _ := filepath.Glob(cfg.Path + ".v*.bak")
+	if len(matches) != 0 {
+		t.Fatalf("empty database was backed up: %v", matches)
+	}
+
+	// 已有数据时先备份再迁移
+	migrations = migrations[:1:1]
+	migrations = append(migrations, migration{version: 2, name: "next", sqlite: []string{`CREATE TABLE extra (id INTEGER)`}, postgres: []string{}})
+	if err := db.Migrate(true); err != nil {
+		t.Fatal(err)
+	}
+	matches, _ = filepath.Glob(cfg.Path + ".v1-*.bak")
+	if len(matches) != 1 {
+		t.Fatalf("backups before migration: %v, want one v1 backup", matches)
+	}
+	if version, err := validateSnapshot(matches[0]); err != nil || version != 1 {
+		t.Errorf("backup schema version = %d, %v; want 1", version, err)
+	}
+}
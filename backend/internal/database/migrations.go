@@ -0,0 +1,478 @@
+package database
+
+// migration 一次数据库结构变更，SQLite 和 PostgreSQL 各自提供语句
+type migration struct {
+	version  int
+	name     string
+	sqlite   []string
+	postgres []string
+}
+
+// migrations 按版本号递增排列，已发布的迁移不能修改，只能追加新的迁移
+var migrations = []migration{
+	{
+		version:  1,
+		name:     "initial schema",
+		sqlite:   sqliteInitialSchema,
+		postgres: postgresInitialSchema,
+	},
+	{
+		version: 2,
+		name:    "metrics and node indexes",
+		sqlite: []string{
+			`CREATE INDEX IF NOT EXISTS idx_system_metrics_node_timestamp ON system_metrics(node_id, timestamp);`,
+			`CREATE INDEX IF NOT EXISTS idx_system_metrics_timestamp ON system_metrics(timestamp);`,
+			`CREATE INDEX IF NOT EXISTS idx_nodes_status ON nodes(status);`,
+			`CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);`,
+		},
+		postgres: []string{
+			`CREATE INDEX IF NOT EXISTS idx_system_metrics_node_timestamp ON system_metrics(node_id, timestamp);`,
+			`CREATE INDEX IF NOT EXISTS idx_system_metrics_timestamp ON system_metrics(timestamp);`,
+			`CREATE INDEX IF NOT EXISTS idx_nodes_status ON nodes(status);`,
+			`CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);`,
+		},
+	},
+	{
+		version: 3,
+		name:    "node labels and groups",
+		sqlite: []string{
+			`CREATE TABLE node_labels (
+				node_id INTEGER NOT NULL,
+				key TEXT NOT NULL,
+				value TEXT NOT NULL,
+				source TEXT NOT NULL DEFAULT 'admin',
+				PRIMARY KEY (node_id, key),
+				FOREIGN KEY (node_id) REFERENCES nodes(id)
+			);`,
+			`CREATE TABLE node_groups (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT UNIQUE NOT NULL,
+				selector TEXT NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);`,
+		},
+		postgres: []string{
+			`CREATE TABLE node_labels (
+				node_id INTEGER NOT NULL REFERENCES nodes(id),
+				key TEXT NOT NULL,
+				value TEXT NOT NULL,
+				source TEXT NOT NULL DEFAULT 'admin',
+				PRIMARY KEY (node_id, key)
+			);`,
+			`CREATE TABLE node_groups (
+				id SERIAL PRIMARY KEY,
+				name TEXT UNIQUE NOT NULL,
+				selector TEXT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);`,
+		},
+	},
+	{
+		version: 4,
+		name:    "node lifecycle",
+		sqlite: []string{
+			`ALTER TABLE nodes ADD COLUMN agent_name TEXT NOT NULL DEFAULT '';`,
+			`UPDATE nodes SET agent_name = name;`,
+			`ALTER TABLE nodes ADD COLUMN lifecycle TEXT NOT NULL DEFAULT 'active';`,
+			`CREATE INDEX IF NOT EXISTS idx_nodes_agent_name ON nodes(agent_name);`,
+		},
+		postgres: []string{
+			`ALTER TABLE nodes ADD COLUMN agent_name TEXT NOT NULL DEFAULT '';`,
+			`UPDATE nodes SET agent_name = name;`,
+			`ALTER TABLE nodes ADD COLUMN lifecycle TEXT NOT NULL DEFAULT 'active';`,
+			`CREATE INDEX IF NOT EXISTS idx_nodes_agent_name ON nodes(agent_name);`,
+		},
+	},
+	{
+		version: 5,
+		name:    "node inventory and events",
+		sqlite: []string{
+			`CREATE TABLE node_inventory (
+				node_id INTEGER PRIMARY KEY,
+				hostname TEXT NOT NULL DEFAULT '',
+				os TEXT NOT NULL DEFAULT '',
+				platform TEXT NOT NULL DEFAULT '',
+				platform_version TEXT NOT NULL DEFAULT '',
+				kernel_version TEXT NOT NULL DEFAULT '',
+				arch TEXT NOT NULL DEFAULT '',
+				cpu_model TEXT NOT NULL DEFAULT '',
+				cpu_cores INTEGER NOT NULL DEFAULT 0,
+				memory_total INTEGER NOT NULL DEFAULT 0,
+				boot_time INTEGER NOT NULL DEFAULT 0,
+				agent_version TEXT NOT NULL DEFAULT '',
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (node_id) REFERENCES nodes(id)
+			);`,
+			`CREATE TABLE node_events (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				node_id INTEGER NOT NULL,
+				type TEXT NOT NULL,
+				message TEXT NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (node_id) REFERENCES nodes(id)
+			);`,
+			`CREATE INDEX IF NOT EXISTS idx_node_events_node_id ON node_events(node_id, created_at);`,
+		},
+		postgres: []string{
+			`CREATE TABLE node_inventory (
+				node_id INTEGER PRIMARY KEY REFERENCES nodes(id),
+				hostname TEXT NOT NULL DEFAULT '',
+				os TEXT NOT NULL DEFAULT '',
+				platform TEXT NOT NULL DEFAULT '',
+				platform_version TEXT NOT NULL DEFAULT '',
+				kernel_version TEXT NOT NULL DEFAULT '',
+				arch TEXT NOT NULL DEFAULT '',
+				cpu_model TEXT NOT NULL DEFAULT '',
+				cpu_cores INTEGER NOT NULL DEFAULT 0,
+				memory_total BIGINT NOT NULL DEFAULT 0,
+				boot_time BIGINT NOT NULL DEFAULT 0,
+				agent_version TEXT NOT NULL DEFAULT '',
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);`,
+			`CREATE TABLE node_events (
+				id SERIAL PRIMARY KEY,
+				node_id INTEGER NOT NULL REFERENCES nodes(id),
+				type TEXT NOT NULL,
+				message TEXT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);`,
+			`CREATE INDEX IF NOT EXISTS idx_node_events_node_id ON node_events(node_id, created_at);`,
+		},
+	},
+	{
+		version: 6,
+		name:    "scrape targets",
+		sqlite: []string{
+			`CREATE TABLE scrape_targets (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT NOT NULL DEFAULT '',
+				url TEXT UNIQUE NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				last_scrape DATETIME,
+				last_error TEXT NOT NULL DEFAULT ''
+			);`,
+			`CREATE INDEX IF NOT EXISTS idx_nodes_last_seen ON nodes(last_seen);`,
+		},
+		postgres: []string{
+			`CREATE TABLE scrape_targets (
+				id SERIAL PRIMARY KEY,
+				name TEXT NOT NULL DEFAULT '',
+				url TEXT UNIQUE NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				last_scrape TIMESTAMP,
+				last_error TEXT NOT NULL DEFAULT ''
+			);`,
+			`CREATE INDEX IF NOT EXISTS idx_nodes_last_seen ON nodes(last_seen);`,
+		},
+	},
+	{
+		version: 7,
+		name:    "agent config profiles",
+		sqlite: []string{
+			`CREATE TABLE config_profiles (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT UNIQUE NOT NULL,
+				node_id INTEGER NOT NULL DEFAULT 0,
+				group_name TEXT NOT NULL DEFAULT '',
+				selector TEXT NOT NULL DEFAULT '',
+				priority INTEGER NOT NULL DEFAULT 0,
+				config TEXT NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);`,
+		},
+		postgres: []string{
+			`CREATE TABLE config_profiles (
+				id SERIAL PRIMARY KEY,
+				name TEXT UNIQUE NOT NULL,
+				node_id INTEGER NOT NULL DEFAULT 0,
+				group_name TEXT NOT NULL DEFAULT '',
+				selector TEXT NOT NULL DEFAULT '',
+				priority INTEGER NOT NULL DEFAULT 0,
+				config TEXT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);`,
+		},
+	},
+	{
+		version: 8,
+		name:    "agent releases",
+		sqlite: []string{
+			`CREATE TABLE agent_releases (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				version TEXT NOT NULL,
+				os TEXT NOT NULL,
+				arch TEXT NOT NULL,
+				sha256 TEXT NOT NULL,
+				signature TEXT NOT NULL,
+				size INTEGER NOT NULL,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				UNIQUE (version, os, arch)
+			);`,
+		},
+		postgres: []string{
+			`CREATE TABLE agent_releases (
+				id SERIAL PRIMARY KEY,
+				version TEXT NOT NULL,
+				os TEXT NOT NULL,
+				arch TEXT NOT NULL,
+				sha256 TEXT NOT NULL,
+				signature TEXT NOT NULL,
+				size BIGINT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+				UNIQUE (version, os, arch)
+			);`,
+		},
+	},
+	{
+		version: 9,
+		name:    "node clock skew",
+		sqlite: []string{
+			`ALTER TABLE nodes ADD COLUMN clock_skew_ms INTEGER NOT NULL DEFAULT 0;`,
+		},
+		postgres: []string{
+			`ALTER TABLE nodes ADD COLUMN clock_skew_ms BIGINT NOT NULL DEFAULT 0;`,
+		},
+	},
+	{
+		version: 10,
+		name:    "service checks",
+		sqlite: []string{
+			`CREATE TABLE node_checks (
+				node_id INTEGER NOT NULL,
+				name TEXT NOT NULL,
+				type TEXT NOT NULL,
+				status TEXT NOT NULL,
+				latency_ms REAL NOT NULL DEFAULT 0,
+				message TEXT NOT NULL DEFAULT '',
+				since DATETIME NOT NULL,
+				updated_at DATETIME NOT NULL,
+				PRIMARY KEY (node_id, name),
+				FOREIGN KEY (node_id) REFERENCES nodes(id)
+			);`,
+			`CREATE TABLE check_events (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				node_id INTEGER NOT NULL,
+				name TEXT NOT NULL,
+				status TEXT NOT NULL,
+				previous_status TEXT NOT NULL DEFAULT '',
+				message TEXT NOT NULL DEFAULT '',
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (node_id) REFERENCES nodes(id)
+			);`,
+			`CREATE INDEX IF NOT EXISTS idx_check_events_node_created ON check_events(node_id, created_at);`,
+		},
+		postgres: []string{
+			`CREATE TABLE node_checks (
+				node_id INTEGER NOT NULL REFERENCES nodes(id),
+				name TEXT NOT NULL,
+				type TEXT NOT NULL,
+				status TEXT NOT NULL,
+				latency_ms DOUBLE PRECISION NOT NULL DEFAULT 0,
+				message TEXT NOT NULL DEFAULT '',
+				since TIMESTAMP NOT NULL,
+				updated_at TIMESTAMP NOT NULL,
+				PRIMARY KEY (node_id, name)
+			);`,
+			`CREATE TABLE check_events (
+				id SERIAL PRIMARY KEY,
+				node_id INTEGER NOT NULL REFERENCES nodes(id),
+				name TEXT NOT NULL,
+				status TEXT NOT NULL,
+				previous_status TEXT NOT NULL DEFAULT '',
+				message TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);`,
+			`CREATE INDEX IF NOT EXISTS idx_check_events_node_created ON check_events(node_id, created_at);`,
+		},
+	},
+	{
+		version: 11,
+		name:    "systemd units",
+		sqlite: []string{
+			`CREATE TABLE node_units (
+				node_id INTEGER NOT NULL,
+				name TEXT NOT NULL,
+				load_state TEXT NOT NULL DEFAULT '',
+				active_state TEXT NOT NULL DEFAULT '',
+				sub_state TEXT NOT NULL DEFAULT '',
+				restarts INTEGER NOT NULL DEFAULT 0,
+				memory_bytes INTEGER NOT NULL DEFAULT 0,
+				since DATETIME NOT NULL,
+				updated_at DATETIME NOT NULL,
+				PRIMARY KEY (node_id, name),
+				FOREIGN KEY (node_id) REFERENCES nodes(id)
+			);`,
+			`CREATE TABLE unit_events (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				node_id INTEGER NOT NULL,
+				name TEXT NOT NULL,
+				active_state TEXT NOT NULL DEFAULT '',
+				sub_state TEXT NOT NULL DEFAULT '',
+				previous_active_state TEXT NOT NULL DEFAULT '',
+				previous_sub_state TEXT NOT NULL DEFAULT '',
+				restarts INTEGER NOT NULL DEFAULT 0,
+				previous_restarts INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				FOREIGN KEY (node_id) REFERENCES nodes(id)
+			);`,
+			`CREATE INDEX IF NOT EXISTS idx_unit_events_node_created ON unit_events(node_id, created_at);`,
+		},
+		postgres: []string{
+			`CREATE TABLE node_units (
+				node_id INTEGER NOT NULL REFERENCES nodes(id),
+				name TEXT NOT NULL,
+				load_state TEXT NOT NULL DEFAULT '',
+				active_state TEXT NOT NULL DEFAULT '',
+				sub_state TEXT NOT NULL DEFAULT '',
+				restarts BIGINT NOT NULL DEFAULT 0,
+				memory_bytes BIGINT NOT NULL DEFAULT 0,
+				since TIMESTAMP NOT NULL,
+				updated_at TIMESTAMP NOT NULL,
+				PRIMARY KEY (node_id, name)
+			);`,
+			`CREATE TABLE unit_events (
+				id SERIAL PRIMARY KEY,
+				node_id INTEGER NOT NULL REFERENCES nodes(id),
+				name TEXT NOT NULL,
+				active_state TEXT NOT NULL DEFAULT '',
+				sub_state TEXT NOT NULL DEFAULT '',
+				previous_active_state TEXT NOT NULL DEFAULT '',
+				previous_sub_state TEXT NOT NULL DEFAULT '',
+				restarts BIGINT NOT NULL DEFAULT 0,
+				previous_restarts BIGINT NOT NULL DEFAULT 0,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+			);`,
+			`CREATE INDEX IF NOT EXISTS idx_unit_events_node_created ON unit_events(node_id, created_at);`,
+		},
+	},
+	{
+		version: 12,
+		name:    "node aliases",
+		sqlite: []string{
+			`CREATE TABLE node_aliases (
+				kind TEXT NOT NULL,
+				value TEXT NOT NULL,
+				node_id INTEGER NOT NULL,
+				PRIMARY KEY (kind, value),
+				FOREIGN KEY (node_id) REFERENCES nodes(id)
+			);`,
+		},
+		postgres: []string{
+			`CREATE TABLE node_aliases (
+				kind TEXT NOT NULL,
+				value TEXT NOT NULL,
+				node_id INTEGER NOT NULL REFERENCES nodes(id),
+				PRIMARY KEY (kind, value)
+			);`,
+		},
+	},
+	{
+		// 程序写入的时间都已是 UTC，列默认值也改为 UTC，不受会话时区影响；SQLite 的 CURRENT_TIMESTAMP 本来就是 UTC
+		version: 13,
+		name:    "utc timestamp defaults",
+		sqlite:  []string{},
+		postgres: []string{
+			`ALTER TABLE nodes ALTER COLUMN last_seen SET DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC');`,
+			`ALTER TABLE system_metrics ALTER COLUMN timestamp SET DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC');`,
+			`ALTER TABLE api_keys ALTER COLUMN created_at SET DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC');`,
+			`ALTER TABLE node_groups ALTER COLUMN created_at SET DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC');`,
+			`ALTER TABLE node_inventory ALTER COLUMN updated_at SET DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC');`,
+			`ALTER TABLE node_events ALTER COLUMN created_at SET DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC');`,
+			`ALTER TABLE scrape_targets ALTER COLUMN created_at SET DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC');`,
+			`ALTER TABLE config_profiles ALTER COLUMN created_at SET DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC');`,
+			`ALTER TABLE config_profiles ALTER COLUMN updated_at SET DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC');`,
+			`ALTER TABLE agent_releases ALTER COLUMN created_at SET DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC');`,
+			`ALTER TABLE check_events ALTER COLUMN created_at SET DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC');`,
+			`ALTER TABLE unit_events ALTER COLUMN created_at SET DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC');`,
+			`ALTER TABLE schema_version ALTER COLUMN applied_at SET DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC');`,
+		},
+	},
+}
+
+// 初始表结构，使用 IF NOT EXISTS 以便兼容引入迁移之前创建的数据库
+var sqliteInitialSchema = []string{
+	// 用户表
+	`CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		username TEXT UNIQUE NOT NULL,
+		password TEXT NOT NULL
+	);`,
+
+	// 节点表
+	`CREATE TABLE IF NOT EXISTS nodes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		ip TEXT UNIQUE NOT NULL,
+		status TEXT DEFAULT 'offline',
+		last_seen DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`,
+
+	// 系统监控数据表
+	`CREATE TABLE IF NOT EXISTS system_metrics (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		node_id INTEGER NOT NULL,
+		cpu_percent REAL NOT NULL,
+		memory_total INTEGER NOT NULL,
+		memory_used INTEGER NOT NULL,
+		memory_percent REAL NOT NULL,
+		cpu_temp REAL NOT NULL,
+		timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (node_id) REFERENCES nodes(id)
+	);`,
+
+	// API密钥表
+	`CREATE TABLE IF NOT EXISTS api_keys (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		prefix TEXT NOT NULL,
+		key_hash TEXT UNIQUE NOT NULL,
+		scopes TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_used DATETIME,
+		expires_at DATETIME,
+		FOREIGN KEY (user_id) REFERENCES users(id)
+	);`,
+}
+
+// PostgreSQL 初始表结构，与 SQLite 保持相同的列名和语义
+var postgresInitialSchema = []string{
+	`CREATE TABLE IF NOT EXISTS users (
+		id SERIAL PRIMARY KEY,
+		username TEXT UNIQUE NOT NULL,
+		password TEXT NOT NULL
+	);`,
+
+	`CREATE TABLE IF NOT EXISTS nodes (
+		id SERIAL PRIMARY KEY,
+		name TEXT NOT NULL,
+		ip TEXT UNIQUE NOT NULL,
+		status TEXT DEFAULT 'offline',
+		last_seen TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`,
+
+	`CREATE TABLE IF NOT EXISTS system_metrics (
+		id BIGSERIAL PRIMARY KEY,
+		node_id INTEGER NOT NULL REFERENCES nodes(id),
+		cpu_percent DOUBLE PRECISION NOT NULL,
+		memory_total BIGINT NOT NULL,
+		memory_used BIGINT NOT NULL,
+		memory_percent DOUBLE PRECISION NOT NULL,
+		cpu_temp DOUBLE PRECISION NOT NULL,
+		timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`,
+
+	`CREATE TABLE IF NOT EXISTS api_keys (
+		id SERIAL PRIMARY KEY,
+		user_id INTEGER NOT NULL REFERENCES users(id),
+		name TEXT NOT NULL,
+		prefix TEXT NOT NULL,
+		key_hash TEXT UNIQUE NOT NULL,
+		scopes TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		last_used TIMESTAMP,
+		expires_at TIMESTAMP
+	);`,
+}
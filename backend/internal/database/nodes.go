@@ -0,0 +1,179 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"miniPanel/internal/models"
+)
+
+// nodeDataTables 以 node_id 关联节点的数据表，删除和合并节点时一并处理。
+// node_labels 因为有主键冲突需要单独处理，不在此列表中
+var nodeDataTables = []string{
+	"system_metrics",
+	"node_events",
+	"config_profiles",
+	"check_events",
+	"unit_events",
+	"node_aliases",
+}
+
+// nodeStateTables 节点的当前状态表，合并时目标节点已有记录则保留目标节点的记录
+var nodeStateTables = []string{
+	"node_inventory",
+	"node_checks",
+	"node_units",
+}
+
+// RenameNode 修改节点显示名称，之后Agent上报的名称不再覆盖它
+func (db *DB) RenameNode(id int, name string) (bool, error) {
+	result, err := db.exec("UPDATE nodes SET name = ? WHERE id = ?", name, id)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// SetNodeLifecycle 修改节点生命周期状态，退役的节点同时标记为离线
+func (db *DB) SetNodeLifecycle(id int, lifecycle string) (bool, error) {
+	switch lifecycle {
+	case models.NodeActive, models.NodeArchived, models.NodeDecommissioned:
+	default:
+		return false, fmt.Errorf("invalid lifecycle state: %s", lifecycle)
+	}
+
+	result, err := db.exec(`
+		UPDATE nodes SET lifecycle = ?,
+			status = CASE WHEN ? = 'decommissioned' THEN 'offline' ELSE status END
+		WHERE id = ?`,
+		lifecycle, lifecycle, id)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// DeleteNode 删除节点及其全部历史数据
+func (db *DB) DeleteNode(id int) (bool, error) {
+	var deleted bool
+	err := db.withTx(func(tx *sql.Tx) error {
+		tables := append([]string{"node_labels"}, nodeStateTables...)
+		for _, table := range append(tables, nodeDataTables...) {
+			if _, err := tx.Exec(db.rebind("DELETE FROM "+table+" WHERE node_id = ?"), id); err != nil {
+				return err
+			}
+		}
+
+		result, err := tx.Exec(db.rebind("DELETE FROM nodes WHERE id = ?"), id)
+		if err != nil {
+			return err
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		deleted = rowsAffected > 0
+		return nil
+	})
+	return deleted, err
+}
+
+// MergeNodes 将源节点的历史数据和标签并入目标节点，然后删除源节点。
+// 目标节点已有的同名标签保持不变。源节点的IP和Agent名称成为目标节点的别名，
+// 之后从源节点地址或以源节点名称上报的数据计入目标节点
+func (db *DB) MergeNodes(targetID, sourceID int) error {
+	if targetID == sourceID {
+		return fmt.Errorf("cannot merge a node into itself")
+	}
+
+	return db.withTx(func(tx *sql.Tx) error {
+		var count int
+		err := tx.QueryRow(db.rebind("SELECT COUNT(*) FROM nodes WHERE id IN (?, ?)"), targetID, sourceID).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count != 2 {
+			return sql.ErrNoRows
+		}
+
+		_, err = tx.Exec(db.rebind(`
+			INSERT INTO node_labels (node_id, key, value, source)
+			SELECT ?, key, value, source FROM node_labels
+			WHERE node_id = ? AND key NOT IN (SELECT key FROM node_labels WHERE node_id = ?)`),
+			targetID, sourceID, targetID)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(db.rebind("DELETE FROM node_labels WHERE node_id = ?"), sourceID); err != nil {
+			return err
+		}
+
+		for _, table := range nodeStateTables {
+			_, err := tx.Exec(db.rebind(`
+				DELETE FROM `+table+` WHERE node_id = ?
+				AND EXISTS (SELECT 1 FROM `+table+` WHERE node_id = ?)`),
+				sourceID, targetID)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, table := range append(nodeStateTables, nodeDataTables...) {
+			_, err := tx.Exec(db.rebind("UPDATE "+table+" SET node_id = ? WHERE node_id = ?"), targetID, sourceID)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, kind := range []string{aliasIP, aliasAgentName} {
+			_, err := tx.Exec(db.rebind(`
+				INSERT INTO node_aliases (kind, value, node_id)
+				SELECT ?, `+kind+`, ? FROM nodes WHERE id = ? AND `+kind+` <> ''
+				ON CONFLICT (kind, value) DO UPDATE SET node_id = excluded.node_id`),
+				kind, targetID, sourceID)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.Exec(db.rebind("DELETE FROM nodes WHERE id = ?"), sourceID)
+		return err
+	})
+}
+
+// MarkStaleNodesOffline 把 before 之后没有上报的在线节点标记为离线，返回标记的节点数
+func (db *DB) MarkStaleNodesOffline(before time.Time) (int64, error) {
+	result, err := db.exec("UPDATE nodes SET status = 'offline' WHERE status = 'online' AND last_seen < ?",
+		before.UTC().Format(timeFormat))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// MarkNodeOffline 把在线节点标记为离线，用于Agent的 gRPC 流断开时，返回节点是否从在线变为离线
+func (db *DB) MarkNodeOffline(id int) (bool, error) {
+	result, err := db.exec("UPDATE nodes SET status = 'offline' WHERE id = ? AND status = 'online'", id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// SetNodeClockSkew 记录测得的Agent时钟偏差（毫秒）
+func (db *DB) SetNodeClockSkew(id int, skewMs int64) error {
+	_, err := db.exec("UPDATE nodes SET clock_skew_ms = ? WHERE id = ?", skewMs, id)
+	return err
+}
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"encoding/json"
+	"time"
+
+	"miniPanel/internal/models"
+)
+
+const profileColumns = "id, name, node_id, group_name, selector, priority, config, created_at, updated_at"
+
+// CreateConfigProfile 添加Agent配置
+func (db *DB) CreateConfigProfile(profile *models.ConfigProfile) error {
+	config, err := json.Marshal(profile.Config)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	id, err := db.insert(`INSERT INTO config_profiles (name, node_id, group_name, selector, priority, config, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		profile.Name, profile.NodeID, profile.Group, profile.Selector, profile.Priority, string(config),
+		now.Format(timeFormat), now.Format(timeFormat))
+	if err != nil {
+		return err
+	}
+
+	profile.ID = int(id)
+	profile.CreatedAt = now.Format(time.RFC3339)
+	profile.UpdatedAt = now.Format(time.RFC3339)
+	return nil
+}
+
+// GetConfigProfiles 获取全部Agent配置，按优先级和ID排序，即应用顺序
+func (db *DB) GetConfigProfiles() ([]models.ConfigProfile, error) {
+	rows, err := db.query("SELECT " + profileColumns + " FROM config_profiles ORDER BY priority, id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := []models.ConfigProfile{}
+	for rows.Next() {
+		profile, err := scanConfigProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, *profile)
+	}
+	return profiles, rows.Err()
+}
+
+// GetConfigProfile 获取单个Agent配置，不存在时返回 sql.ErrNoRows
+func (db *DB) GetConfigProfile(id int) (*models.ConfigProfile, error) {
+	return scanConfigProfile(db.queryRow("SELECT "+profileColumns+" FROM config_profiles WHERE id = ?", id))
+}
+
+// UpdateConfigProfile 修改Agent配置
+func (db *DB) UpdateConfigProfile(profile *models.ConfigProfile) (bool, error) {
+	config, err := json.Marshal(profile.Config)
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now().UTC()
+	result, err := db.exec(`UPDATE config_profiles
+		SET name = ?, node_id = ?, group_name = ?, selector = ?, priority = ?, config = ?, updated_at = ?
+		WHERE id = ?`,
+		profile.Name, profile.NodeID, profile.Group, profile.Selector, profile.Priority, string(config),
+		now.Format(timeFormat), profile.ID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	profile.UpdatedAt = now.Format(time.RFC3339)
+	return rowsAffected > 0, nil
+}
+
+// DeleteConfigProfile 删除Agent配置
+func (db *DB) DeleteConfigProfile(id int) (bool, error) {
+	result, err := db.exec("DELETE FROM config_profiles WHERE id = ?", id)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+func scanConfigProfile(row rowScanner) (*models.ConfigProfile, error) {
+	var profile models.ConfigProfile
+	var config string
+	err := row.Scan(&profile.ID, &profile.Name, &profile.NodeID, &profile.Group, &profile.Selector,
+		&profile.Priority, &config, &profile.CreatedAt, &profile.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(config), &profile.Config); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"time"
+
+	"miniPanel/internal/models"
+)
+
+const releaseColumns = "id, version, os, arch, sha256, signature, size, created_at"
+
+// CreateRelease 登记Agent发布文件
+func (db *DB) CreateRelease(release *models.AgentRelease) error {
+	id, err := db.insert(`INSERT INTO agent_releases (version, os, arch, sha256, signature, size, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		release.Version, release.OS, release.Arch, release.SHA256, release.Signature, release.Size, utcNow())
+	if err != nil {
+		return err
+	}
+	release.ID = int(id)
+	release.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	return nil
+}
+
+// GetReleases 获取全部发布文件，新上传的在前
+func (db *DB) GetReleases() ([]models.AgentRelease, error) {
+	rows, err := db.query("SELECT " + releaseColumns + " FROM agent_releases ORDER BY id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	releases := []models.AgentRelease{}
+	for rows.Next() {
+		release, err := scanRelease(rows)
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, *release)
+	}
+	return releases, rows.Err()
+}
+
+// GetRelease 按版本、系统和架构获取发布文件，不存在时返回 sql.ErrNoRows
+func (db *DB) GetRelease(version, goos, arch string) (*models.AgentRelease, error) {
+	return scanRelease(db.queryRow("SELECT "+releaseColumns+" FROM agent_releases WHERE version = ? AND os = ? AND arch = ?",
+		version, goos, arch))
+}
+
+// GetReleaseByID 获取发布文件，不存在时返回 sql.ErrNoRows
+func (db *DB) GetReleaseByID(id int) (*models.AgentRelease, error) {
+	return scanRelease(db.queryRow("SELECT "+releaseColumns+" FROM agent_releases WHERE id = ?", id))
+}
+
+// DeleteRelease 删除发布文件记录，文件本身由调用方删除
+func (db *DB) DeleteRelease(id int) (bool, error) {
+	result, err := db.exec("DELETE FROM agent_releases WHERE id = ?", id)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+func scanRelease(row rowScanner) (*models.AgentRelease, error) {
+	var release models.AgentRelease
+	err := row.Scan(&release.ID, &release.Version, &release.OS, &release.Arch,
+		&release.SHA256, &release.Signature, &release.Size, &release.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"miniPanel/internal/models"
+)
+
+// CreateScrapeTarget 添加拉取目标
+func (db *DB) CreateScrapeTarget(target *models.ScrapeTarget) error {
+	id, err := db.insert("INSERT INTO scrape_targets (name, url, created_at) VALUES (?, ?, ?)",
+		target.Name, target.URL, utcNow())
+	if err != nil {
+		return err
+	}
+	target.ID = int(id)
+	target.CreatedAt = time.Now().UTC().Format(time.RFC3339)
+	return nil
+}
+
+// GetScrapeTargets 获取全部拉取目标
+func (db *DB) GetScrapeTargets() ([]models.ScrapeTarget, error) {
+	rows, err := db.query("SELECT id, name, url, created_at, last_scrape, last_error FROM scrape_targets ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	targets := []models.ScrapeTarget{}
+	for rows.Next() {
+		var target models.ScrapeTarget
+		var lastScrape sql.NullString
+		err := rows.Scan(&target.ID, &target.Name, &target.URL, &target.CreatedAt, &lastScrape, &target.LastError)
+		if err != nil {
+			return nil, err
+		}
+		target.LastScrape = lastScrape.String
+		targets = append(targets, target)
+	}
+	return targets, rows.Err()
+}
+
+// DeleteScrapeTarget 删除拉取目标，已拉取的节点和数据保留
+func (db *DB) DeleteScrapeTarget(id int) (bool, error) {
+	result, err := db.exec("DELETE FROM scrape_targets WHERE id = ?", id)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return rowsAffected > 0, nil
+}
+
+// UpdateScrapeStatus 记录最近一次拉取的时间和错误，成功时 scrapeErr 为空
+func (db *DB) UpdateScrapeStatus(id int, at time.Time, scrapeErr string) error {
+	_, err := db.exec("UPDATE scrape_targets SET last_scrape = ?, last_error = ? WHERE id = ?",
+		at.UTC().Format(timeFormat), scrapeErr, id)
+	return err
+}
@@ -0,0 +1,97 @@
+package database
+
+import (
+	"time"
+
+	"miniPanel/internal/models"
+)
+
+// Store 存储接口，处理器只依赖该接口，具体实现由配置的数据库驱动决定
+type Store interface {
+	// 用户
+	GetUserByUsername(username string) (*models.User, error)
+
+	// 节点
+	GetAllNodes() ([]models.Node, error)
+	GetNodeByID(id int) (*models.Node, error)
+	GetNodeByIP(ip string) (*models.Node, error)
+	GetNodeByName(name string) (*models.Node, error)
+	CreateOrUpdateNode(name, ip string) error
+	UpsertNodeByName(name, ip string) (*models.Node, error)
+	RenameNode(id int, name string) (bool, error)
+	SetNodeLifecycle(id int, lifecycle string) (bool, error)
+	DeleteNode(id int) (bool, error)
+	MergeNodes(targetID, sourceID int) error
+	MarkStaleNodesOffline(before time.Time) (int64, error)
+	MarkNodeOffline(id int) (bool, error)
+	SetNodeClockSkew(id int, skewMs int64) error
+
+	// 主机信息和节点事件
+	GetNodeInventory(nodeID int) (*models.NodeInventory, error)
+	UpdateNodeInventory(nodeID int, info *models.HostInfo) error
+	AddNodeEvent(event *models.NodeEvent) error
+	GetNodeEvents(nodeID int, limit int) ([]models.NodeEvent, error)
+
+	// 服务检查
+	UpdateNodeChecks(nodeID int, at time.Time, results []models.CheckResult) ([]models.CheckEvent, error)
+	GetNodeChecks(nodeID int) ([]models.NodeCheck, error)
+	GetChecks(status string) ([]models.NodeCheck, error)
+	GetCheckEvents(nodeID int, name string, limit int) ([]models.CheckEvent, error)
+
+	// systemd 单元
+	UpdateNodeUnits(nodeID int, at time.Time, units []models.UnitStatus) ([]models.UnitEvent, error)
+	GetNodeUnits(nodeID int) ([]models.NodeUnit, error)
+	GetUnits(activeState string) ([]models.NodeUnit, error)
+	GetUnitEvents(nodeID int, name string, limit int) ([]models.UnitEvent, error)
+
+	// 标签和分组
+	GetNodeLabels(nodeID int) (map[string]string, error)
+	SetAgentLabels(nodeID int, labels map[string]string) (bool, error)
+	SetAdminLabels(nodeID int, labels map[string]string) error
+	CreateGroup(group *models.NodeGroup) error
+	GetGroups() ([]models.NodeGroup, error)
+	GetGroupByName(name string) (*models.NodeGroup, error)
+	DeleteGroup(id int) (bool, error)
+
+	// 监控数据
+	InsertMetrics(metrics *models.AgentMetrics) error
+	InsertMetricsBatch(batch []*models.AgentMetrics) error
+	GetLatestMetrics(nodeID int) (*models.SystemMetrics, error)
+	GetHistoryMetrics(nodeID int, days int) ([]models.SystemMetrics, error)
+	GetMetricsSince(nodeID int, since time.Time) ([]models.SystemMetrics, error)
+	GetMetricsStats(since time.Time) (map[int]models.MetricsStats, error)
+
+	// 拉取目标
+	CreateScrapeTarget(target *models.ScrapeTarget) error
+	GetScrapeTargets() ([]models.ScrapeTarget, error)
+	DeleteScrapeTarget(id int) (bool, error)
+	UpdateScrapeStatus(id int, at time.Time, scrapeErr string) error
+
+	// Agent集中配置
+	CreateConfigProfile(profile *models.ConfigProfile) error
+	GetConfigProfiles() ([]models.ConfigProfile, error)
+	GetConfigProfile(id int) (*models.ConfigProfile, error)
+	UpdateConfigProfile(profile *models.ConfigProfile) (bool, error)
+	DeleteConfigProfile(id int) (bool, error)
+
+	// Agent发布文件
+	CreateRelease(release *models.AgentRelease) error
+	GetReleases() ([]models.AgentRelease, error)
+	GetRelease(version, goos, arch string) (*models.AgentRelease, error)
+	GetReleaseByID(id int) (*models.AgentRelease, error)
+	DeleteRelease(id int) (bool, error)
+
+	// API密钥
+	CreateAPIKey(key *models.APIKey) error
+	GetAPIKeyByHash(keyHash string) (*models.APIKey, error)
+	GetAPIKeysByUser(userID int) ([]models.APIKey, error)
+	DeleteAPIKey(id, userID int) (bool, error)
+	TouchAPIKey(id int, now time.Time) error
+
+	// 备份
+	Backup(dest string, compress bool) error
+
+	Close() error
+}
+
+var _ Store = (*DB)(nil)
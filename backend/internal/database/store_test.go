@@ -0,0 +1,27 @@
+package database_test
+
+import (
+	"testing"
+
+	"miniPanel/internal/database/dbtest"
+)
+
+func TestStoreSQLite(t *testing.T) {
+	if err := dbtest.TestStore(dbtest.OpenSQLite(t)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStorePostgres(t *testing.T) {
+	if err := dbtest.TestStore(dbtest.OpenPostgres(t, nil)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestStorePostgresNonUTC 会话时区不是 UTC 时，写入的时间仍然是 UTC
+func TestStorePostgresNonUTC(t *testing.T) {
+	store := dbtest.OpenPostgres(t, map[string]string{"timezone": "Asia/Shanghai"})
+	if err := dbtest.TestStore(store); err != nil {
+		t.Fatal(err)
+	}
+}
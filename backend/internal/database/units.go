@@ -0,0 +1,163 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"miniPanel/internal/models"
+)
+
+const unitColumns = "node_id, name, load_state, active_state, sub_state, restarts, memory_bytes, since, updated_at"
+
+func scanUnit(row rowScanner) (*models.NodeUnit, error) {
+	unit := &models.NodeUnit{}
+	err := row.Scan(&unit.NodeID, &unit.Name, &unit.LoadState, &unit.ActiveState, &unit.SubState,
+		&unit.Restarts, &unit.MemoryBytes, &unit.Since, &unit.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return unit, nil
+}
+
+// UpdateNodeUnits 用上报的状态替换节点的 systemd 单元，不在上报中的单元被删除。
+// 新出现的单元、active_state 或 sub_state 变化以及重启次数增加时记录到 unit_events，返回新记录的事件。
+// at 为数据时间，早于已保存状态的数据（如补发的旧数据）被忽略
+func (db *DB) UpdateNodeUnits(nodeID int, at time.Time, units []models.UnitStatus) ([]models.UnitEvent, error) {
+	ts := at.UTC().Format(timeFormat)
+	var newer int
+	err := db.queryRow("SELECT COUNT(*) FROM node_units WHERE node_id = ? AND updated_at > ?", nodeID, ts).Scan(&newer)
+	if err != nil {
+		return nil, err
+	}
+	if newer > 0 {
+		return nil, nil
+	}
+
+	current, err := db.GetNodeUnits(nodeID)
+	if err != nil {
+		return nil, err
+	}
+	prev := map[string]models.NodeUnit{}
+	for _, unit := range current {
+		prev[unit.Name] = unit
+	}
+
+	var events []models.UnitEvent
+	err = db.withTx(func(tx *sql.Tx) error {
+		for _, u := range units {
+			old, seen := prev[u.Name]
+			delete(prev, u.Name)
+
+			// 状态未变化时保留进入该状态的时间
+			_, err := tx.Exec(db.rebind(`
+				INSERT INTO node_units (node_id, name, load_state, active_state, sub_state, restarts, memory_bytes, since, updated_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+				ON CONFLICT (node_id, name) DO UPDATE SET
+					since = CASE WHEN node_units.active_state = excluded.active_state AND node_units.sub_state = excluded.sub_state
+						THEN node_units.since ELSE excluded.since END,
+					load_state = excluded.load_state, active_state = excluded.active_state, sub_state = excluded.sub_state,
+					restarts = excluded.restarts, memory_bytes = excluded.memory_bytes, updated_at = excluded.updated_at`),
+				nodeID, u.Name, u.LoadState, u.ActiveState, u.SubState, u.Restarts, u.MemoryBytes, ts, ts)
+			if err != nil {
+				return err
+			}
+			if seen && old.ActiveState == u.ActiveState && old.SubState == u.SubState && u.Restarts <= old.Restarts {
+				continue
+			}
+
+			event := models.UnitEvent{
+				NodeID:              nodeID,
+				Name:                u.Name,
+				ActiveState:         u.ActiveState,
+				SubState:            u.SubState,
+				PreviousActiveState: old.ActiveState,
+				PreviousSubState:    old.SubState,
+				Restarts:            u.Restarts,
+				PreviousRestarts:    old.Restarts,
+				CreatedAt:           ts,
+			}
+			_, err = tx.Exec(db.rebind(`
+				INSERT INTO unit_events (node_id, name, active_state, sub_state, previous_active_state, previous_sub_state,
+					restarts, previous_restarts, created_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+				nodeID, event.Name, event.ActiveState, event.SubState, event.PreviousActiveState, event.PreviousSubState,
+				event.Restarts, event.PreviousRestarts, ts)
+			if err != nil {
+				return err
+			}
+			events = append(events, event)
+		}
+
+		// Agent配置中已移除或不再匹配的单元
+		for name := range prev {
+			if _, err := tx.Exec(db.rebind("DELETE FROM node_units WHERE node_id = ? AND name = ?"), nodeID, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// GetNodeUnits 获取节点全部 systemd 单元的当前状态，按名称排序
+func (db *DB) GetNodeUnits(nodeID int) ([]models.NodeUnit, error) {
+	return db.queryUnits("SELECT "+unitColumns+" FROM node_units WHERE node_id = ? ORDER BY name", nodeID)
+}
+
+// GetUnits 获取所有节点的 systemd 单元，activeState 不为空时只返回该状态的单元
+func (db *DB) GetUnits(activeState string) ([]models.NodeUnit, error) {
+	if activeState == "" {
+		return db.queryUnits("SELECT " + unitColumns + " FROM node_units ORDER BY node_id, name")
+	}
+	return db.queryUnits("SELECT "+unitColumns+" FROM node_units WHERE active_state = ? ORDER BY node_id, name", activeState)
+}
+
+func (db *DB) queryUnits(query string, args ...interface{}) ([]models.NodeUnit, error) {
+	rows, err := db.query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	units := []models.NodeUnit{}
+	for rows.Next() {
+		unit, err := scanUnit(rows)
+		if err != nil {
+			return nil, err
+		}
+		units = append(units, *unit)
+	}
+	return units, rows.Err()
+}
+
+// GetUnitEvents 获取节点 systemd 单元最近的状态变化，按时间倒序；name 不为空时只返回该单元的记录
+func (db *DB) GetUnitEvents(nodeID int, name string, limit int) ([]models.UnitEvent, error) {
+	query := `SELECT id, node_id, name, active_state, sub_state, previous_active_state, previous_sub_state,
+		restarts, previous_restarts, created_at
+		FROM unit_events WHERE node_id = ?`
+	args := []interface{}{nodeID}
+	if name != "" {
+		query += " AND name = ?"
+		args = append(args, name)
+	}
+	rows, err := db.query(query+" ORDER BY created_at DESC, id DESC LIMIT ?", append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.UnitEvent{}
+	for rows.Next() {
+		var event models.UnitEvent
+		err := rows.Scan(&event.ID, &event.NodeID, &event.Name, &event.ActiveState, &event.SubState,
+			&event.PreviousActiveState, &event.PreviousSubState, &event.Restarts, &event.PreviousRestarts, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+
+	"miniPanel/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 创建数据库备份
+func (h *Handler) CreateBackup(c *gin.Context) {
+	info, err := h.backups.Create()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to create backup: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data:    info,
+	})
+}
+
+// 获取备份列表
+func (h *Handler) GetBackups(c *gin.Context) {
+	backups, err := h.backups.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to list backups",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    backups,
+	})
+}
+
+// 下载备份文件
+func (h *Handler) DownloadBackup(c *gin.Context) {
+	path, err := h.backups.Path(c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "Backup not found",
+		})
+		return
+	}
+
+	c.FileAttachment(path, c.Param("name"))
+}
+
+// 获取写入队列状态：队列深度、批次写入耗时等
+func (h *Handler) GetIngestStats(c *gin.Context) {
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    h.writer.Stats(),
+	})
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"miniPanel/internal/alerts"
+	"miniPanel/internal/models"
+)
+
+// raiseAlerts 按告警规则检查节点的状态变化，触发的告警记录为节点事件。
+// 规则按节点的当前标签匹配，没有规则时不读取标签
+func (h *Handler) raiseAlerts(node *models.Node, changes []alerts.Event) {
+	if len(changes) == 0 || h.alerts.Empty() {
+		return
+	}
+
+	set, err := h.db.GetNodeLabels(node.ID)
+	if err != nil {
+		log.Printf("Failed to get labels for node %s, skipping alert rules: %v", node.Name, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	for _, change := range changes {
+		change.NodeID = node.ID
+		change.NodeName = node.Name
+		change.Labels = set
+		change.Time = now
+		for _, alert := range h.alerts.Evaluate(change) {
+			event := models.NodeEvent{
+				NodeID:  node.ID,
+				Type:    models.EventAlert,
+				Message: fmt.Sprintf("Alert %s: %s", alert.Rule, alert.Message),
+			}
+			log.Printf("Node %s: %s", node.Name, event.Message)
+			if err := h.db.AddNodeEvent(&event); err != nil {
+				log.Printf("Failed to record event for node %s: %v", node.Name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"miniPanel/internal/alerts"
+	"miniPanel/internal/config"
+	"miniPanel/internal/database"
+	"miniPanel/internal/models"
+)
+
+// alertStore 返回预设的状态变化和标签，并记录写入的节点事件
+type alertStore struct {
+	database.Store
+	labels      map[string]string
+	checkEvents []models.CheckEvent
+	unitEvents  []models.UnitEvent
+	events      []models.NodeEvent
+}
+
+func (s *alertStore) UpdateNodeChecks(nodeID int, at time.Time, results []models.CheckResult) ([]models.CheckEvent, error) {
+	return s.checkEvents, nil
+}
+
+func (s *alertStore) UpdateNodeUnits(nodeID int, at time.Time, units []models.UnitStatus) ([]models.UnitEvent, error) {
+	return s.unitEvents, nil
+}
+
+func (s *alertStore) GetNodeLabels(nodeID int) (map[string]string, error) {
+	return s.labels, nil
+}
+
+func (s *alertStore) AddNodeEvent(event *models.NodeEvent) error {
+	s.events = append(s.events, *event)
+	return nil
+}
+
+func TestSyncRaisesAlerts(t *testing.T) {
+	engine, err := alerts.New(config.AlertsConfig{Rules: []config.AlertRule{
+		{Name: "prod-http", Selector: "env=prod", Kind: alerts.KindCheck, Match: "http-*", Condition: alerts.ConditionFailed},
+		{Name: "restarts", Selector: "env=prod", Kind: alerts.KindUnit, Condition: alerts.ConditionRestarted},
+	}})
+	if err != nil {
+		t.Fatalf("alerts.New: %v", err)
+	}
+	node := &models.Node{ID: 1, Name: "web-1"}
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		sync   func(h *Handler, s *alertStore)
+		want   []string // 按顺序写入的节点事件类型
+	}{
+		{
+			name:   "check failure matches rule",
+			labels: map[string]string{"env": "prod"},
+			sync: func(h *Handler, s *alertStore) {
+				s.checkEvents = []models.CheckEvent{{Name: "http-api", Status: models.CheckFail, PreviousStatus: models.CheckOK, Message: "timeout"}}
+				h.syncChecks(node, time.Now(), []models.CheckResult{{Name: "http-api", Status: models.CheckFail}})
+			},
+			want: []string{models.EventCheckFailed, models.EventAlert},
+		},
+		{
+			name:   "check failure on other environment",
+			labels: map[string]string{"env": "dev"},
+			sync: func(h *Handler, s *alertStore) {
+				s.checkEvents = []models.CheckEvent{{Name: "http-api", Status: models.CheckFail, PreviousStatus: models.CheckOK}}
+				h.syncChecks(node, time.Now(), []models.CheckResult{{Name: "http-api", Status: models.CheckFail}})
+			},
+			want: []string{models.EventCheckFailed},
+		},
+		{
+			name:   "check recovery has no rule",
+			labels: map[string]string{"env": "prod"},
+			sync: func(h *Handler, s *alertStore) {
+				s.checkEvents = []models.CheckEvent{{Name: "http-api", Status: models.CheckOK, PreviousStatus: models.CheckFail}}
+				h.syncChecks(node, time.Now(), []models.CheckResult{{Name: "http-api", Status: models.CheckOK}})
+			},
+			want: []string{models.EventCheckOK},
+		},
+		{
+			name:   "unit restart matches rule",
+			labels: map[string]string{"env": "prod"},
+			sync: func(h *Handler, s *alertStore) {
+				s.unitEvents = []models.UnitEvent{{Name: "nginx.service", ActiveState: "active", PreviousActiveState: "active", Restarts: 2, PreviousRestarts: 1}}
+				h.syncUnits(node, time.Now(), []models.UnitStatus{{Name: "nginx.service"}})
+			},
+			want: []string{models.EventUnitRestart, models.EventAlert},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &alertStore{labels: tt.labels}
+			h := &Handler{db: store, alerts: engine}
+			tt.sync(h, store)
+
+			var got []string
+			for _, e := range store.events {
+				got = append(got, e.Type)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("events %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("events %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
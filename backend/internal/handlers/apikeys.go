@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"miniPanel/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API密钥权限范围
+const (
+	ScopeMetricsRead = "metrics:read" // 读取节点和监控数据
+	ScopeNodesWrite  = "nodes:write"  // 管理节点
+	ScopeAdmin       = "admin"        // 全部权限，包括密钥管理
+)
+
+// apiKeyPrefix 明文密钥前缀，用于和JWT区分
+const apiKeyPrefix = "mpk_"
+
+var validScopes = map[string]bool{
+	ScopeMetricsRead: true,
+	ScopeNodesWrite:  true,
+	ScopeAdmin:       true,
+}
+
+// generateAPIKey 生成明文密钥及其展示前缀
+func generateAPIKey() (key, prefix string, err error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	key = apiKeyPrefix + hex.EncodeToString(buf)
+	return key, key[:len(apiKeyPrefix)+8], nil
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseDBTime 解析数据库中的时间字段，兼容驱动返回的不同格式
+func parseDBTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02 15:04:05", value)
+}
+
+// authenticateAPIKey 校验API密钥，成功时返回密钥记录
+func (h *Handler) authenticateAPIKey(key string) (*models.APIKey, bool) {
+	apiKey, err := h.db.GetAPIKeyByHash(hashAPIKey(key))
+	if err != nil {
+		return nil, false
+	}
+
+	if apiKey.ExpiresAt != "" {
+		expiresAt, err := parseDBTime(apiKey.ExpiresAt)
+		if err != nil || time.Now().After(expiresAt) {
+			return nil, false
+		}
+	}
+
+	// 更新使用时间失败不影响认证
+	if err := h.db.TouchAPIKey(apiKey.ID, time.Now()); err != nil {
+		log.Printf("Failed to update API key last used time: %v", err)
+	}
+
+	return apiKey, true
+}
+
+// hasScope 判断权限列表是否包含指定权限，admin 拥有全部权限
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireScope 权限检查中间件，需在 JWTMiddleware 之后使用
+func (h *Handler) RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scopes, _ := c.Get("scopes")
+		list, _ := scopes.([]string)
+		if !hasScope(list, scope) {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Message: "Insufficient scope: " + scope + " required",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// 创建API密钥
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid request format",
+		})
+		return
+	}
+
+	if len(req.Scopes) == 0 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "At least one scope required",
+		})
+		return
+	}
+
+	// 不允许创建超出自身权限的密钥
+	callerScopes := c.GetStringSlice("scopes")
+	for _, scope := range req.Scopes {
+		if !validScopes[scope] {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Message: "Unknown scope: " + scope,
+			})
+			return
+		}
+		if !hasScope(callerScopes, scope) {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Message: "Cannot grant scope: " + scope,
+			})
+			return
+		}
+	}
+
+	key, prefix, err := generateAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to generate API key",
+		})
+		return
+	}
+
+	apiKey := &models.APIKey{
+		UserID:  c.GetInt("user_id"),
+		Name:    strings.TrimSpace(req.Name),
+		Prefix:  prefix,
+		KeyHash: hashAPIKey(key),
+		Scopes:  req.Scopes,
+	}
+	if req.ExpiresInDays > 0 {
+		apiKey.ExpiresAt = time.Now().UTC().AddDate(0, 0, req.ExpiresInDays).Format("2006-01-02 15:04:05")
+	}
+
+	if err := h.db.CreateAPIKey(apiKey); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to save API key",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data: models.CreateAPIKeyResponse{
+			Key:    key,
+			APIKey: *apiKey,
+		},
+	})
+}
+
+// 获取当前用户的API密钥列表
+func (h *Handler) GetAPIKeys(c *gin.Context) {
+	keys, err := h.db.GetAPIKeysByUser(c.GetInt("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to get API keys",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    keys,
+	})
+}
+
+// 删除API密钥
+func (h *Handler) DeleteAPIKey(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid key id",
+		})
+		return
+	}
+
+	deleted, err := h.db.DeleteAPIKey(id, c.GetInt("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to delete API key",
+		})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "API key not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "API key deleted",
+	})
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"miniPanel/internal/database"
+	"miniPanel/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestGenerateAPIKey(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 100; i++ {
+		key, prefix, err := generateAPIKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(key, apiKeyPrefix) || len(key) != len(apiKeyPrefix)+48 {
+			t.Fatalf("key %q has wrong format", key)
+		}
+		if !strings.HasPrefix(key, prefix) || len(prefix) != len(apiKeyPrefix)+8 {
+			t.Fatalf("prefix %q does not match key %q", prefix, key)
+		}
+		if seen[key] {
+			t.Fatalf("duplicate key %q", key)
+		}
+		seen[key] = true
+	}
+}
+
+func TestHashAPIKey(t *testing.T) {
+	// SHA-256 十六进制摘要，数据库中只保存它
+	got := hashAPIKey("mpk_test")
+	if len(got) != 64 || got != hashAPIKey("mpk_test") {
+		t.Errorf("hashAPIKey = %q is not a stable sha256 hex digest", got)
+	}
+	if got == hashAPIKey("mpk_tesT") {
+		t.Error("different keys have the same hash")
+	}
+	if want := "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"; hashAPIKey("") != want {
+		t.Errorf("hashAPIKey(\"\") = %s, want %s", hashAPIKey(""), want)
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	tests := []struct {
+		scopes []string
+		scope  string
+		want   bool
+	}{
+		{nil, ScopeMetricsRead, false},
+		{[]string{ScopeMetricsRead}, ScopeMetricsRead, true},
+		{[]string{ScopeMetricsRead}, ScopeNodesWrite, false},
+		{[]string{ScopeMetricsRead, ScopeNodesWrite}, ScopeNodesWrite, true},
+		{[]string{ScopeNodesWrite}, ScopeAdmin, false},
+		{[]string{ScopeAdmin}, ScopeNodesWrite, true},
+		{[]string{ScopeAdmin}, ScopeAdmin, true},
+		{[]string{"METRICS:READ"}, ScopeMetricsRead, false},
+	}
+	for _, tt := range tests {
+		if got := hasScope(tt.scopes, tt.scope); got != tt.want {
+			t.Errorf("hasScope(%v, %s) = %v, want %v", tt.scopes, tt.scope, got, tt.want)
+		}
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &Handler{}
+	tests := []struct {
+		name   string
+		scopes any
+		want   int
+	}{
+		{"allowed", []string{ScopeNodesWrite}, http.StatusOK},
+		{"admin", []string{ScopeAdmin}, http.StatusOK},
+		{"missing scope", []string{ScopeMetricsRead}, http.StatusForbidden},
+		{"no scopes", nil, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		r := gin.New()
+		r.Use(func(c *gin.Context) {
+			if tt.scopes != nil {
+				c.Set("scopes", tt.scopes)
+			}
+		})
+		r.POST("/", h.RequireScope(ScopeNodesWrite), func(c *gin.Context) { c.Status(http.StatusOK) })
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("POST", "/", nil))
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
+
+// apiKeyStore 按摘要查找密钥
+type apiKeyStore struct {
+	database.Store
+	keys    map[string]*models.APIKey
+	touched int
+}
+
+func (s *apiKeyStore) GetAPIKeyByHash(keyHash string) (*models.APIKey, error) {
+	if key, ok := s.keys[keyHash]; ok {
+		return key, nil
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (s *apiKeyStore) TouchAPIKey(id int, now time.Time) error {
+	s.touched++
+	return nil
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	now := time.Now().UTC()
+	store := &apiKeyStore{keys: map[string]*models.APIKey{
+		hashAPIKey("mpk_valid"):      {ID: 1, Scopes: []string{ScopeMetricsRead}},
+		hashAPIKey("mpk_future"):     {ID: 2, ExpiresAt: now.Add(time.Hour).Format(time.RFC3339)},
+		hashAPIKey("mpk_expired"):    {ID: 3, ExpiresAt: now.Add(-time.Hour).Format("2006-01-02 15:04:05")},
+		hashAPIKey("mpk_bad_expiry"): {ID: 4, ExpiresAt: "tomorrow"},
+	}}
+	h := &Handler{db: store}
+
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"mpk_valid", true},
+		{"mpk_future", true},
+		{"mpk_expired", false},
+		{"mpk_bad_expiry", false},
+		{"mpk_unknown", false},
+	}
+	for _, tt := range tests {
+		if _, ok := h.authenticateAPIKey(tt.key); ok != tt.want {
+			t.Errorf("authenticateAPIKey(%s) = %v, want %v", tt.key, ok, tt.want)
+		}
+	}
+	if store.touched != 2 {
+		t.Errorf("last used updated %d times, want 2", store.touched)
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"miniPanel/internal/models"
+)
+
+// WarmCache 启动时从数据库加载每个节点最近的监控数据。
+// 失败时已加载的节点使用缓存，其余节点的时间范围查询仍访问数据库
+func (h *Handler) WarmCache() error {
+	nodes, err := h.db.GetAllNodes()
+	if err != nil {
+		return err
+	}
+
+	since := time.Now().Add(-time.Duration(h.cfg.Cache.WindowMinutes) * time.Minute)
+	var count int
+	for _, node := range nodes {
+		samples, err := h.db.GetMetricsSince(node.ID, since)
+		if err != nil {
+			return err
+		}
+		for _, sample := range samples {
+			ts, err := parseDBTime(sample.Timestamp)
+			if err != nil {
+				continue
+			}
+			h.recent.Add(sample, ts)
+			count++
+		}
+		h.recent.MarkLoaded(node.ID)
+	}
+	h.recent.MarkAllLoaded()
+
+	log.Printf("Loaded %d recent samples for %d nodes into cache", count, len(nodes))
+	return nil
+}
+
+// latestSample 从缓存获取节点最新数据，缓存中没有时（最近一段时间没有上报）从数据库加载
+func (h *Handler) latestSample(nodeID int) *models.SystemMetrics {
+	if sample, ok := h.recent.Latest(nodeID); ok {
+		return &sample
+	}
+
+	sample, err := h.db.GetLatestMetrics(nodeID)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Failed to get latest metrics for node %d: %v", nodeID, err)
+		}
+		return nil
+	}
+	if ts, err := parseDBTime(sample.Timestamp); err == nil {
+		h.recent.Add(*sample, ts)
+	}
+	return sample
+}
+
+// metricsSince 获取节点 since 之后的数据，超出缓存范围时查询数据库
+func (h *Handler) metricsSince(nodeID int, since time.Time) ([]models.SystemMetrics, error) {
+	if samples, ok := h.recent.Since(nodeID, since); ok {
+		return samples, nil
+	}
+	return h.db.GetMetricsSince(nodeID, since)
+}
+
+// stats 统计节点 since 之后的数据，超出缓存范围时使用数据库的统计结果，
+// dbStats 在第一次需要时查询并在多个节点间复用
+func (h *Handler) stats(nodeID int, since time.Time, dbStats *map[int]models.MetricsStats) (*models.MetricsStats, error) {
+	if s, ok := h.recent.Stats(nodeID, since); ok {
+		return s, nil
+	}
+
+	if *dbStats == nil {
+		stats, err := h.db.GetMetricsStats(since)
+		if err != nil {
+			return nil, err
+		}
+		*dbStats = stats
+	}
+	if s, ok := (*dbStats)[nodeID]; ok {
+		return &s, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"miniPanel/internal/alerts"
+	"miniPanel/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// syncChecks 保存Agent上报的服务检查结果。检查失败和恢复时记录节点事件，
+// 第一次上报即正常的检查只记录到检查历史。at 为数据时间，补发的旧数据不覆盖较新的状态
+func (h *Handler) syncChecks(node *models.Node, at time.Time, results []models.CheckResult) {
+	valid := make([]models.CheckResult, 0, len(results))
+	for _, r := range results {
+		if r.Name == "" {
+			continue
+		}
+		// 不认识的状态按失败处理
+		if r.Status != models.CheckOK {
+			r.Status = models.CheckFail
+		}
+		valid = append(valid, r)
+	}
+
+	events, err := h.db.UpdateNodeChecks(node.ID, at, valid)
+	if err != nil {
+		log.Printf("Failed to update checks for node %s: %v", node.Name, err)
+		return
+	}
+
+	var changes []alerts.Event
+	for _, e := range events {
+		event := models.NodeEvent{NodeID: node.ID}
+		change := alerts.Event{Kind: alerts.KindCheck, Name: e.Name}
+		switch {
+		case e.Status == models.CheckFail:
+			event.Type = models.EventCheckFailed
+			event.Message = fmt.Sprintf("Check %s failed: %s", e.Name, e.Message)
+			change.Condition = alerts.ConditionFailed
+		case e.PreviousStatus != "":
+			event.Type = models.EventCheckOK
+			event.Message = fmt.Sprintf("Check %s recovered", e.Name)
+			change.Condition = alerts.ConditionRecovered
+		default:
+			continue
+		}
+		log.Printf("Node %s: %s", node.Name, event.Message)
+		if err := h.db.AddNodeEvent(&event); err != nil {
+			log.Printf("Failed to record event for node %s: %v", node.Name, err)
+		}
+		change.Message = event.Message
+		changes = append(changes, change)
+	}
+	h.raiseAlerts(node, changes)
+}
+
+// 获取节点服务检查的当前状态
+func (h *Handler) GetNodeChecks(c *gin.Context) {
+	id, ok := nodeIDParam(c)
+	if !ok {
+		return
+	}
+
+	checks, err := h.db.GetNodeChecks(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to get node checks",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    checks,
+	})
+}
+
+// 获取节点服务检查的状态变化历史，可按检查名称过滤
+func (h *Handler) GetCheckEvents(c *gin.Context) {
+	id, ok := nodeIDParam(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid limit parameter",
+		})
+		return
+	}
+
+	events, err := h.db.GetCheckEvents(id, c.Query("name"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to get check events",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    events,
+	})
+}
+
+// 获取所有节点的服务检查，?status=fail 只返回失败的检查
+func (h *Handler) GetChecks(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", models.CheckOK, models.CheckFail:
+	default:
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid status parameter",
+		})
+		return
+	}
+
+	checks, err := h.db.GetChecks(status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to get checks",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    checks,
+	})
+}
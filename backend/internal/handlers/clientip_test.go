@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http/httptest"
+	"testing"
+
+	"miniPanel/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestClientIP(t *testing.T) {
+	verified := &tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "web-01"}}}},
+	}
+
+	tests := []struct {
+		name    string
+		trust   bool
+		headers map[string]string
+		tls     *tls.ConnectionState
+		want    string
+	}{
+		{"default ignores headers", false, map[string]string{"X-Real-IP": "10.0.0.9"}, nil, "192.0.2.1"},
+		{"trusted X-Real-IP", true, map[string]string{"X-Real-IP": "10.0.0.9"}, nil, "10.0.0.9"},
+		{"trusted X-Forwarded-For", true, map[string]string{"X-Forwarded-For": "10.0.0.7, 10.0.0.8"}, nil, "10.0.0.7"},
+		{"trusted without headers", true, nil, nil, "192.0.2.1"},
+		{"client certificate ignores headers", true, map[string]string{"X-Real-IP": "10.0.0.9"}, verified, "192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.Server.TrustProxyHeaders = tt.trust
+			h := &Handler{cfg: cfg}
+
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("POST", "/api/metrics", nil)
+			c.Request.RemoteAddr = "192.0.2.1:40000"
+			c.Request.TLS = tt.tls
+			for k, v := range tt.headers {
+				c.Request.Header.Set(k, v)
+			}
+
+			if got := h.clientIP(c); got != tt.want {
+				t.Errorf("clientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultConfigDoesNotTrustProxyHeaders(t *testing.T) {
+	if config.DefaultConfig().Server.TrustProxyHeaders {
+		t.Error("trust_proxy_headers is enabled by default")
+	}
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"miniPanel/internal/models"
+)
+
+// clockSkewResolution 测得的偏差变化超过此值时才更新节点记录，避免网络延迟的抖动导致每次上报都写入
+const clockSkewResolution = time.Second
+
+var (
+	errClockSkew      = errors.New("agent clock is out of sync")
+	errTimestampRange = errors.New("sample timestamp out of range")
+)
+
+// checkClock 测量Agent时钟偏差并校验数据时间，通过后数据时间统一为 UTC。
+//
+// Agent携带发送时间（sent_at）时，发送时间与接收时间之差即为时钟偏差（包含网络延迟）。
+// 偏差超过 max_clock_skew 时，按配置将数据时间减去偏差，或拒绝数据。
+// 补发的暂存数据时间较早但发送时间正确，不会被误判为时钟偏差。
+// 之后仍然晚于接收时间 max_clock_skew 以上的数据（如不发送 sent_at 的旧版Agent）校正为接收时间或被拒绝，
+// 早于 max_sample_age 的数据总是被拒绝
+func (h *Handler) checkClock(node *models.Node, metrics *models.AgentMetrics, receivedAt time.Time) error {
+	tolerance := time.Duration(h.cfg.Ingest.MaxClockSkew) * time.Second
+	correct := h.cfg.Ingest.CorrectClockSkew
+
+	if !metrics.SentAt.IsZero() {
+		skew := metrics.SentAt.Sub(receivedAt)
+		h.recordClockSkew(node, skew, tolerance)
+		if skew > tolerance || skew < -tolerance {
+			if !correct {
+				return fmt.Errorf("%w: off by %v", errClockSkew, skew.Round(time.Second))
+			}
+			metrics.Timestamp = metrics.Timestamp.Add(-skew)
+		}
+	}
+
+	if metrics.Timestamp.After(receivedAt.Add(tolerance)) {
+		if !correct {
+			return fmt.Errorf("%w: %v is in the future", errTimestampRange, metrics.Timestamp.UTC().Format(time.RFC3339))
+		}
+		metrics.Timestamp = receivedAt
+	}
+	if maxAge := h.cfg.Ingest.MaxSampleAge; maxAge > 0 && metrics.Timestamp.Before(receivedAt.Add(-time.Duration(maxAge)*time.Second)) {
+		return fmt.Errorf("%w: %v is older than %ds", errTimestampRange, metrics.Timestamp.UTC().Format(time.RFC3339), maxAge)
+	}
+
+	metrics.Timestamp = metrics.Timestamp.UTC()
+	return nil
+}
+
+// recordClockSkew 保存测得的时钟偏差，偏差超出或回到允许范围时记录节点事件
+func (h *Handler) recordClockSkew(node *models.Node, skew, tolerance time.Duration) {
+	prev := time.Duration(node.ClockSkewMs) * time.Millisecond
+	if d := skew - prev; d < clockSkewResolution && d > -clockSkewResolution {
+		return
+	}
+	if err := h.db.SetNodeClockSkew(node.ID, skew.Milliseconds()); err != nil {
+		log.Printf("Failed to update clock skew for node %s: %v", node.Name, err)
+		return
+	}
+	node.ClockSkewMs = skew.Milliseconds()
+
+	wasSkewed := prev > tolerance || prev < -tolerance
+	skewed := skew > tolerance || skew < -tolerance
+	if wasSkewed == skewed {
+		return
+	}
+
+	message := fmt.Sprintf("Agent clock is off by %v", skew.Round(time.Second))
+	if !skewed {
+		message = fmt.Sprintf("Agent clock is back in sync (off by %v)", skew.Round(time.Millisecond))
+	}
+	log.Printf("Node %s: %s", node.Name, message)
+	if err := h.db.AddNodeEvent(&models.NodeEvent{NodeID: node.ID, Type: models.EventClockSkew, Message: message}); err != nil {
+		log.Printf("Failed to record event for node %s: %v", node.Name, err)
+	}
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"miniPanel/internal/config"
+	"miniPanel/internal/database"
+	"miniPanel/internal/models"
+)
+
+// clockStore 记录 checkClock 写入的时钟偏差和节点事件
+type clockStore struct {
+	database.Store
+	skews  []int64
+	events []models.NodeEvent
+}
+
+func (s *clockStore) SetNodeClockSkew(id int, skewMs int64) error {
+	s.skews = append(s.skews, skewMs)
+	return nil
+}
+
+func (s *clockStore) AddNodeEvent(event *models.NodeEvent) error {
+	s.events = append(s.events, *event)
+	return nil
+}
+
+func TestCheckClock(t *testing.T) {
+	received := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	shanghai := time.FixedZone("CST", 8*3600)
+
+	tests := []struct {
+		name       string
+		correct    bool
+		prevSkewMs int64
+		timestamp  time.Time
+		sentAt     time.Time
+		wantTime   time.Time // 校验通过后的数据时间
+		wantErr    error
+		wantSkews  []int64
+		wantEvents int
+	}{
+		{
+			name:      "in sync",
+			correct:   true,
+			timestamp: received.Add(-2 * time.Second),
+			sentAt:    received,
+			wantTime:  received.Add(-2 * time.Second),
+		},
+		{
+			name:      "non-UTC zone is converted",
+			correct:   true,
+			timestamp: received.Add(-time.Second).In(shanghai),
+			sentAt:    received.In(shanghai),
+			wantTime:  received.Add(-time.Second),
+		},
+		{
+			name:       "fast clock corrected",
+			correct:    true,
+			timestamp:  received.Add(5*time.Minute - time.Second),
+			sentAt:     received.Add(5 * time.Minute),
+			wantTime:   received.Add(-time.Second),
+			wantSkews:  []int64{300000},
+			wantEvents: 1,
+		},
+		{
+			name:       "slow clock corrected",
+			correct:    true,
+			timestamp:  received.Add(-10 * time.Minute),
+			sentAt:     received.Add(-10 * time.Minute),
+			wantTime:   received,
+			wantSkews:  []int64{-600000},
+			wantEvents: 1,
+		},
+		{
+			name:       "skew rejected",
+			correct:    false,
+			timestamp:  received.Add(5 * time.Minute),
+			sentAt:     received.Add(5 * time.Minute),
+			wantErr:    errClockSkew,
+			wantSkews:  []int64{300000},
+			wantEvents: 1,
+		},
+		{
+			name:       "back in sync",
+			correct:    true,
+			prevSkewMs: 300000,
+			timestamp:  received,
+			sentAt:     received,
+			wantTime:   received,
+			wantSkews:  []int64{0},
+			wantEvents: 1,
+		},
+		{
+			name:       "skew within resolution not recorded",
+			correct:    true,
+			prevSkewMs: 300000,
+			timestamp:  received.Add(5*time.Minute + 500*time.Millisecond),
+			sentAt:     received.Add(5*time.Minute + 500*time.Millisecond),
+			wantTime:   received,
+		},
+		{
+			name:      "spooled sample keeps its time",
+			correct:   true,
+			timestamp: received.Add(-3 * time.Hour),
+			sentAt:    received,
+			wantTime:  received.Add(-3 * time.Hour),
+		},
+		{
+			name:      "old agent in the future corrected",
+			correct:   true,
+			timestamp: received.Add(time.Hour),
+			wantTime:  received,
+		},
+		{
+			name:      "old agent in the future rejected",
+			correct:   false,
+			timestamp: received.Add(time.Hour),
+			wantErr:   errTimestampRange,
+		},
+		{
+			name:      "within tolerance in the future",
+			correct:   false,
+			timestamp: received.Add(30 * time.Second),
+			wantTime:  received.Add(30 * time.Second),
+		},
+		{
+			name:      "older than max_sample_age",
+			correct:   true,
+			timestamp: received.Add(-8 * 24 * time.Hour),
+			sentAt:    received,
+			wantErr:   errTimestampRange,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.Ingest.MaxClockSkew = 60
+			cfg.Ingest.CorrectClockSkew = tt.correct
+			cfg.Ingest.MaxSampleAge = 7 * 24 * 3600
+			store := &clockStore{}
+			h := &Handler{db: store, cfg: cfg}
+			node := &models.Node{ID: 1, Name: "web-01", ClockSkewMs: tt.prevSkewMs}
+			metrics := &models.AgentMetrics{Timestamp: tt.timestamp, SentAt: tt.sentAt}
+
+			err := h.checkClock(node, metrics, received)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if !metrics.Timestamp.Equal(tt.wantTime) || metrics.Timestamp.Location() != time.UTC {
+					t.Errorf("timestamp = %v, want %v in UTC", metrics.Timestamp, tt.wantTime)
+				}
+			}
+			if len(store.skews) != len(tt.wantSkews) {
+				t.Fatalf("recorded skews %v, want %v", store.skews, tt.wantSkews)
+			}
+			for i := range store.skews {
+				if store.skews[i] != tt.wantSkews[i] {
+					t.Errorf("recorded skews %v, want %v", store.skews, tt.wantSkews)
+				}
+			}
+			if len(store.events) != tt.wantEvents {
+				t.Errorf("recorded %d events, want %d", len(store.events), tt.wantEvents)
+			}
+			for _, e := range store.events {
+				if e.Type != models.EventClockSkew {
+					t.Errorf("event type = %s, want %s", e.Type, models.EventClockSkew)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalizeReport(t *testing.T) {
+	tests := []struct {
+		version                           int
+		wantLabels, wantChecks, wantUnits bool // 缺少时是否补为空值
+	}{
+		{0, false, false, false},
+		{1, true, false, false},
+		{2, true, true, false},
+		{3, true, true, true},
+		{4, true, true, true},
+	}
+	for _, tt := range tests {
+		metrics := &models.AgentMetrics{ProtocolVersion: tt.version}
+		normalizeReport(metrics)
+		if metrics.Timestamp.IsZero() {
+			t.Errorf("v%d: timestamp not set", tt.version)
+		}
+		if (metrics.Labels != nil) != tt.wantLabels || (metrics.Checks != nil) != tt.wantChecks || (metrics.Units != nil) != tt.wantUnits {
+			t.Errorf("v%d: labels/checks/units set = %v/%v/%v, want %v/%v/%v", tt.version,
+				metrics.Labels != nil, metrics.Checks != nil, metrics.Units != nil, tt.wantLabels, tt.wantChecks, tt.wantUnits)
+		}
+	}
+
+	// Agent没有获取到单元状态时保留已有单元
+	metrics := &models.AgentMetrics{ProtocolVersion: 3, UnitsUnavailable: true}
+	normalizeReport(metrics)
+	if metrics.Units != nil {
+		t.Errorf("units unavailable: units = %v, want nil", metrics.Units)
+	}
+}
@@ -6,8 +6,15 @@ import (
 	"strings"
 	"time"
 
+	"miniPanel/internal/alerts"
+	"miniPanel/internal/backup"
+	"miniPanel/internal/cache"
+	"miniPanel/internal/config"
 	"miniPanel/internal/database"
+	"miniPanel/internal/ingest"
 	"miniPanel/internal/models"
+	"miniPanel/internal/tlsutil"
+	"miniPanel/internal/wire"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -15,14 +22,27 @@ import (
 )
 
 type Handler struct {
-	db        *database.DB
+	db        database.Store
+	cfg       *config.Config
+	backups   *backup.Manager
+	writer    *ingest.Writer
+	recent    *cache.Window
+	streams   *streamHub
+	alerts    *alerts.Engine
+	configs   configCache
 	jwtSecret string
 }
 
-func NewHandler(db *database.DB, jwtSecret string) *Handler {
+func NewHandler(db database.Store, cfg *config.Config, backups *backup.Manager, writer *ingest.Writer, alerter *alerts.Engine) *Handler {
 	return &Handler{
 		db:        db,
-		jwtSecret: jwtSecret,
+		cfg:       cfg,
+		backups:   backups,
+		writer:    writer,
+		alerts:    alerter,
+		recent:    cache.NewWindow(time.Duration(cfg.Cache.WindowMinutes)*time.Minute, cfg.Cache.MaxSamples),
+		streams:   newStreamHub(),
+		jwtSecret: cfg.Auth.JWTSecret,
 	}
 }
 
@@ -48,10 +68,33 @@ func (h *Handler) generateToken(user *models.User) (string, error) {
 	return token.SignedString([]byte(h.jwtSecret))
 }
 
-// JWT中间件
+// JWT中间件，同时接受 X-API-Key 或 Bearer 形式的API密钥
 func (h *Handler) JWTMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
+		apiKey := c.GetHeader("X-API-Key")
+		if apiKey == "" && strings.HasPrefix(authHeader, "Bearer "+apiKeyPrefix) {
+			apiKey = strings.TrimPrefix(authHeader, "Bearer ")
+		}
+
+		if apiKey != "" {
+			key, ok := h.authenticateAPIKey(apiKey)
+			if !ok {
+				c.JSON(http.StatusUnauthorized, models.APIResponse{
+					Success: false,
+					Message: "Invalid API key",
+				})
+				c.Abort()
+				return
+			}
+
+			c.Set("user_id", key.UserID)
+			c.Set("api_key_id", key.ID)
+			c.Set("scopes", key.Scopes)
+			c.Next()
+			return
+		}
+
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, models.APIResponse{
 				Success: false,
@@ -87,6 +130,8 @@ func (h *Handler) JWTMiddleware() gin.HandlerFunc {
 
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
+		// 登录会话拥有全部权限
+		c.Set("scopes", []string{ScopeAdmin})
 		c.Next()
 	}
 }
@@ -138,8 +183,17 @@ func (h *Handler) Login(c *gin.Context) {
 	})
 }
 
-// 获取节点列表
+// 获取节点列表，支持 selector 或 group 参数按标签过滤
 func (h *Handler) GetNodes(c *gin.Context) {
+	sel, err := h.selectorFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
 	nodes, err := h.db.GetAllNodes()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
@@ -149,9 +203,20 @@ func (h *Handler) GetNodes(c *gin.Context) {
 		return
 	}
 
+	// 已归档和已退役的节点默认不显示
+	if c.Query("include_archived") != "true" {
+		active := []models.Node{}
+		for _, node := range nodes {
+			if node.Lifecycle == models.NodeActive {
+				active = append(active, node)
+			}
+		}
+		nodes = active
+	}
+
 	c.JSON(http.StatusOK, models.NodesResponse{
 		Success: true,
-		Data:    nodes,
+		Data:    filterNodes(nodes, sel),
 	})
 }
 
@@ -175,8 +240,8 @@ func (h *Handler) GetRealTimeMetrics(c *gin.Context) {
 		return
 	}
 
-	metrics, err := h.db.GetLatestMetrics(nodeID)
-	if err != nil {
+	metrics := h.latestSample(nodeID)
+	if metrics == nil {
 		c.JSON(http.StatusNotFound, models.APIResponse{
 			Success: false,
 			Message: "No metrics found for this node",
@@ -190,24 +255,26 @@ func (h *Handler) GetRealTimeMetrics(c *gin.Context) {
 	})
 }
 
-// 获取历史监控数据
+// 获取历史监控数据，指定 node_id 或 selector/group 参数；
+// 时间范围由 minutes 或 days 指定，缓存范围内的查询不访问数据库
 func (h *Handler) GetHistoryMetrics(c *gin.Context) {
 	nodeIDStr := c.Query("node_id")
 	daysStr := c.DefaultQuery("days", "1")
+	minutesStr := c.Query("minutes")
 
-	if nodeIDStr == "" {
+	sel, err := h.selectorFromQuery(c)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, models.APIResponse{
 			Success: false,
-			Message: "node_id parameter required",
+			Message: err.Error(),
 		})
 		return
 	}
 
-	nodeID, err := strconv.Atoi(nodeIDStr)
-	if err != nil {
+	if nodeIDStr == "" && sel == nil {
 		c.JSON(http.StatusBadRequest, models.APIResponse{
 			Success: false,
-			Message: "Invalid node_id",
+			Message: "node_id, selector or group parameter required",
 		})
 		return
 	}
@@ -216,8 +283,53 @@ func (h *Handler) GetHistoryMetrics(c *gin.Context) {
 	if err != nil || days <= 0 {
 		days = 1
 	}
+	since := time.Now().AddDate(0, 0, -days)
+	if minutesStr != "" {
+		minutes, err := strconv.Atoi(minutesStr)
+		if err != nil || minutes <= 0 {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Message: "Invalid minutes",
+			})
+			return
+		}
+		since = time.Now().Add(-time.Duration(minutes) * time.Minute)
+	}
+
+	var nodeIDs []int
+	if nodeIDStr != "" {
+		nodeID, err := strconv.Atoi(nodeIDStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Message: "Invalid node_id",
+			})
+			return
+		}
+		nodeIDs = append(nodeIDs, nodeID)
+	} else {
+		nodes, err := h.db.GetAllNodes()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Message: "Failed to get nodes",
+			})
+			return
+		}
+		for _, node := range filterNodes(nodes, sel) {
+			nodeIDs = append(nodeIDs, node.ID)
+		}
+	}
 
-	metrics, err := h.db.GetHistoryMetrics(nodeID, days)
+	var metrics []models.SystemMetrics
+	for _, nodeID := range nodeIDs {
+		var list []models.SystemMetrics
+		list, err = h.metricsSince(nodeID, since)
+		if err != nil {
+			break
+		}
+		metrics = append(metrics, list...)
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
@@ -234,8 +346,11 @@ func (h *Handler) GetHistoryMetrics(c *gin.Context) {
 
 // Agent上报数据接口
 func (h *Handler) ReceiveMetrics(c *gin.Context) {
-	var agentMetrics models.AgentMetrics
-	if err := c.ShouldBindJSON(&agentMetrics); err != nil {
+	// 告知Agent支持的上报格式，包括错误响应
+	c.Header("Accept-Post", wire.AcceptPost)
+
+	agentMetrics, err := bindReport(c)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, models.APIResponse{
 			Success: false,
 			Message: "Invalid request format",
@@ -243,58 +358,131 @@ func (h *Handler) ReceiveMetrics(c *gin.Context) {
 		return
 	}
 
-	// 设置时间戳
-	if agentMetrics.Timestamp.IsZero() {
-		agentMetrics.Timestamp = time.Now()
-	}
-
-	// 获取客户端IP作为节点标识
-	clientIP := c.ClientIP()
-	if c.GetHeader("X-Real-IP") != "" {
-		clientIP = c.GetHeader("X-Real-IP")
-	} else if c.GetHeader("X-Forwarded-For") != "" {
-		clientIP = strings.Split(c.GetHeader("X-Forwarded-For"), ",")[0]
+	// 优先使用客户端证书中的身份
+	identity := tlsutil.PeerIdentity(c.Request.TLS)
+	if identity == "" && h.cfg.Server.TLS.RequireAgentCert {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Message: "Client certificate required",
+		})
+		return
 	}
 
-	// 创建或更新节点信息
+	// 未使用证书时以 Node-Name 请求头作为节点名称，没有时使用客户端IP
+	clientIP := h.clientIP(c)
 	nodeName := c.GetHeader("Node-Name")
 	if nodeName == "" {
 		nodeName = clientIP
 	}
 
-	err := h.db.CreateOrUpdateNode(nodeName, clientIP)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
+	result := h.acceptReport(identity, nodeName, clientIP, agentMetrics)
+	if result.status != http.StatusOK {
+		if result.status == http.StatusTooManyRequests {
+			// 队列已满时让Agent稍后重试
+			c.Header("Retry-After", strconv.Itoa(h.cfg.Ingest.RetryAfter))
+		}
+		c.JSON(result.status, models.APIResponse{
 			Success: false,
-			Message: "Failed to update node info",
+			Message: result.message,
 		})
 		return
 	}
 
-	// 获取节点ID
-	node, err := h.db.GetNodeByIP(clientIP)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
-			Success: false,
-			Message: "Failed to get node info",
-		})
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: result.message,
+		Data:    result.ack,
+	})
+}
+
+// Agent检查连接、TLS和认证配置，返回服务器识别出的节点身份，不保存数据
+func (h *Handler) CheckAgent(c *gin.Context) {
+	c.Header("Accept-Post", wire.AcceptPost)
+
+	resp, node, ok := h.findAgentNode(c)
+	if !ok {
 		return
 	}
+	if node != nil {
+		resp.NodeID = node.ID
+	}
 
-	agentMetrics.NodeID = node.ID
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    resp,
+	})
+}
 
-	// 插入监控数据
-	err = h.db.InsertMetrics(&agentMetrics)
+// findAgentNode 按上报数据时的规则确定Agent身份并查找已登记的节点，节点不存在时为 nil。
+// 需要客户端证书但未提供、或节点已退役时写入错误响应并返回 false
+func (h *Handler) findAgentNode(c *gin.Context) (models.AgentCheckResponse, *models.Node, bool) {
+	identity := tlsutil.PeerIdentity(c.Request.TLS)
+	resp := models.AgentCheckResponse{
+		NodeName: identity,
+		Identity: "certificate",
+		ClientIP: h.clientIP(c),
+	}
+	if identity == "" && h.cfg.Server.TLS.RequireAgentCert {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Message: "Client certificate required",
+		})
+		return resp, nil, false
+	}
+
+	var node *models.Node
+	var err error
+	if identity != "" {
+		node, err = h.db.GetNodeByName(identity)
+	} else {
+		resp.NodeName, resp.Identity = c.GetHeader("Node-Name"), "header"
+		if resp.NodeName == "" {
+			resp.NodeName, resp.Identity = resp.ClientIP, "ip"
+		}
+		node, err = h.db.GetNodeByIP(resp.ClientIP)
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
+		return resp, nil, true
+	}
+	if node.Lifecycle == models.NodeDecommissioned {
+		c.JSON(http.StatusGone, models.APIResponse{
 			Success: false,
-			Message: "Failed to save metrics",
+			Message: "Node has been decommissioned",
 		})
-		return
+		return resp, nil, false
 	}
+	return resp, node, true
+}
 
-	c.JSON(http.StatusOK, models.APIResponse{
-		Success: true,
-		Message: "Metrics received successfully",
-	})
-}
\ No newline at end of file
+// clientIP 获取客户端IP，仅在配置信任时使用代理请求头。
+// 提供了有效客户端证书的连接直接来自Agent而不是代理，始终使用连接的源地址
+func (h *Handler) clientIP(c *gin.Context) string {
+	if !h.cfg.Server.TrustProxyHeaders || tlsutil.PeerIdentity(c.Request.TLS) != "" {
+		return c.RemoteIP()
+	}
+
+	clientIP := c.ClientIP()
+	if c.GetHeader("X-Real-IP") != "" {
+		clientIP = c.GetHeader("X-Real-IP")
+	} else if c.GetHeader("X-Forwarded-For") != "" {
+		clientIP = strings.TrimSpace(strings.Split(c.GetHeader("X-Forwarded-For"), ",")[0])
+	}
+	return clientIP
+}
+
+// upsertAgentNode 按Agent身份创建或更新节点：有证书身份时以证书身份作为节点名称，
+// 否则以客户端IP作为节点标识
+func (h *Handler) upsertAgentNode(identity, nodeName, clientIP string) (*models.Node, error) {
+	if identity != "" {
+		return h.db.UpsertNodeByName(identity, clientIP)
+	}
+	return h.upsertNodeByIP(nodeName, clientIP)
+}
+
+// upsertNodeByIP 以IP作为节点标识创建或更新节点
+func (h *Handler) upsertNodeByIP(nodeName, clientIP string) (*models.Node, error) {
+	if err := h.db.CreateOrUpdateNode(nodeName, clientIP); err != nil {
+		return nil, err
+	}
+	return h.db.GetNodeByIP(clientIP)
+}
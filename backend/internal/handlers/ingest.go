@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"time"
+
+	"miniPanel/internal/cache"
+	"miniPanel/internal/labels"
+	"miniPanel/internal/models"
+	"miniPanel/internal/wire"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxReportSize 一次上报数据的最大字节数
+const maxReportSize = 4 << 20
+
+var (
+	errNodeDecommissioned = errors.New("node has been decommissioned")
+	errQueueFull          = errors.New("ingest queue is full")
+)
+
+// bindReport 按 Content-Type 解析上报数据：application/x-protobuf 为 protobuf，
+// 其他（包括未设置 Content-Type 的旧版Agent）按 JSON 解析
+func bindReport(c *gin.Context) (*models.AgentMetrics, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxReportSize))
+	if err != nil {
+		return nil, err
+	}
+
+	var metrics *models.AgentMetrics
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if mediaType == wire.ContentTypeProtobuf {
+		if metrics, err = wire.DecodeReport(body); err != nil {
+			return nil, err
+		}
+	} else {
+		metrics = &models.AgentMetrics{}
+		if err := json.Unmarshal(body, metrics); err != nil {
+			return nil, err
+		}
+	}
+
+	return metrics, nil
+}
+
+// reportResult 处理一条上报数据的结果，status 与 POST /api/metrics 的状态码相同
+type reportResult struct {
+	status  int
+	message string
+	ack     models.MetricsAck
+}
+
+// acceptReport 确定节点并处理一条上报数据，HTTP 上报和 gRPC 流共用
+func (h *Handler) acceptReport(identity, nodeName, clientIP string, metrics *models.AgentMetrics) reportResult {
+	node, err := h.upsertAgentNode(identity, nodeName, clientIP)
+	if err != nil {
+		return reportResult{status: http.StatusInternalServerError, message: "Failed to update node info"}
+	}
+
+	switch err := h.ingest(node, metrics); {
+	case err == nil:
+	case errors.Is(err, errNodeDecommissioned):
+		return reportResult{status: http.StatusGone, message: "Node has been decommissioned"}
+	case errors.Is(err, errQueueFull):
+		return reportResult{status: http.StatusTooManyRequests, message: "Server is busy, retry later"}
+	case errors.Is(err, errClockSkew), errors.Is(err, errTimestampRange):
+		// 重发也不会被接受，Agent丢弃这条数据
+		return reportResult{status: http.StatusUnprocessableEntity, message: err.Error()}
+	}
+
+	// 返回集中配置版本，Agent发现版本变化时获取新配置
+	result := reportResult{status: http.StatusOK, message: "Metrics received successfully"}
+	if cfg, err := h.effectiveConfig(node.ID); err != nil {
+		log.Printf("Failed to get config for node %s: %v", node.Name, err)
+	} else {
+		result.ack.ConfigVersion = cfg.Version
+	}
+	return result
+}
+
+// normalizeReport 按协议版本补全上报数据，推送和拉取的数据在校验时间前都经过这里
+func normalizeReport(metrics *models.AgentMetrics) {
+	// 设置时间戳
+	if metrics.Timestamp.IsZero() {
+		metrics.Timestamp = time.Now()
+	}
+	// 协议版本 1 起标签总是完整发送，没有标签表示Agent未配置标签；旧版Agent不发送标签时保留已有标签。
+	// 比服务器更新的版本按已知字段处理
+	if metrics.ProtocolVersion >= 1 && metrics.Labels == nil {
+		metrics.Labels = map[string]string{}
+	}
+	// 协议版本 2 起服务检查结果也总是完整发送，版本 3 起 systemd 单元也是
+	if metrics.ProtocolVersion >= 2 && metrics.Checks == nil {
+		metrics.Checks = []models.CheckResult{}
+	}
+	// Agent没有获取到单元状态时不携带单元，保留已有单元
+	if metrics.UnitsUnavailable {
+		metrics.Units = nil
+	} else if metrics.ProtocolVersion >= 3 && metrics.Units == nil {
+		metrics.Units = []models.UnitStatus{}
+	}
+}
+
+// ingest 处理一条已确定节点的监控数据：补全默认值，校验数据时间，同步标签、主机信息、服务检查和 systemd 单元，放入写入队列并更新缓存。
+// 推送和拉取的数据都经过这里
+func (h *Handler) ingest(node *models.Node, metrics *models.AgentMetrics) error {
+	if node.Lifecycle == models.NodeDecommissioned {
+		return errNodeDecommissioned
+	}
+	normalizeReport(metrics)
+	if err := h.checkClock(node, metrics, time.Now()); err != nil {
+		return err
+	}
+
+	metrics.NodeID = node.ID
+
+	// 同步Agent配置的标签，格式错误时只忽略标签
+	if metrics.Labels != nil {
+		if err := labels.Validate(metrics.Labels); err != nil {
+			log.Printf("Ignoring labels from node %s: %v", node.Name, err)
+		} else if changed, err := h.db.SetAgentLabels(node.ID, metrics.Labels); err != nil {
+			log.Printf("Failed to update labels for node %s: %v", node.Name, err)
+		} else if changed {
+			// 标签可能改变适用的集中配置
+			h.configs.invalidate(node.ID)
+		}
+	}
+
+	// 保存主机信息，失败时不影响监控数据
+	if metrics.Host != nil {
+		if err := h.db.UpdateNodeInventory(node.ID, metrics.Host); err != nil {
+			log.Printf("Failed to update inventory for node %s: %v", node.Name, err)
+		}
+	}
+
+	if metrics.Checks != nil {
+		h.syncChecks(node, metrics.Timestamp, metrics.Checks)
+	}
+	if metrics.Units != nil {
+		h.syncUnits(node, metrics.Timestamp, metrics.Units)
+	}
+
+	if !h.writer.Enqueue(metrics) {
+		return errQueueFull
+	}
+	h.recent.Add(cache.FromAgent(metrics), metrics.Timestamp)
+	return nil
+}
+
+// IngestScraped 处理从Agent拉取的数据，节点以名称作为标识，与证书身份相同
+func (h *Handler) IngestScraped(name, ip string, metrics *models.AgentMetrics) error {
+	node, err := h.db.UpsertNodeByName(name, ip)
+	if err != nil {
+		return err
+	}
+	return h.ingest(node, metrics)
+}
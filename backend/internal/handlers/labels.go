@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"miniPanel/internal/labels"
+	"miniPanel/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// selectorFromQuery 从 selector 或 group 查询参数得到标签选择器，都未指定时返回 nil
+func (h *Handler) selectorFromQuery(c *gin.Context) (*labels.Selector, error) {
+	if group := c.Query("group"); group != "" {
+		g, err := h.db.GetGroupByName(group)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("group not found: %s", group)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return labels.Parse(g.Selector)
+	}
+
+	if expr := c.Query("selector"); expr != "" {
+		return labels.Parse(expr)
+	}
+	return nil, nil
+}
+
+// filterNodes 返回满足选择器的节点
+func filterNodes(nodes []models.Node, sel *labels.Selector) []models.Node {
+	if sel == nil || sel.Empty() {
+		return nodes
+	}
+
+	filtered := []models.Node{}
+	for _, node := range nodes {
+		if sel.Matches(node.Labels) {
+			filtered = append(filtered, node)
+		}
+	}
+	return filtered
+}
+
+// 设置节点标签（替换管理员设置的全部标签）
+func (h *Handler) SetNodeLabels(c *gin.Context) {
+	nodeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid node id",
+		})
+		return
+	}
+
+	var req models.SetLabelsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid request format",
+		})
+		return
+	}
+	if err := labels.Validate(req.Labels); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if _, err := h.db.GetNodeByID(nodeID); err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "Node not found",
+		})
+		return
+	}
+
+	if err := h.db.SetAdminLabels(nodeID, req.Labels); err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to save labels",
+		})
+		return
+	}
+	// 标签可能改变适用的集中配置
+	h.configs.invalidate(nodeID)
+	go h.notifyConfigChanged()
+
+	node, err := h.db.GetNodeByID(nodeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to get node info",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    node,
+	})
+}
+
+// 获取分组列表
+func (h *Handler) GetGroups(c *gin.Context) {
+	groups, err := h.db.GetGroups()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to get groups",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    groups,
+	})
+}
+
+// 创建分组
+func (h *Handler) CreateGroup(c *gin.Context) {
+	var req models.CreateGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid request format",
+		})
+		return
+	}
+
+	sel, err := labels.Parse(req.Selector)
+	if err != nil || sel.Empty() {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid selector",
+		})
+		return
+	}
+
+	group := &models.NodeGroup{
+		Name:     strings.TrimSpace(req.Name),
+		Selector: sel.String(),
+	}
+	if err := h.db.CreateGroup(group); err != nil {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Message: "Failed to create group, name may already exist",
+		})
+		return
+	}
+
+	if created, err := h.db.GetGroupByName(group.Name); err == nil {
+		group = created
+	}
+	// 指定该分组名称的集中配置可能重新适用
+	h.configs.invalidateAll()
+	go h.notifyConfigChanged()
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data:    group,
+	})
+}
+
+// 删除分组
+func (h *Handler) DeleteGroup(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid group id",
+		})
+		return
+	}
+
+	deleted, err := h.db.DeleteGroup(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to delete group",
+		})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "Group not found",
+		})
+		return
+	}
+
+	// 指定该分组的集中配置不再适用
+	h.configs.invalidateAll()
+	go h.notifyConfigChanged()
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Group deleted",
+	})
+}
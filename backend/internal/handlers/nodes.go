@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"miniPanel/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// nodeIDParam 解析路径中的节点ID，失败时直接返回400
+func nodeIDParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid node id",
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// respondNode 返回节点最新信息
+func (h *Handler) respondNode(c *gin.Context, id int) {
+	node, err := h.db.GetNodeByID(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to get node info",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    node,
+	})
+}
+
+// 获取单个节点，包含主机信息
+func (h *Handler) GetNode(c *gin.Context) {
+	id, ok := nodeIDParam(c)
+	if !ok {
+		return
+	}
+
+	node, err := h.db.GetNodeByID(id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "Node not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to get node info",
+		})
+		return
+	}
+
+	node.Inventory, err = h.db.GetNodeInventory(id)
+	if err != nil && err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to get node inventory",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    node,
+	})
+}
+
+// 获取节点事件（重启、系统升级、Agent升级等）
+func (h *Handler) GetNodeEvents(c *gin.Context) {
+	id, ok := nodeIDParam(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid limit parameter",
+		})
+		return
+	}
+
+	events, err := h.db.GetNodeEvents(id, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to get node events",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    events,
+	})
+}
+
+// 重命名节点
+func (h *Handler) RenameNode(c *gin.Context) {
+	id, ok := nodeIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.RenameNodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Name) == "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid request format",
+		})
+		return
+	}
+
+	updated, err := h.db.RenameNode(id, strings.TrimSpace(req.Name))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to rename node",
+		})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "Node not found",
+		})
+		return
+	}
+
+	h.respondNode(c, id)
+}
+
+// setLifecycle 返回修改节点生命周期状态的处理函数
+func (h *Handler) setLifecycle(lifecycle string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := nodeIDParam(c)
+		if !ok {
+			return
+		}
+
+		updated, err := h.db.SetNodeLifecycle(id, lifecycle)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Message: "Failed to update node",
+			})
+			return
+		}
+		if !updated {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false,
+				Message: "Node not found",
+			})
+			return
+		}
+
+		h.respondNode(c, id)
+	}
+}
+
+// 归档节点：默认列表中隐藏，重新上报后自动恢复
+func (h *Handler) ArchiveNode(c *gin.Context) {
+	h.setLifecycle(models.NodeArchived)(c)
+}
+
+// 退役节点：拒绝该节点后续上报，保留历史数据
+func (h *Handler) DecommissionNode(c *gin.Context) {
+	h.setLifecycle(models.NodeDecommissioned)(c)
+}
+
+// 恢复节点为活跃状态
+func (h *Handler) ActivateNode(c *gin.Context) {
+	h.setLifecycle(models.NodeActive)(c)
+}
+
+// 删除节点及其全部历史数据
+func (h *Handler) DeleteNode(c *gin.Context) {
+	id, ok := nodeIDParam(c)
+	if !ok {
+		return
+	}
+
+	deleted, err := h.db.DeleteNode(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to delete node",
+		})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "Node not found",
+		})
+		return
+	}
+	h.recent.Delete(id)
+	h.configs.invalidate(id)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Node deleted",
+	})
+}
+
+// 合并节点：把 source_id 的历史数据并入路径中的节点
+func (h *Handler) MergeNodes(c *gin.Context) {
+	id, ok := nodeIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.MergeNodesRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.SourceID == id {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid request format",
+		})
+		return
+	}
+
+	err := h.db.MergeNodes(id, req.SourceID)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "Node not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to merge nodes",
+		})
+		return
+	}
+
+	h.recent.Merge(id, req.SourceID)
+	h.configs.invalidate(id)
+	h.configs.invalidate(req.SourceID)
+
+	h.respondNode(c, id)
+}
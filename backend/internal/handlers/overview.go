@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"miniPanel/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 获取全部节点概览：每个节点的最新数据和最近1小时统计，以及全局汇总。
+// 支持与节点列表相同的 selector、group 和 include_archived 参数
+func (h *Handler) GetOverview(c *gin.Context) {
+	sel, err := h.selectorFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	nodes, err := h.db.GetAllNodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to get nodes",
+		})
+		return
+	}
+
+	includeArchived := c.Query("include_archived") == "true"
+	overview := models.FleetOverview{
+		Nodes:       []models.NodeOverview{},
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+
+	// 缓存不能覆盖最近1小时的节点才查询数据库，并且只查询一次
+	since := time.Now().Add(-time.Hour)
+	var dbStats map[int]models.MetricsStats
+
+	var cpuSum, memorySum float64
+	var reporting int
+	for _, node := range filterNodes(nodes, sel) {
+		if !includeArchived && node.Lifecycle != models.NodeActive {
+			continue
+		}
+
+		item := models.NodeOverview{Node: node, Latest: h.latestSample(node.ID)}
+		item.Stats1h, err = h.stats(node.ID, since, &dbStats)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Message: "Failed to get metrics stats",
+			})
+			return
+		}
+		overview.Nodes = append(overview.Nodes, item)
+
+		overview.TotalNodes++
+		if node.Status == "online" {
+			overview.OnlineNodes++
+		} else {
+			overview.OfflineNodes++
+		}
+
+		latest := item.Latest
+		if latest == nil {
+			continue
+		}
+		reporting++
+		cpuSum += latest.CPUPercent
+		memorySum += latest.MemoryPercent
+		overview.HighestCPU = maxNodeValue(overview.HighestCPU, node, latest.CPUPercent)
+		overview.HighestMemory = maxNodeValue(overview.HighestMemory, node, latest.MemoryPercent)
+		// 没有温度传感器的节点上报 0，不参与比较
+		if latest.CPUTemp > 0 {
+			overview.HottestNode = maxNodeValue(overview.HottestNode, node, latest.CPUTemp)
+		}
+	}
+	if reporting > 0 {
+		overview.AvgCPU = cpuSum / float64(reporting)
+		overview.AvgMemory = memorySum / float64(reporting)
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    overview,
+	})
+}
+
+func maxNodeValue(current *models.NodeValue, node models.Node, value float64) *models.NodeValue {
+	if current != nil && current.Value >= value {
+		return current
+	}
+	return &models.NodeValue{NodeID: node.ID, Name: node.Name, Value: value}
+}
@@ -0,0 +1,430 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"miniPanel/internal/labels"
+	"miniPanel/internal/models"
+	"miniPanel/internal/release"
+
+	"github.com/gin-gonic/gin"
+)
+
+// configCache 缓存每个节点生效的集中配置，避免每次上报都重新计算。
+// 集中配置或分组变化时全部清除，节点标签变化时清除该节点。零值可以直接使用
+type configCache struct {
+	mu      sync.Mutex
+	gen     uint64 // 每次清除时加一，计算期间发生过清除的结果不保存
+	configs map[int]*models.AgentConfigResponse
+}
+
+// get 返回缓存的配置，没有缓存时返回当前的清除次数，供 put 使用
+func (c *configCache) get(nodeID int) (*models.AgentConfigResponse, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.configs[nodeID], c.gen
+}
+
+// put 保存计算结果，gen 为开始计算前 get 返回的清除次数
+func (c *configCache) put(nodeID int, gen uint64, result *models.AgentConfigResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if gen != c.gen {
+		return
+	}
+	if c.configs == nil {
+		c.configs = make(map[int]*models.AgentConfigResponse)
+	}
+	c.configs[nodeID] = result
+}
+
+// invalidate 清除一个节点的缓存
+func (c *configCache) invalidate(nodeID int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	delete(c.configs, nodeID)
+}
+
+// invalidateAll 清除全部缓存
+func (c *configCache) invalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.gen++
+	c.configs = nil
+}
+
+// effectiveConfig 返回节点生效的集中配置，优先使用缓存。返回值被多处共用，调用方不能修改
+func (h *Handler) effectiveConfig(nodeID int) (*models.AgentConfigResponse, error) {
+	result, gen := h.configs.get(nodeID)
+	if result != nil {
+		return result, nil
+	}
+	result, err := h.computeConfig(nodeID)
+	if err != nil {
+		return nil, err
+	}
+	h.configs.put(nodeID, gen, result)
+	return result, nil
+}
+
+// computeConfig 计算节点生效的集中配置：先按优先级应用全部节点、分组和选择器的配置，
+// 最后应用指定该节点的配置。没有适用的配置时版本为空
+func (h *Handler) computeConfig(nodeID int) (*models.AgentConfigResponse, error) {
+	result := &models.AgentConfigResponse{Profiles: []string{}}
+
+	profiles, err := h.db.GetConfigProfiles()
+	if err != nil || len(profiles) == 0 {
+		return result, err
+	}
+
+	nodeLabels := map[string]string{}
+	if nodeID != 0 {
+		if nodeLabels, err = h.db.GetNodeLabels(nodeID); err != nil {
+			return nil, err
+		}
+	}
+
+	var shared, own []models.ConfigProfile
+	for _, profile := range profiles {
+		if profile.NodeID != 0 {
+			if profile.NodeID == nodeID {
+				own = append(own, profile)
+			}
+			continue
+		}
+
+		expr := profile.Selector
+		if profile.Group != "" {
+			group, err := h.db.GetGroupByName(profile.Group)
+			if err == sql.ErrNoRows {
+				continue // 分组已删除
+			}
+			if err != nil {
+				return nil, err
+			}
+			expr = group.Selector
+		}
+		sel, err := labels.Parse(expr)
+		if err != nil {
+			continue
+		}
+		if sel.Matches(nodeLabels) {
+			shared = append(shared, profile)
+		}
+	}
+
+	// 后应用的配置覆盖先应用的同名配置项
+	for _, profile := range append(shared, own...) {
+		data, err := json.Marshal(profile.Config)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &result.Config); err != nil {
+			return nil, err
+		}
+		result.Profiles = append(result.Profiles, profile.Name)
+	}
+	if len(result.Profiles) == 0 {
+		return result, nil
+	}
+
+	data, err := json.Marshal(result.Config)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	result.Version = hex.EncodeToString(sum[:8])
+	return result, nil
+}
+
+// Agent获取本节点生效的集中配置，节点身份的确定方式与上报数据相同
+func (h *Handler) GetAgentConfig(c *gin.Context) {
+	_, node, ok := h.findAgentNode(c)
+	if !ok {
+		return
+	}
+
+	// 尚未上报过的节点只适用不限定节点的配置
+	nodeID := 0
+	if node != nil {
+		nodeID = node.ID
+	}
+	result, err := h.effectiveConfig(nodeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to get agent config",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    result,
+	})
+}
+
+// 获取节点生效的集中配置及其来源
+func (h *Handler) GetNodeConfig(c *gin.Context) {
+	id, ok := nodeIDParam(c)
+	if !ok {
+		return
+	}
+
+	if _, err := h.db.GetNodeByID(id); err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "Node not found",
+		})
+		return
+	}
+
+	result, err := h.effectiveConfig(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to get agent config",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    result,
+	})
+}
+
+// 获取全部Agent配置，按应用顺序排列
+func (h *Handler) GetConfigProfiles(c *gin.Context) {
+	profiles, err := h.db.GetConfigProfiles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to get config profiles",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    profiles,
+	})
+}
+
+// 添加Agent配置
+func (h *Handler) CreateConfigProfile(c *gin.Context) {
+	profile, ok := h.bindConfigProfile(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.CreateConfigProfile(profile); err != nil {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Message: "Failed to create config profile, name may already exist",
+		})
+		return
+	}
+	h.configs.invalidateAll()
+	go h.notifyConfigChanged()
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    profile,
+	})
+}
+
+// 修改Agent配置，Agent在下次上报时获取新配置，通过 gRPC 流连接的Agent立即获取
+func (h *Handler) UpdateConfigProfile(c *gin.Context) {
+	id, ok := profileIDParam(c)
+	if !ok {
+		return
+	}
+	profile, ok := h.bindConfigProfile(c)
+	if !ok {
+		return
+	}
+	profile.ID = id
+
+	updated, err := h.db.UpdateConfigProfile(profile)
+	if err != nil {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Message: "Failed to update config profile, name may already exist",
+		})
+		return
+	}
+	if !updated {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "Config profile not found",
+		})
+		return
+	}
+
+	h.configs.invalidateAll()
+	go h.notifyConfigChanged()
+
+	profile, err = h.db.GetConfigProfile(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to get config profile",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    profile,
+	})
+}
+
+// 删除Agent配置，Agent在下次上报时恢复本地配置
+func (h *Handler) DeleteConfigProfile(c *gin.Context) {
+	id, ok := profileIDParam(c)
+	if !ok {
+		return
+	}
+
+	deleted, err := h.db.DeleteConfigProfile(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to delete config profile",
+		})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "Config profile not found",
+		})
+		return
+	}
+	h.configs.invalidateAll()
+	go h.notifyConfigChanged()
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Config profile deleted",
+	})
+}
+
+func profileIDParam(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid profile id",
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// bindConfigProfile 解析并校验配置请求，出错时已写入响应
+func (h *Handler) bindConfigProfile(c *gin.Context) (*models.ConfigProfile, bool) {
+	var req models.ConfigProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid request format",
+		})
+		return nil, false
+	}
+
+	profile := &models.ConfigProfile{
+		Name:     req.Name,
+		NodeID:   req.NodeID,
+		Group:    req.Group,
+		Selector: req.Selector,
+		Priority: req.Priority,
+	}
+	if err := h.validateConfigProfile(profile, req.Config); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return nil, false
+	}
+	return profile, true
+}
+
+// validateConfigProfile 校验目标和配置内容，配置中的未知项视为错误，避免拼写错误被忽略
+func (h *Handler) validateConfigProfile(profile *models.ConfigProfile, raw json.RawMessage) error {
+	targets := 0
+	for _, set := range []bool{profile.NodeID != 0, profile.Group != "", profile.Selector != ""} {
+		if set {
+			targets++
+		}
+	}
+	if targets > 1 {
+		return fmt.Errorf("only one of node_id, group and selector can be set")
+	}
+
+	switch {
+	case profile.NodeID != 0:
+		if _, err := h.db.GetNodeByID(profile.NodeID); err != nil {
+			return fmt.Errorf("node not found: %d", profile.NodeID)
+		}
+	case profile.Group != "":
+		if _, err := h.db.GetGroupByName(profile.Group); err != nil {
+			return fmt.Errorf("group not found: %s", profile.Group)
+		}
+	case profile.Selector != "":
+		sel, err := labels.Parse(profile.Selector)
+		if err != nil {
+			return err
+		}
+		profile.Selector = sel.String()
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&profile.Config); err != nil {
+		return fmt.Errorf("invalid config: %v", err)
+	}
+
+	cfg := profile.Config
+	if cfg.Update != nil && cfg.Update.Version != nil && !release.ValidName(*cfg.Update.Version) {
+		return fmt.Errorf("invalid config: update.version is not a valid version")
+	}
+	if cfg.Agent != nil {
+		if cfg.Agent.Interval != nil && *cfg.Agent.Interval <= 0 {
+			return fmt.Errorf("invalid config: agent.interval must be positive")
+		}
+		if cfg.Agent.SpoolSize != nil && *cfg.Agent.SpoolSize < 0 {
+			return fmt.Errorf("invalid config: agent.spool_size must not be negative")
+		}
+	}
+	if cfg.Checks != nil {
+		names := map[string]bool{}
+		for i, check := range *cfg.Checks {
+			switch {
+			case check.Name == "" || check.Target == "":
+				return fmt.Errorf("invalid config: checks[%d].name and target are required", i)
+			case names[check.Name]:
+				return fmt.Errorf("invalid config: checks[%d].name is duplicated: %s", i, check.Name)
+			case !validCheckTypes[check.Type]:
+				return fmt.Errorf("invalid config: checks[%d].type must be tcp, http, process or file", i)
+			case check.Timeout < 0:
+				return fmt.Errorf("invalid config: checks[%d].timeout must not be negative", i)
+			}
+			names[check.Name] = true
+		}
+	}
+	return nil
+}
+
+// validCheckTypes Agent支持的服务检查类型
+var validCheckTypes = map[string]bool{"tcp": true, "http": true, "process": true, "file": true}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"miniPanel/internal/database"
+	"miniPanel/internal/models"
+)
+
+// profileStore 提供集中配置和节点标签，记录读取配置的次数
+type profileStore struct {
+	database.Store
+	profiles []models.ConfigProfile
+	labels   map[string]string
+	loads    int
+}
+
+func (s *profileStore) GetConfigProfiles() ([]models.ConfigProfile, error) {
+	s.loads++
+	return s.profiles, nil
+}
+
+func (s *profileStore) GetNodeLabels(nodeID int) (map[string]string, error) {
+	return s.labels, nil
+}
+
+// parseConfig 按创建配置时的方式解析配置内容
+func parseConfig(t *testing.T, raw string) models.ManagedAgentConfig {
+	t.Helper()
+	profile := &models.ConfigProfile{}
+	if err := (&Handler{}).validateConfigProfile(profile, json.RawMessage(raw)); err != nil {
+		t.Fatalf("%s: %v", raw, err)
+	}
+	return profile.Config
+}
+
+func TestEffectiveConfigChecks(t *testing.T) {
+	store := &profileStore{
+		labels: map[string]string{"env": "prod"},
+		profiles: []models.ConfigProfile{
+			{Name: "all", Config: parseConfig(t, `{"checks": [{"name": "ssh", "type": "tcp", "target": "127.0.0.1:22"}]}`)},
+			{Name: "prod", Selector: "env=prod", Config: parseConfig(t, `{"agent": {"interval": 10}}`)},
+			{Name: "web-01", NodeID: 1, Config: parseConfig(t, `{"checks": [{"name": "nginx", "type": "http", "target": "http://127.0.0.1/", "expect_status": 200}]}`)},
+		},
+	}
+	h := &Handler{db: store}
+
+	// 没有设置检查的配置不影响先应用的检查
+	got, err := h.effectiveConfig(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.ManagedCheck{{Name: "ssh", Type: "tcp", Target: "127.0.0.1:22"}}
+	if got.Config.Checks == nil || !reflect.DeepEqual(*got.Config.Checks, want) {
+		t.Errorf("node 2: checks = %+v, want %+v", got.Config.Checks, want)
+	}
+
+	// 后应用的检查整体替换先应用的
+	got, err = h.effectiveConfig(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []models.ManagedCheck{{Name: "nginx", Type: "http", Target: "http://127.0.0.1/", ExpectStatus: 200}}
+	if got.Config.Checks == nil || !reflect.DeepEqual(*got.Config.Checks, want) {
+		t.Errorf("node 1: checks = %+v, want %+v", got.Config.Checks, want)
+	}
+
+	// 空列表清除全部检查，下发给Agent时保留
+	store.profiles[2].Config = parseConfig(t, `{"checks": []}`)
+	h.configs.invalidateAll()
+	got, err = h.effectiveConfig(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(got.Config)
+	if string(data) != `{"agent":{"interval":10},"checks":[]}` {
+		t.Errorf("node 1: config = %s", data)
+	}
+}
+
+func TestEffectiveConfigCache(t *testing.T) {
+	store := &profileStore{
+		labels:   map[string]string{"env": "prod"},
+		profiles: []models.ConfigProfile{{Name: "prod", Selector: "env=prod", Config: parseConfig(t, `{"agent": {"interval": 10}}`)}},
+	}
+	h := &Handler{db: store}
+
+	version := func(nodeID int) string {
+		t.Helper()
+		result, err := h.effectiveConfig(nodeID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result.Version
+	}
+
+	// 每个节点只计算一次
+	prod := version(1)
+	if version(1) != prod || version(2) != prod || store.loads != 2 {
+		t.Fatalf("computed %d times, want 2", store.loads)
+	}
+
+	// 节点标签变化只清除该节点
+	store.labels = map[string]string{"env": "dev"}
+	h.configs.invalidate(1)
+	if version(1) != "" || version(2) != prod {
+		t.Errorf("after label change: versions %q/%q, want \"\"/%q", version(1), version(2), prod)
+	}
+
+	// 集中配置变化清除全部节点
+	h.configs.invalidateAll()
+	if version(2) != "" || store.loads != 4 {
+		t.Errorf("after profile change: version %q, computed %d times", version(2), store.loads)
+	}
+
+	// 计算期间发生清除时不保存计算结果
+	_, gen := h.configs.get(3)
+	h.configs.invalidateAll()
+	h.configs.put(3, gen, &models.AgentConfigResponse{Version: "stale"})
+	if version(3) == "stale" {
+		t.Error("stale result was cached")
+	}
+}
+
+func TestValidateConfigProfileChecks(t *testing.T) {
+	tests := []string{
+		`{"checks": [{"type": "tcp", "target": "127.0.0.1:22"}]}`,
+		`{"checks": [{"name": "ssh", "type": "ping", "target": "127.0.0.1"}]}`,
+		`{"checks": [{"name": "ssh", "type": "tcp", "target": "a:22"}, {"name": "ssh", "type": "tcp", "target": "b:22"}]}`,
+		`{"checks": [{"name": "ssh", "type": "tcp", "target": "a:22", "timeout": -1}]}`,
+		`{"checks": [{"name": "ssh", "type": "tcp", "target": "a:22", "retries": 3}]}`,
+	}
+	for _, raw := range tests {
+		if err := (&Handler{}).validateConfigProfile(&models.ConfigProfile{}, json.RawMessage(raw)); err == nil {
+			t.Errorf("%s: accepted", raw)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"miniPanel/internal/models"
+	"miniPanel/internal/release"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 获取Agent发布文件列表
+func (h *Handler) GetReleases(c *gin.Context) {
+	releases, err := h.db.GetReleases()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to get releases",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    releases,
+	})
+}
+
+// 上传Agent发布文件（multipart：file、version、os、arch、signature），
+// 配置了签名公钥时校验签名
+func (h *Handler) UploadRelease(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(h.cfg.Releases.MaxSizeMiB)<<20)
+
+	rel := &models.AgentRelease{
+		Version:   c.PostForm("version"),
+		OS:        c.PostForm("os"),
+		Arch:      c.PostForm("arch"),
+		Signature: c.PostForm("signature"),
+	}
+	file, err := c.FormFile("file")
+	if err != nil || rel.Signature == "" ||
+		!release.ValidName(rel.Version) || !release.ValidName(rel.OS) || !release.ValidName(rel.Arch) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "file, version, os, arch and signature are required",
+		})
+		return
+	}
+
+	if _, err := h.db.GetRelease(rel.Version, rel.OS, rel.Arch); err == nil {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Message: "Release already exists",
+		})
+		return
+	}
+
+	path := release.Path(h.cfg.Releases.Dir, rel.Version, rel.OS, rel.Arch)
+	if err := h.saveRelease(file, path, rel); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Failed to save release: " + err.Error(),
+		})
+		return
+	}
+
+	if err := h.db.CreateRelease(rel); err != nil {
+		os.Remove(path)
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Message: "Failed to create release",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.APIResponse{
+		Success: true,
+		Data:    rel,
+	})
+}
+
+// saveRelease 写入临时文件并计算摘要，校验通过后重命名到 path
+func (h *Handler) saveRelease(file *multipart.FileHeader, path string, rel *models.AgentRelease) error {
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0750); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), src)
+	if err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	rel.Size = size
+	rel.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	if h.cfg.Releases.PublicKey != "" {
+		data, err := os.ReadFile(tmp.Name())
+		if err != nil {
+			return err
+		}
+		if err := release.Verify(h.cfg.Releases.PublicKey, data, rel.Signature); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// 删除Agent发布文件
+func (h *Handler) DeleteRelease(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid release id",
+		})
+		return
+	}
+
+	rel, err := h.db.GetReleaseByID(id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "Release not found",
+		})
+		return
+	}
+	if err == nil {
+		_, err = h.db.DeleteRelease(id)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to delete release",
+		})
+		return
+	}
+
+	path := release.Path(h.cfg.Releases.Dir, rel.Version, rel.OS, rel.Arch)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		log.Printf("Failed to remove release file %s: %v", path, err)
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Release deleted",
+	})
+}
+
+// agentRelease 按路径中的版本和查询参数中的 os、arch 查找发布文件，出错时已写入响应
+func (h *Handler) agentRelease(c *gin.Context) (*models.AgentRelease, bool) {
+	if _, _, ok := h.findAgentNode(c); !ok {
+		return nil, false
+	}
+
+	rel, err := h.db.GetRelease(c.Param("version"), c.Query("os"), c.Query("arch"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "Release not found",
+		})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to get release",
+		})
+		return nil, false
+	}
+	return rel, true
+}
+
+// Agent获取发布文件信息：大小、摘要和签名
+func (h *Handler) GetAgentRelease(c *gin.Context) {
+	rel, ok := h.agentRelease(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    rel,
+	})
+}
+
+// Agent下载发布文件
+func (h *Handler) DownloadAgentRelease(c *gin.Context) {
+	rel, ok := h.agentRelease(c)
+	if !ok {
+		return
+	}
+
+	c.File(release.Path(h.cfg.Releases.Dir, rel.Version, rel.OS, rel.Arch))
+}
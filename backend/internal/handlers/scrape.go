@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"miniPanel/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 获取拉取目标及最近一次拉取结果
+func (h *Handler) GetScrapeTargets(c *gin.Context) {
+	targets, err := h.db.GetScrapeTargets()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to get scrape targets",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    targets,
+	})
+}
+
+// 添加拉取目标
+func (h *Handler) CreateScrapeTarget(c *gin.Context) {
+	var req models.CreateScrapeTargetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid request format",
+		})
+		return
+	}
+
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid url, expected http(s)://host:port/metrics",
+		})
+		return
+	}
+
+	target := &models.ScrapeTarget{Name: req.Name, URL: req.URL}
+	if err := h.db.CreateScrapeTarget(target); err != nil {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Message: "Failed to create scrape target, url may already exist",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    target,
+	})
+}
+
+// 删除拉取目标，已拉取的节点和历史数据保留
+func (h *Handler) DeleteScrapeTarget(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid target id",
+		})
+		return
+	}
+
+	deleted, err := h.db.DeleteScrapeTarget(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to delete scrape target",
+		})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "Scrape target not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Scrape target deleted",
+	})
+}
@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"miniPanel/internal/models"
+	"miniPanel/internal/tlsutil"
+	"miniPanel/internal/wire"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// streamHub 通过 gRPC 流连接的Agent，每个节点保留最新的一个连接
+type streamHub struct {
+	mu       sync.Mutex
+	sessions map[int]*streamSession
+}
+
+func newStreamHub() *streamHub {
+	return &streamHub{sessions: map[int]*streamSession{}}
+}
+
+// add 登记新连接，同一节点的旧连接（通常已断开但尚未检测到）不再接收命令
+func (hub *streamHub) add(s *streamSession) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	hub.sessions[s.nodeID] = s
+}
+
+// remove 移除连接，返回节点是否已没有连接
+func (hub *streamHub) remove(s *streamSession) bool {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	current, ok := hub.sessions[s.nodeID]
+	if !ok || current == s {
+		delete(hub.sessions, s.nodeID)
+		return true
+	}
+	return false
+}
+
+func (hub *streamHub) get(nodeID int) *streamSession {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	return hub.sessions[nodeID]
+}
+
+func (hub *streamHub) list() []*streamSession {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	sessions := make([]*streamSession, 0, len(hub.sessions))
+	for _, s := range hub.sessions {
+		sessions = append(sessions, s)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].nodeID < sessions[j].nodeID })
+	return sessions
+}
+
+// streamSession 一个Agent连接。发给Agent的消息都经过 out，由单独的协程写入流
+type streamSession struct {
+	nodeID       int
+	nodeName     string
+	remoteAddr   string
+	agentVersion string
+	connectedAt  time.Time
+	reports      atomic.Uint64
+
+	out  chan []byte
+	done chan struct{} // 连接结束时关闭
+
+	mu            sync.Mutex
+	configVersion string // 最近告知Agent的集中配置版本
+}
+
+// send 发送回复，连接结束时放弃
+func (s *streamSession) send(msg []byte) bool {
+	select {
+	case s.out <- msg:
+		return true
+	case <-s.done:
+		return false
+	}
+}
+
+// command 下发命令，发送队列已满时不等待，返回是否已放入队列
+func (s *streamSession) command(cmd, configVersion string) bool {
+	select {
+	case s.out <- wire.EncodeCommand(cmd, configVersion):
+		return true
+	default:
+		return false
+	}
+}
+
+// setConfigVersion 记录告知Agent的配置版本，返回版本是否变化
+func (s *streamSession) setConfigVersion(version string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := s.configVersion != version
+	s.configVersion = version
+	return changed
+}
+
+func (s *streamSession) info() models.StreamConnection {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return models.StreamConnection{
+		NodeID:        s.nodeID,
+		NodeName:      s.nodeName,
+		RemoteAddr:    s.remoteAddr,
+		AgentVersion:  s.agentVersion,
+		ConnectedAt:   s.connectedAt.Format(time.RFC3339),
+		Reports:       s.reports.Load(),
+		ConfigVersion: s.configVersion,
+	}
+}
+
+// StreamServiceDesc Agent gRPC 流服务的定义，注册时 ss 传入 Handler
+func (h *Handler) StreamServiceDesc() *grpc.ServiceDesc {
+	return &grpc.ServiceDesc{
+		ServiceName: wire.StreamService,
+		HandlerType: (*any)(nil),
+		Streams: []grpc.StreamDesc{{
+			StreamName: wire.StreamMethod,
+			Handler: func(_ any, stream grpc.ServerStream) error {
+				return h.serveStream(stream)
+			},
+			ServerStreams: true,
+			ClientStreams: true,
+		}},
+	}
+}
+
+// serveStream 处理一个Agent连接：确定节点身份，逐条处理上报数据并回复，
+// 连接断开时立即把节点标记为离线
+func (h *Handler) serveStream(stream grpc.ServerStream) error {
+	var identity, clientIP, remoteAddr string
+	if p, ok := peer.FromContext(stream.Context()); ok {
+		remoteAddr = p.Addr.String()
+		clientIP, _, _ = net.SplitHostPort(remoteAddr)
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			identity = tlsutil.PeerIdentity(&info.State)
+		}
+	}
+	if identity == "" && h.cfg.Server.TLS.RequireAgentCert {
+		return status.Error(codes.Unauthenticated, "client certificate required")
+	}
+
+	var data []byte
+	if err := stream.RecvMsg(&data); err != nil {
+		return err
+	}
+	msg, err := wire.DecodeAgentMessage(data)
+	if err != nil || msg.Hello == nil {
+		return status.Error(codes.InvalidArgument, "first message must be hello")
+	}
+
+	// 与 HTTP 上报相同：证书身份优先，其次是 Agent 提供的名称，最后是客户端IP
+	nodeName := msg.Hello.NodeName
+	if nodeName == "" {
+		nodeName = clientIP
+	}
+	node, err := h.upsertAgentNode(identity, nodeName, clientIP)
+	if err != nil {
+		return status.Error(codes.Internal, "failed to update node info")
+	}
+	if node.Lifecycle == models.NodeDecommissioned {
+		return status.Error(codes.PermissionDenied, "node has been decommissioned")
+	}
+
+	s := &streamSession{
+		nodeID:       node.ID,
+		nodeName:     node.Name,
+		remoteAddr:   remoteAddr,
+		agentVersion: msg.Hello.AgentVersion,
+		connectedAt:  time.Now(),
+		out:          make(chan []byte, 16),
+		done:         make(chan struct{}),
+	}
+	if result, err := h.effectiveConfig(node.ID); err != nil {
+		log.Printf("Failed to get config for node %s: %v", node.Name, err)
+	} else {
+		s.configVersion = result.Version
+	}
+
+	// 写入流的协程在处理函数返回前退出
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case b := <-s.out:
+				if err := stream.SendMsg(b); err != nil {
+					return
+				}
+			case <-s.done:
+				return
+			}
+		}
+	}()
+
+	h.streams.add(s)
+	log.Printf("Agent %s connected via gRPC stream from %s", node.Name, remoteAddr)
+	defer func() {
+		close(s.done)
+		wg.Wait()
+		if h.streams.remove(s) {
+			if _, err := h.db.MarkNodeOffline(s.nodeID); err != nil {
+				log.Printf("Failed to mark node %s offline: %v", s.nodeName, err)
+			}
+		}
+		log.Printf("Agent %s disconnected from gRPC stream", s.nodeName)
+	}()
+
+	s.send(wire.EncodeWelcome(node.ID, node.Name, s.configVersion))
+
+	for {
+		var data []byte
+		if err := stream.RecvMsg(&data); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		msg, err := wire.DecodeAgentMessage(data)
+		if err != nil {
+			return status.Error(codes.InvalidArgument, "invalid message: "+err.Error())
+		}
+		if msg.Report == nil {
+			continue
+		}
+
+		result := h.acceptReport(identity, nodeName, clientIP, msg.Report)
+		ack := wire.Ack{Seq: msg.Seq, Status: result.status, Message: result.message}
+		switch result.status {
+		case http.StatusOK:
+			s.reports.Add(1)
+			s.setConfigVersion(result.ack.ConfigVersion)
+			ack.ConfigVersion = result.ack.ConfigVersion
+		case http.StatusTooManyRequests:
+			ack.RetryAfter = h.cfg.Ingest.RetryAfter
+		}
+		if !s.send(wire.EncodeAck(ack)) {
+			return nil
+		}
+	}
+}
+
+// notifyConfigChanged 集中配置或节点标签变化后，通知通过 gRPC 流连接且配置版本变化的Agent。
+// 其他Agent在下次上报时从回复中得知新版本
+func (h *Handler) notifyConfigChanged() {
+	for _, s := range h.streams.list() {
+		result, err := h.effectiveConfig(s.nodeID)
+		if err != nil {
+			log.Printf("Failed to get config for node %s: %v", s.nodeName, err)
+			continue
+		}
+		if s.setConfigVersion(result.Version) {
+			s.command(wire.CommandSyncConfig, result.Version)
+		}
+	}
+}
+
+// 获取通过 gRPC 流连接的Agent
+func (h *Handler) GetStreams(c *gin.Context) {
+	sessions := h.streams.list()
+	connections := make([]models.StreamConnection, 0, len(sessions))
+	for _, s := range sessions {
+		connections = append(connections, s.info())
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    connections,
+	})
+}
+
+// 通过 gRPC 流向节点的Agent下发命令
+func (h *Handler) SendNodeCommand(c *gin.Context) {
+	nodeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid node id",
+		})
+		return
+	}
+
+	var req models.NodeCommandRequest
+	if err := c.ShouldBindJSON(&req); err != nil || !wire.ValidCommand(req.Type) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid command, must be collect_now, send_inventory or sync_config",
+		})
+		return
+	}
+
+	s := h.streams.get(nodeID)
+	if s == nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "Node is not connected via gRPC stream",
+		})
+		return
+	}
+
+	var version string
+	if req.Type == wire.CommandSyncConfig {
+		result, err := h.effectiveConfig(nodeID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Message: "Failed to get node config",
+			})
+			return
+		}
+		version = result.Version
+		s.setConfigVersion(version)
+	}
+
+	if !s.command(req.Type, version) {
+		c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Message: "Agent is busy, retry later",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "Command sent",
+	})
+}
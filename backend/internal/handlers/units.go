@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"miniPanel/internal/alerts"
+	"miniPanel/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// syncUnits 保存Agent上报的 systemd 单元状态。单元进入 failed 状态和被自动重启时记录节点事件，
+// 其他状态变化只记录到单元历史。at 为数据时间，补发的旧数据不覆盖较新的状态
+func (h *Handler) syncUnits(node *models.Node, at time.Time, units []models.UnitStatus) {
+	valid := make([]models.UnitStatus, 0, len(units))
+	for _, u := range units {
+		if u.Name != "" {
+			valid = append(valid, u)
+		}
+	}
+
+	events, err := h.db.UpdateNodeUnits(node.ID, at, valid)
+	if err != nil {
+		log.Printf("Failed to update systemd units for node %s: %v", node.Name, err)
+		return
+	}
+
+	var changes []alerts.Event
+	for _, e := range events {
+		event := models.NodeEvent{NodeID: node.ID}
+		change := alerts.Event{Kind: alerts.KindUnit, Name: e.Name}
+		switch {
+		case e.ActiveState == models.UnitFailed && e.PreviousActiveState != models.UnitFailed:
+			event.Type = models.EventUnitFailed
+			event.Message = fmt.Sprintf("Unit %s failed (%s)", e.Name, e.SubState)
+			change.Condition = alerts.ConditionFailed
+		case e.PreviousActiveState != "" && e.Restarts > e.PreviousRestarts:
+			event.Type = models.EventUnitRestart
+			event.Message = fmt.Sprintf("Unit %s was restarted by systemd (%d restarts)", e.Name, e.Restarts)
+			change.Condition = alerts.ConditionRestarted
+		default:
+			continue
+		}
+		log.Printf("Node %s: %s", node.Name, event.Message)
+		if err := h.db.AddNodeEvent(&event); err != nil {
+			log.Printf("Failed to record event for node %s: %v", node.Name, err)
+		}
+		change.Message = event.Message
+		changes = append(changes, change)
+	}
+	h.raiseAlerts(node, changes)
+}
+
+// 获取节点 systemd 单元的当前状态
+func (h *Handler) GetNodeUnits(c *gin.Context) {
+	id, ok := nodeIDParam(c)
+	if !ok {
+		return
+	}
+
+	units, err := h.db.GetNodeUnits(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to get node units",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    units,
+	})
+}
+
+// 获取节点 systemd 单元的状态变化历史，可按单元名称过滤
+func (h *Handler) GetUnitEvents(c *gin.Context) {
+	id, ok := nodeIDParam(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "Invalid limit parameter",
+		})
+		return
+	}
+
+	events, err := h.db.GetUnitEvents(id, c.Query("name"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to get unit events",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    events,
+	})
+}
+
+// 获取所有节点的 systemd 单元，?state=failed 只返回该 active_state 的单元
+func (h *Handler) GetUnits(c *gin.Context) {
+	units, err := h.db.GetUnits(c.Query("state"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "Failed to get units",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    units,
+	})
+}
@@ -0,0 +1,179 @@
+// Package ingest 异步批量写入Agent上报的监控数据
+package ingest
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"miniPanel/internal/config"
+	"miniPanel/internal/database"
+	"miniPanel/internal/models"
+)
+
+// Writer 把上报数据放入有界队列，由单独的协程按批次在一个事务中写入数据库
+type Writer struct {
+	store         database.Store
+	queue         chan *models.AgentMetrics
+	batchSize     int
+	flushInterval time.Duration
+	done          chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+	stats  Stats
+}
+
+// Stats 写入队列的运行状态
+type Stats struct {
+	QueueDepth      int     `json:"queue_depth"`
+	QueueCapacity   int     `json:"queue_capacity"`
+	Accepted        uint64  `json:"accepted"` // 进入队列的数据条数
+	Rejected        uint64  `json:"rejected"` // 队列已满被拒绝的条数
+	Written         uint64  `json:"written"`  // 成功写入的条数
+	Dropped         uint64  `json:"dropped"`  // 写入失败被丢弃的条数
+	Batches         uint64  `json:"batches"`  // 成功提交的批次数
+	LastBatchSize   int     `json:"last_batch_size"`
+	LastFlushMillis float64 `json:"last_flush_ms"`
+	AvgFlushMillis  float64 `json:"avg_flush_ms"`
+	MaxFlushMillis  float64 `json:"max_flush_ms"`
+	LastFlushAt     string  `json:"last_flush_at,omitempty"`
+	LastError       string  `json:"last_error,omitempty"`
+}
+
+// NewWriter 创建写入器，需要调用 Run 开始写入
+func NewWriter(store database.Store, cfg config.IngestConfig) *Writer {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1
+	}
+	if cfg.FlushIntervalMs <= 0 {
+		cfg.FlushIntervalMs = 1000
+	}
+
+	return &Writer{
+		store:         store,
+		queue:         make(chan *models.AgentMetrics, cfg.QueueSize),
+		batchSize:     cfg.BatchSize,
+		flushInterval: time.Duration(cfg.FlushIntervalMs) * time.Millisecond,
+		done:          make(chan struct{}),
+		stats:         Stats{QueueCapacity: cfg.QueueSize},
+	}
+}
+
+// Enqueue 把数据放入队列，队列已满或已关闭时立即返回 false
+func (w *Writer) Enqueue(metrics *models.AgentMetrics) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		w.stats.Rejected++
+		return false
+	}
+	select {
+	case w.queue <- metrics:
+		w.stats.Accepted++
+		return true
+	default:
+		w.stats.Rejected++
+		return false
+	}
+}
+
+// Run 持续从队列读取数据，达到批次大小或刷新间隔时写入，直到 Close 后队列被清空
+func (w *Writer) Run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*models.AgentMetrics, 0, w.batchSize)
+	for {
+		select {
+		case metrics, ok := <-w.queue:
+			if !ok {
+				w.flush(batch)
+				return
+			}
+			batch = append(batch, metrics)
+			if len(batch) >= w.batchSize {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+// Close 停止接收数据，等待队列中剩余的数据写入完成
+func (w *Writer) Close() {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	<-w.done
+}
+
+// flush 在一个事务中写入一批数据。整批失败时（例如其中一条的节点已被删除）逐条重新写入，
+// 只丢弃仍然失败的数据，不让一条坏数据拖累同批的其他数据
+func (w *Writer) flush(batch []*models.AgentMetrics) {
+	if len(batch) == 0 {
+		return
+	}
+
+	start := time.Now()
+	written, dropped := len(batch), 0
+	var lastErr error
+	if err := w.store.InsertMetricsBatch(batch); err != nil {
+		log.Printf("Failed to write batch of %d samples, retrying one by one: %v", len(batch), err)
+		written = 0
+		for _, metrics := range batch {
+			if err := w.store.InsertMetrics(metrics); err != nil {
+				log.Printf("Dropping sample from node %d: %v", metrics.NodeID, err)
+				dropped++
+				lastErr = err
+				continue
+			}
+			written++
+		}
+	}
+	elapsed := float64(time.Since(start).Microseconds()) / 1000
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.stats.Dropped += uint64(dropped)
+	if lastErr != nil {
+		w.stats.LastError = lastErr.Error()
+	}
+	if written == 0 {
+		return
+	}
+
+	w.stats.Written += uint64(written)
+	w.stats.Batches++
+	w.stats.LastBatchSize = written
+	w.stats.LastFlushMillis = elapsed
+	w.stats.AvgFlushMillis += (elapsed - w.stats.AvgFlushMillis) / float64(w.stats.Batches)
+	w.stats.MaxFlushMillis = max(w.stats.MaxFlushMillis, elapsed)
+	w.stats.LastFlushAt = start.UTC().Format(time.RFC3339)
+}
+
+// Stats 返回当前运行状态
+func (w *Writer) Stats() Stats {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	stats := w.stats
+	stats.QueueDepth = len(w.queue)
+	return stats
+}
@@ -0,0 +1,93 @@
+package ingest
+
+import (
+	"errors"
+	"testing"
+
+	"miniPanel/internal/config"
+	"miniPanel/internal/database"
+	"miniPanel/internal/models"
+)
+
+// fakeStore 只实现写入监控数据，unknown 中的节点写入失败，与外键约束的效果相同
+type fakeStore struct {
+	database.Store
+	unknown map[int]bool
+	down    bool
+	written []*models.AgentMetrics
+}
+
+var errFake = errors.New("insert failed")
+
+func (s *fakeStore) InsertMetrics(metrics *models.AgentMetrics) error {
+	if s.down || s.unknown[metrics.NodeID] {
+		return errFake
+	}
+	s.written = append(s.written, metrics)
+	return nil
+}
+
+func (s *fakeStore) InsertMetricsBatch(batch []*models.AgentMetrics) error {
+	for _, metrics := range batch {
+		if s.down || s.unknown[metrics.NodeID] {
+			return errFake
+		}
+	}
+	s.written = append(s.written, batch...)
+	return nil
+}
+
+func TestFlush(t *testing.T) {
+	tests := []struct {
+		name        string
+		nodes       []int
+		unknown     map[int]bool
+		down        bool
+		wantWritten uint64
+		wantDropped uint64
+		wantBatches uint64
+	}{
+		{"all valid", []int{1, 2, 3}, nil, false, 3, 0, 1},
+		{"one unknown node", []int{1, 2, 3}, map[int]bool{2: true}, false, 2, 1, 1},
+		{"all unknown", []int{4, 4}, map[int]bool{4: true}, false, 0, 2, 0},
+		{"database down", []int{1, 2}, nil, true, 0, 2, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{unknown: tt.unknown, down: tt.down}
+			w := NewWriter(store, config.IngestConfig{QueueSize: 10, BatchSize: 10})
+
+			var batch []*models.AgentMetrics
+			for _, id := range tt.nodes {
+				batch = append(batch, &models.AgentMetrics{NodeID: id})
+			}
+			w.flush(batch)
+
+			stats := w.Stats()
+			if stats.Written != tt.wantWritten || stats.Dropped != tt.wantDropped || stats.Batches != tt.wantBatches {
+				t.Errorf("written/dropped/batches = %d/%d/%d, want %d/%d/%d",
+					stats.Written, stats.Dropped, stats.Batches, tt.wantWritten, tt.wantDropped, tt.wantBatches)
+			}
+			if uint64(len(store.written)) != tt.wantWritten {
+				t.Errorf("store has %d samples, want %d", len(store.written), tt.wantWritten)
+			}
+			if (tt.wantDropped > 0) != (stats.LastError != "") {
+				t.Errorf("last_error = %q with %d dropped", stats.LastError, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestEnqueueFull(t *testing.T) {
+	w := NewWriter(&fakeStore{}, config.IngestConfig{QueueSize: 1, BatchSize: 10})
+	if !w.Enqueue(&models.AgentMetrics{}) {
+		t.Fatal("first Enqueue rejected")
+	}
+	if w.Enqueue(&models.AgentMetrics{}) {
+		t.Fatal("Enqueue on full queue accepted")
+	}
+	stats := w.Stats()
+	if stats.Accepted != 1 || stats.Rejected != 1 || stats.QueueDepth != 1 {
+		t.Errorf("accepted/rejected/depth = %d/%d/%d, want 1/1/1", stats.Accepted, stats.Rejected, stats.QueueDepth)
+	}
+}
@@ -0,0 +1,131 @@
+package labels
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	keyPattern   = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9_.\-/]{0,62})$`)
+	valuePattern = regexp.MustCompile(`^[A-Za-z0-9_.\-]{0,63}$`)
+)
+
+// Validate 校验标签键值格式
+func Validate(set map[string]string) error {
+	for key, value := range set {
+		if !keyPattern.MatchString(key) {
+			return fmt.Errorf("invalid label key: %q", key)
+		}
+		if !valuePattern.MatchString(value) {
+			return fmt.Errorf("invalid value for label %s: %q", key, value)
+		}
+	}
+	return nil
+}
+
+// 选择器操作符
+const (
+	opEquals    = "="
+	opNotEquals = "!="
+	opExists    = "exists"
+	opNotExists = "!exists"
+)
+
+type requirement struct {
+	key   string
+	op    string
+	value string
+}
+
+// Selector 标签选择器，多个条件之间为“与”关系
+type Selector struct {
+	requirements []requirement
+}
+
+// Parse 解析选择器表达式，例如 "env=prod,rack!=a3,gpu,!legacy"。
+// 支持 key=value、key==value、key!=value、key（存在）和 !key（不存在），空表达式匹配所有节点
+func Parse(expr string) (*Selector, error) {
+	sel := &Selector{}
+	for _, part := range strings.Split(expr, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		var req requirement
+		switch {
+		case strings.Contains(part, "!="):
+			kv := strings.SplitN(part, "!=", 2)
+			req = requirement{key: strings.TrimSpace(kv[0]), op: opNotEquals, value: strings.TrimSpace(kv[1])}
+		case strings.Contains(part, "=="):
+			kv := strings.SplitN(part, "==", 2)
+			req = requirement{key: strings.TrimSpace(kv[0]), op: opEquals, value: strings.TrimSpace(kv[1])}
+		case strings.Contains(part, "="):
+			kv := strings.SplitN(part, "=", 2)
+			req = requirement{key: strings.TrimSpace(kv[0]), op: opEquals, value: strings.TrimSpace(kv[1])}
+		case strings.HasPrefix(part, "!"):
+			req = requirement{key: strings.TrimSpace(part[1:]), op: opNotExists}
+		default:
+			req = requirement{key: part, op: opExists}
+		}
+
+		if !keyPattern.MatchString(req.key) {
+			return nil, fmt.Errorf("invalid label key in selector: %q", req.key)
+		}
+		if !valuePattern.MatchString(req.value) {
+			return nil, fmt.Errorf("invalid label value in selector: %q", req.value)
+		}
+		sel.requirements = append(sel.requirements, req)
+	}
+	return sel, nil
+}
+
+// Matches 判断标签集合是否满足选择器
+func (s *Selector) Matches(set map[string]string) bool {
+	for _, req := range s.requirements {
+		value, ok := set[req.key]
+		switch req.op {
+		case opEquals:
+			if !ok || value != req.value {
+				return false
+			}
+		case opNotEquals:
+			if ok && value == req.value {
+				return false
+			}
+		case opExists:
+			if !ok {
+				return false
+			}
+		case opNotExists:
+			if ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Empty 选择器是否没有任何条件
+func (s *Selector) Empty() bool {
+	return len(s.requirements) == 0
+}
+
+// String 返回规范化的选择器表达式
+func (s *Selector) String() string {
+	parts := make([]string, 0, len(s.requirements))
+	for _, req := range s.requirements {
+		switch req.op {
+		case opEquals, opNotEquals:
+			parts = append(parts, req.key+req.op+req.value)
+		case opExists:
+			parts = append(parts, req.key)
+		case opNotExists:
+			parts = append(parts, "!"+req.key)
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,98 @@
+package labels
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		set     map[string]string
+		wantErr bool
+	}{
+		{nil, false},
+		{map[string]string{"env": "prod", "team/owner": "ops", "k8s.io-zone": "a_1"}, false},
+		{map[string]string{"gpu": ""}, false},
+		{map[string]string{"": "x"}, true},
+		{map[string]string{"-env": "prod"}, true},
+		{map[string]string{"env": "prod west"}, true},
+		{map[string]string{"env": "a/b"}, true},
+		{map[string]string{strings.Repeat("k", 63): "v"}, false},
+		{map[string]string{strings.Repeat("k", 64): "v"}, true},
+		{map[string]string{"k": strings.Repeat("v", 64)}, true},
+	}
+	for _, tt := range tests {
+		if err := Validate(tt.set); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%v) = %v, wantErr %v", tt.set, err, tt.wantErr)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    string // 规范化后的表达式
+		wantErr bool
+	}{
+		{"", "", false},
+		{" , ", "", false},
+		{"env=prod", "env=prod", false},
+		{"env==prod", "env=prod", false},
+		{" rack != a3 , env = prod ", "env=prod,rack!=a3", false},
+		{"gpu,!legacy", "!legacy,gpu", false},
+		{"env=", "env=", false},
+		{"=prod", "", true},
+		{"env=prod west", "", true},
+		{"!", "", true},
+		{"bad key", "", true},
+	}
+	for _, tt := range tests {
+		sel, err := Parse(tt.expr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Parse(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			continue
+		}
+		if err == nil && sel.String() != tt.want {
+			t.Errorf("Parse(%q) = %q, want %q", tt.expr, sel.String(), tt.want)
+		}
+	}
+}
+
+func TestMatches(t *testing.T) {
+	set := map[string]string{"env": "prod", "rack": "a3", "gpu": ""}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"", true},
+		{"env=prod", true},
+		{"env=staging", false},
+		{"env!=staging", true},
+		{"env!=prod", false},
+		{"zone!=a", true},
+		{"gpu", true},
+		{"gpu=", true},
+		{"legacy", false},
+		{"!legacy", true},
+		{"!gpu", false},
+		{"env=prod,rack=a3,gpu,!legacy", true},
+		{"env=prod,rack=b1", false},
+	}
+	for _, tt := range tests {
+		sel, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.expr, err)
+		}
+		if got := sel.Matches(set); got != tt.want {
+			t.Errorf("%q matches %v = %v, want %v", tt.expr, set, got, tt.want)
+		}
+	}
+
+	empty, _ := Parse("")
+	if !empty.Empty() || !empty.Matches(nil) {
+		t.Error("empty selector must match everything")
+	}
+	if sel, _ := Parse("env=prod"); sel.Matches(nil) {
+		t.Error("env=prod matches a node without labels")
+	}
+}
@@ -0,0 +1,39 @@
+// Package liveness 定时把长时间没有数据的节点标记为离线
+package liveness
+
+import (
+	"log"
+	"time"
+
+	"miniPanel/internal/config"
+	"miniPanel/internal/database"
+)
+
+// Run 按配置的间隔检查节点，直到 stop 关闭；OfflineAfter 为 0 时不检查
+func Run(store database.Store, cfg config.LivenessConfig, stop <-chan struct{}) {
+	if cfg.OfflineAfter <= 0 || cfg.CheckInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(cfg.CheckInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			n, err := sweep(store, time.Duration(cfg.OfflineAfter)*time.Second, time.Now())
+			if err != nil {
+				log.Printf("Failed to mark stale nodes offline: %v", err)
+			} else if n > 0 {
+				log.Printf("Marked %d nodes offline", n)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// sweep 把在 now 之前 offlineAfter 内没有数据的在线节点标记为离线，返回标记的节点数
+func sweep(store database.Store, offlineAfter time.Duration, now time.Time) (int64, error) {
+	return store.MarkStaleNodesOffline(now.Add(-offlineAfter))
+}
@@ -0,0 +1,66 @@
+package liveness
+
+import (
+	"testing"
+	"time"
+
+	"miniPanel/internal/database"
+	"miniPanel/internal/database/dbtest"
+)
+
+func TestSweepSQLite(t *testing.T) {
+	testSweep(t, dbtest.OpenSQLite(t))
+}
+
+// 会话时区在 UTC 以西和以东时，刚上报的节点都不能被误判为离线，过期的节点都要被标记
+func TestSweepPostgresNonUTC(t *testing.T) {
+	for _, tz := range []string{"America/New_York", "Asia/Shanghai"} {
+		t.Run(tz, func(t *testing.T) {
+			testSweep(t, dbtest.OpenPostgres(t, map[string]string{"timezone": tz}))
+		})
+	}
+}
+
+func testSweep(t *testing.T, store database.Store) {
+	const ip = "10.98.0.1"
+	if err := store.CreateOrUpdateNode("liveness-node", ip); err != nil {
+		t.Fatalf("CreateOrUpdateNode: %v", err)
+	}
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		wantMarked int64
+		wantStatus string
+	}{
+		{"just reported", now, 0, "online"},
+		{"within offline_after", now.Add(50 * time.Second), 0, "online"},
+		{"past offline_after", now.Add(2 * time.Minute), 1, "offline"},
+		{"already offline", now.Add(3 * time.Minute), 0, "offline"},
+	}
+	for _, tt := range tests {
+		n, err := sweep(store, time.Minute, tt.now)
+		if err != nil {
+			t.Fatalf("%s: sweep: %v", tt.name, err)
+		}
+		if n != tt.wantMarked {
+			t.Errorf("%s: marked %d nodes, want %d", tt.name, n, tt.wantMarked)
+		}
+		node, err := store.GetNodeByIP(ip)
+		if err != nil {
+			t.Fatalf("%s: GetNodeByIP: %v", tt.name, err)
+		}
+		if node.Status != tt.wantStatus {
+			t.Errorf("%s: status = %q, want %q", tt.name, node.Status, tt.wantStatus)
+		}
+	}
+
+	// 重新上报后恢复在线
+	if err := store.CreateOrUpdateNode("liveness-node", ip); err != nil {
+		t.Fatalf("CreateOrUpdateNode: %v", err)
+	}
+	if n, err := sweep(store, time.Minute, time.Now()); err != nil || n != 0 {
+		t.Errorf("sweep after report = %d, %v; want 0", n, err)
+	}
+}
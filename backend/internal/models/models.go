@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
@@ -11,25 +12,230 @@ type User struct {
 	Password string `json:"-" db:"password"` // 不在JSON中显示密码
 }
 
+// 节点生命周期状态
+const (
+	NodeActive         = "active"         // 正常节点
+	NodeArchived       = "archived"       // 已归档：默认列表中隐藏，重新上报后恢复
+	NodeDecommissioned = "decommissioned" // 已退役：拒绝上报，保留历史数据
+)
+
 // Node 节点表
 type Node struct {
-	ID       int    `json:"id" db:"id"`
-	Name     string `json:"name" db:"name"`
-	IP       string `json:"ip" db:"ip"`
-	Status   string `json:"status" db:"status"`
-	LastSeen string `json:"last_seen" db:"last_seen"`
+	ID        int    `json:"id" db:"id"`
+	Name      string `json:"name" db:"name"`             // 显示名称，可由管理员修改
+	AgentName string `json:"agent_name" db:"agent_name"` // Agent上报的名称或证书身份
+	IP        string `json:"ip" db:"ip"`
+	Status    string `json:"status" db:"status"`
+	Lifecycle string `json:"lifecycle" db:"lifecycle"`
+	LastSeen  string `json:"last_seen" db:"last_seen"`
+
+	// ClockSkewMs Agent时钟与服务器的偏差（毫秒），正数表示Agent时钟较快；Agent未携带发送时间时为 0
+	ClockSkewMs int64 `json:"clock_skew_ms" db:"clock_skew_ms"`
+
+	Labels map[string]string `json:"labels"`
+
+	// Inventory 主机信息，只在查询单个节点时返回
+	Inventory *NodeInventory `json:"inventory,omitempty"`
+}
+
+// HostInfo Agent上报的主机信息
+type HostInfo struct {
+	Hostname        string `json:"hostname" db:"hostname"`
+	OS              string `json:"os" db:"os"`
+	Platform        string `json:"platform" db:"platform"`
+	PlatformVersion string `json:"platform_version" db:"platform_version"`
+	KernelVersion   string `json:"kernel_version" db:"kernel_version"`
+	Arch            string `json:"arch" db:"arch"`
+	CPUModel        string `json:"cpu_model" db:"cpu_model"`
+	CPUCores        int    `json:"cpu_cores" db:"cpu_cores"`
+	MemoryTotal     uint64 `json:"memory_total" db:"memory_total"`
+	BootTime        uint64 `json:"boot_time" db:"boot_time"` // Unix时间戳（秒）
+	AgentVersion    string `json:"agent_version" db:"agent_version"`
+}
+
+// NodeInventory 节点主机信息表
+type NodeInventory struct {
+	NodeID int `json:"node_id" db:"node_id"`
+	HostInfo
+	Uptime    uint64 `json:"uptime"` // 根据启动时间计算，单位秒
+	UpdatedAt string `json:"updated_at" db:"updated_at"`
+}
+
+// 节点事件类型
+const (
+	EventReboot       = "reboot"        // 启动时间变化
+	EventAgentUpdated = "agent_updated" // Agent版本变化
+	EventOSUpdated    = "os_updated"    // 系统或内核版本变化
+	EventClockSkew    = "clock_skew"    // Agent时钟偏差超过或恢复到允许范围内
+	EventCheckFailed  = "check_failed"  // 服务检查失败
+	EventCheckOK      = "check_ok"      // 服务检查恢复正常
+	EventUnitFailed   = "unit_failed"   // systemd 单元进入 failed 状态
+	EventUnitRestart  = "unit_restart"  // systemd 单元被自动重启
+	EventAlert        = "alert"         // 告警规则触发
+)
+
+// NodeEvent 节点事件表
+type NodeEvent struct {
+	ID        int    `json:"id" db:"id"`
+	NodeID    int    `json:"node_id" db:"node_id"`
+	Type      string `json:"type" db:"type"`
+	Message   string `json:"message" db:"message"`
+	CreatedAt string `json:"created_at" db:"created_at"`
+}
+
+// NodeGroup 节点分组，由标签选择器定义
+type NodeGroup struct {
+	ID        int    `json:"id" db:"id"`
+	Name      string `json:"name" db:"name"`
+	Selector  string `json:"selector" db:"selector"`
+	CreatedAt string `json:"created_at" db:"created_at"`
+}
+
+// ScrapeTarget 拉取目标表，服务器定时从这些Agent拉取数据
+type ScrapeTarget struct {
+	ID         int    `json:"id" db:"id"`
+	Name       string `json:"name" db:"name"` // 节点名称，为空时使用Agent返回的名称
+	URL        string `json:"url" db:"url"`
+	CreatedAt  string `json:"created_at" db:"created_at"`
+	LastScrape string `json:"last_scrape,omitempty" db:"last_scrape"`
+	LastError  string `json:"last_error,omitempty" db:"last_error"`
+}
+
+// ConfigProfile 集中管理的Agent配置，可以指定节点、分组或标签选择器，
+// 都不指定时适用于全部节点
+type ConfigProfile struct {
+	ID        int                `json:"id" db:"id"`
+	Name      string             `json:"name" db:"name"`
+	NodeID    int                `json:"node_id,omitempty" db:"node_id"`
+	Group     string             `json:"group,omitempty" db:"group_name"`
+	Selector  string             `json:"selector,omitempty" db:"selector"`
+	Priority  int                `json:"priority" db:"priority"` // 多个配置适用时，数值大的覆盖数值小的；指定节点的配置最后应用
+	Config    ManagedAgentConfig `json:"config" db:"config"`
+	CreatedAt string             `json:"created_at" db:"created_at"`
+	UpdatedAt string             `json:"updated_at" db:"updated_at"`
+}
+
+// ManagedAgentConfig 服务器下发的Agent配置项，结构与Agent配置文件相同，未设置的项不覆盖
+type ManagedAgentConfig struct {
+	Agent     *ManagedAgentSection     `json:"agent,omitempty"`
+	Collector *ManagedCollectorSection `json:"collector,omitempty"`
+	Update    *ManagedUpdateSection    `json:"update,omitempty"`
+
+	// 替换Agent本地的全部服务检查，多个配置都设置时后应用的整体覆盖先应用的
+	Checks *[]ManagedCheck `json:"checks,omitempty"`
+}
+
+// ManagedAgentSection 可下发的 agent 配置项
+type ManagedAgentSection struct {
+	Interval  *int `json:"interval,omitempty"`
+	SpoolSize *int `json:"spool_size,omitempty"`
+}
+
+// ManagedCollectorSection 可下发的 collector 配置项
+type ManagedCollectorSection struct {
+	CPU    *bool `json:"cpu,omitempty"`
+	Memory *bool `json:"memory,omitempty"`
+	Temp   *bool `json:"temp,omitempty"`
+}
+
+// ManagedUpdateSection 可下发的 update 配置项
+type ManagedUpdateSection struct {
+	Version *string `json:"version,omitempty"` // Agent升级的目标版本
+}
+
+// ManagedCheck 可下发的服务检查，字段与Agent配置文件中的 checks 相同
+type ManagedCheck struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"` // tcp、http、process 或 file
+	Target  string `json:"target"`
+	Timeout int    `json:"timeout,omitempty"` // 超时时间（秒），未设置时由Agent使用默认值
+
+	ExpectStatus       int    `json:"expect_status,omitempty"`
+	ExpectBody         string `json:"expect_body,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
+
+	MaxAge  int   `json:"max_age,omitempty"`
+	MinSize int64 `json:"min_size,omitempty"`
+	MaxSize int64 `json:"max_size,omitempty"`
+}
+
+// AgentRelease Agent发布文件，按版本、系统和架构区分
+type AgentRelease struct {
+	ID        int    `json:"id" db:"id"`
+	Version   string `json:"version" db:"version"`
+	OS        string `json:"os" db:"os"`
+	Arch      string `json:"arch" db:"arch"`
+	SHA256    string `json:"sha256" db:"sha256"`       // 十六进制
+	Signature string `json:"signature" db:"signature"` // 对文件内容的 ed25519 签名（base64）
+	Size      int64  `json:"size" db:"size"`
+	CreatedAt string `json:"created_at" db:"created_at"`
 }
 
 // SystemMetrics 系统监控数据表
 type SystemMetrics struct {
-	ID          int     `json:"id" db:"id"`
-	NodeID      int     `json:"node_id" db:"node_id"`
-	CPUPercent  float64 `json:"cpu_percent" db:"cpu_percent"`
-	MemoryTotal uint64  `json:"memory_total" db:"memory_total"`
-	MemoryUsed  uint64  `json:"memory_used" db:"memory_used"`
+	ID            int     `json:"id" db:"id"`
+	NodeID        int     `json:"node_id" db:"node_id"`
+	CPUPercent    float64 `json:"cpu_percent" db:"cpu_percent"`
+	MemoryTotal   uint64  `json:"memory_total" db:"memory_total"`
+	MemoryUsed    uint64  `json:"memory_used" db:"memory_used"`
 	MemoryPercent float64 `json:"memory_percent" db:"memory_percent"`
-	CPUTemp     float64 `json:"cpu_temp" db:"cpu_temp"`
-	Timestamp   string  `json:"timestamp" db:"timestamp"`
+	CPUTemp       float64 `json:"cpu_temp" db:"cpu_temp"`
+	Timestamp     string  `json:"timestamp" db:"timestamp"`
+}
+
+// MetricsStats 一段时间内监控数据的统计值
+type MetricsStats struct {
+	Samples   int     `json:"samples"`
+	CPUMin    float64 `json:"cpu_min"`
+	CPUAvg    float64 `json:"cpu_avg"`
+	CPUMax    float64 `json:"cpu_max"`
+	MemoryMin float64 `json:"memory_min"`
+	MemoryAvg float64 `json:"memory_avg"`
+	MemoryMax float64 `json:"memory_max"`
+	TempMin   float64 `json:"temp_min"`
+	TempAvg   float64 `json:"temp_avg"`
+	TempMax   float64 `json:"temp_max"`
+}
+
+// NodeOverview 概览中的单个节点
+type NodeOverview struct {
+	Node
+	Latest  *SystemMetrics `json:"latest"`   // 没有数据时为 null
+	Stats1h *MetricsStats  `json:"stats_1h"` // 最近1小时统计，没有数据时为 null
+}
+
+// NodeValue 某项指标最高的节点
+type NodeValue struct {
+	NodeID int     `json:"node_id"`
+	Name   string  `json:"name"`
+	Value  float64 `json:"value"`
+}
+
+// FleetOverview 全部节点概览
+type FleetOverview struct {
+	TotalNodes    int            `json:"total_nodes"`
+	OnlineNodes   int            `json:"online_nodes"`
+	OfflineNodes  int            `json:"offline_nodes"`
+	AvgCPU        float64        `json:"avg_cpu"`
+	AvgMemory     float64        `json:"avg_memory"`
+	HottestNode   *NodeValue     `json:"hottest_node"`
+	HighestCPU    *NodeValue     `json:"highest_cpu"`
+	HighestMemory *NodeValue     `json:"highest_memory"`
+	Nodes         []NodeOverview `json:"nodes"`
+	GeneratedAt   string         `json:"generated_at"`
+}
+
+// APIKey API密钥表，明文密钥只在创建时返回一次
+type APIKey struct {
+	ID        int      `json:"id" db:"id"`
+	UserID    int      `json:"user_id" db:"user_id"`
+	Name      string   `json:"name" db:"name"`
+	Prefix    string   `json:"prefix" db:"prefix"`
+	KeyHash   string   `json:"-" db:"key_hash"` // 只保存SHA-256摘要
+	Scopes    []string `json:"scopes" db:"scopes"`
+	CreatedAt string   `json:"created_at" db:"created_at"`
+	LastUsed  string   `json:"last_used,omitempty" db:"last_used"`
+	ExpiresAt string   `json:"expires_at,omitempty" db:"expires_at"`
 }
 
 // LoginRequest 登录请求
@@ -44,12 +250,62 @@ type LoginResponse struct {
 	User  User   `json:"user"`
 }
 
+// CreateAPIKeyRequest 创建API密钥请求
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	Scopes        []string `json:"scopes" binding:"required"`
+	ExpiresInDays int      `json:"expires_in_days"` // 0 表示永不过期
+}
+
+// CreateAPIKeyResponse 创建API密钥响应
+type CreateAPIKeyResponse struct {
+	Key    string `json:"key"` // 明文密钥，仅此一次
+	APIKey APIKey `json:"api_key"`
+}
+
+// SetLabelsRequest 设置节点标签请求，会替换管理员设置的全部标签
+type SetLabelsRequest struct {
+	Labels map[string]string `json:"labels"`
+}
+
+// RenameNodeRequest 重命名节点请求
+type RenameNodeRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// MergeNodesRequest 合并节点请求，源节点的历史数据并入目标节点后删除源节点
+type MergeNodesRequest struct {
+	SourceID int `json:"source_id" binding:"required"`
+}
+
+// CreateScrapeTargetRequest 添加拉取目标请求
+type CreateScrapeTargetRequest struct {
+	Name string `json:"name"`
+	URL  string `json:"url" binding:"required"`
+}
+
+// ConfigProfileRequest 创建或修改Agent配置请求，config 中不允许出现未知的配置项
+type ConfigProfileRequest struct {
+	Name     string          `json:"name" binding:"required"`
+	NodeID   int             `json:"node_id"`
+	Group    string          `json:"group"`
+	Selector string          `json:"selector"`
+	Priority int             `json:"priority"`
+	Config   json.RawMessage `json:"config" binding:"required"`
+}
+
+// CreateGroupRequest 创建节点分组请求
+type CreateGroupRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Selector string `json:"selector" binding:"required"`
+}
+
 // MetricsResponse 监控数据响应
 type MetricsResponse struct {
-	Success bool           `json:"success"`
-	Data    SystemMetrics  `json:"data,omitempty"`
+	Success bool            `json:"success"`
+	Data    SystemMetrics   `json:"data,omitempty"`
 	List    []SystemMetrics `json:"list,omitempty"`
-	Message string         `json:"message,omitempty"`
+	Message string          `json:"message,omitempty"`
 }
 
 // NodesResponse 节点列表响应
@@ -66,13 +322,148 @@ type APIResponse struct {
 	Message string      `json:"message,omitempty"`
 }
 
+// AgentCheckResponse Agent检查连接时返回的身份信息
+type AgentCheckResponse struct {
+	NodeName string `json:"node_name"`
+	Identity string `json:"identity"` // certificate, header 或 ip
+	ClientIP string `json:"client_ip"`
+	NodeID   int    `json:"node_id,omitempty"` // 节点已登记时返回
+}
+
+// MetricsAck 接收监控数据的响应内容
+type MetricsAck struct {
+	ConfigVersion string `json:"config_version,omitempty"` // 节点当前的集中配置版本，没有适用的配置时为空
+}
+
+// AgentConfigResponse 节点生效的集中配置
+type AgentConfigResponse struct {
+	Version  string             `json:"version"` // 配置内容的摘要，内容不变时版本不变
+	Config   ManagedAgentConfig `json:"config"`
+	Profiles []string           `json:"profiles"` // 生效的配置名称，按应用顺序
+}
+
+// StreamConnection 通过 gRPC 流连接的Agent
+type StreamConnection struct {
+	NodeID        int    `json:"node_id"`
+	NodeName      string `json:"node_name"`
+	RemoteAddr    string `json:"remote_addr"`
+	AgentVersion  string `json:"agent_version"`
+	ConnectedAt   string `json:"connected_at"`
+	Reports       uint64 `json:"reports"`        // 本次连接收到的上报条数
+	ConfigVersion string `json:"config_version"` // 最近告知Agent的集中配置版本
+}
+
+// NodeCommandRequest 通过 gRPC 流向Agent下发命令
+type NodeCommandRequest struct {
+	Type string `json:"type" binding:"required"` // collect_now、send_inventory 或 sync_config
+}
+
 // AgentMetrics Agent上报的监控数据
 type AgentMetrics struct {
-	NodeID        int     `json:"node_id"`
-	CPUPercent    float64 `json:"cpu_percent"`
-	MemoryTotal   uint64  `json:"memory_total"`
-	MemoryUsed    uint64  `json:"memory_used"`
-	MemoryPercent float64 `json:"memory_percent"`
-	CPUTemp       float64 `json:"cpu_temp"`
+	NodeID          int `json:"node_id"`
+	ProtocolVersion int `json:"protocol_version"` // 上报协议版本，旧版Agent不发送时为 0
+
+	CPUPercent    float64   `json:"cpu_percent"`
+	MemoryTotal   uint64    `json:"memory_total"`
+	MemoryUsed    uint64    `json:"memory_used"`
+	MemoryPercent float64   `json:"memory_percent"`
+	CPUTemp       float64   `json:"cpu_temp"`
 	Timestamp     time.Time `json:"timestamp"`
-}
\ No newline at end of file
+
+	// SentAt Agent发送时的本机时间，用于测量时钟偏差；旧版Agent不发送时为零值
+	SentAt time.Time `json:"sent_at"`
+
+	// Agent配置的标签，为 nil 时（旧版Agent）不修改已有标签
+	Labels map[string]string `json:"labels"`
+
+	// Agent只在启动和主机信息变化时上报
+	Host *HostInfo `json:"host,omitempty"`
+
+	// 服务检查结果，为 nil 时（协议版本 2 之前的Agent）不修改已有检查
+	Checks []CheckResult `json:"checks"`
+
+	// systemd 单元状态，为 nil 时（协议版本 3 之前的Agent）不修改已有单元
+	Units []UnitStatus `json:"units"`
+
+	// Agent没有获取到 systemd 单元状态，此时不修改已有单元
+	UnitsUnavailable bool `json:"units_unavailable"`
+}
+
+// 服务检查状态
+const (
+	CheckOK   = "ok"
+	CheckFail = "fail"
+)
+
+// CheckResult Agent上报的一项服务检查结果
+type CheckResult struct {
+	Name      string  `json:"name"`
+	Type      string  `json:"type"` // tcp、http、process 或 file
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Message   string  `json:"message,omitempty"`
+}
+
+// NodeCheck 节点服务检查的当前状态表
+type NodeCheck struct {
+	NodeID    int     `json:"node_id" db:"node_id"`
+	Name      string  `json:"name" db:"name"`
+	Type      string  `json:"type" db:"type"`
+	Status    string  `json:"status" db:"status"`
+	LatencyMs float64 `json:"latency_ms" db:"latency_ms"`
+	Message   string  `json:"message" db:"message"`
+	Since     string  `json:"since" db:"since"`           // 进入当前状态的时间
+	UpdatedAt string  `json:"updated_at" db:"updated_at"` // 最近一次上报的时间
+}
+
+// CheckEvent 服务检查状态变化记录表，首次上报时 PreviousStatus 为空
+type CheckEvent struct {
+	ID             int    `json:"id" db:"id"`
+	NodeID         int    `json:"node_id" db:"node_id"`
+	Name           string `json:"name" db:"name"`
+	Status         string `json:"status" db:"status"`
+	PreviousStatus string `json:"previous_status" db:"previous_status"`
+	Message        string `json:"message" db:"message"`
+	CreatedAt      string `json:"created_at" db:"created_at"`
+}
+
+// UnitFailed systemd 单元失败时的 active_state
+const UnitFailed = "failed"
+
+// UnitStatus Agent上报的一个 systemd 单元的状态
+type UnitStatus struct {
+	Name        string `json:"name"`
+	LoadState   string `json:"load_state"`   // loaded、not-found 等
+	ActiveState string `json:"active_state"` // active、inactive、failed 等
+	SubState    string `json:"sub_state"`    // running、exited、dead 等
+	Restarts    uint32 `json:"restarts"`     // systemd 自动重启的次数
+	MemoryBytes uint64 `json:"memory_bytes"` // 当前内存占用，未开启内存统计时为 0
+}
+
+// NodeUnit 节点 systemd 单元的当前状态表
+type NodeUnit struct {
+	NodeID      int    `json:"node_id" db:"node_id"`
+	Name        string `json:"name" db:"name"`
+	LoadState   string `json:"load_state" db:"load_state"`
+	ActiveState string `json:"active_state" db:"active_state"`
+	SubState    string `json:"sub_state" db:"sub_state"`
+	Restarts    uint32 `json:"restarts" db:"restarts"`
+	MemoryBytes uint64 `json:"memory_bytes" db:"memory_bytes"`
+	Since       string `json:"since" db:"since"`           // 进入当前 active_state/sub_state 的时间
+	UpdatedAt   string `json:"updated_at" db:"updated_at"` // 最近一次上报的时间
+}
+
+// UnitEvent systemd 单元状态变化记录表，首次上报时 Previous* 为空；
+// 状态未变但重启次数增加时也会记录
+type UnitEvent struct {
+	ID                  int    `json:"id" db:"id"`
+	NodeID              int    `json:"node_id" db:"node_id"`
+	Name                string `json:"name" db:"name"`
+	ActiveState         string `json:"active_state" db:"active_state"`
+	SubState            string `json:"sub_state" db:"sub_state"`
+	PreviousActiveState string `json:"previous_active_state" db:"previous_active_state"`
+	PreviousSubState    string `json:"previous_sub_state" db:"previous_sub_state"`
+	Restarts            uint32 `json:"restarts" db:"restarts"`
+	PreviousRestarts    uint32 `json:"previous_restarts" db:"previous_restarts"`
+	CreatedAt           string `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,60 @@
+// Package release 管理Agent发布文件的签名和存放位置。
+// 签名使用 ed25519 对完整的二进制文件签名，密钥以 base64 编码保存
+package release
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// validName 版本号、系统和架构只允许这些字符，同时用作文件路径
+var validName = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z._+-]*$`)
+
+// ValidName 检查版本号、系统或架构名称
+func ValidName(s string) bool {
+	return validName.MatchString(s) && !strings.Contains(s, "..")
+}
+
+// Path 发布文件在 dir 中的路径
+func Path(dir, version, goos, arch string) string {
+	return filepath.Join(dir, version, fmt.Sprintf("miniPanel-agent-%s-%s", goos, arch))
+}
+
+// GenerateKey 生成签名密钥对，返回 base64 编码的公钥和私钥
+func GenerateKey() (publicKey, privateKey string, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.StdEncoding.EncodeToString(pub), base64.StdEncoding.EncodeToString(priv), nil
+}
+
+// Sign 使用 base64 编码的私钥对数据签名，返回 base64 编码的签名
+func Sign(privateKey string, data []byte) (string, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(privateKey))
+	if err != nil || len(key) != ed25519.PrivateKeySize {
+		return "", fmt.Errorf("invalid private key")
+	}
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(ed25519.PrivateKey(key), data)), nil
+}
+
+// Verify 使用 base64 编码的公钥校验签名
+func Verify(publicKey string, data []byte, signature string) error {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKey))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid public key")
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(signature))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return fmt.Errorf("invalid signature encoding")
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), data, sig) {
+		return fmt.Errorf("signature verification failed")
+	}
+	return nil
+}
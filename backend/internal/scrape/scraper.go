@@ -0,0 +1,211 @@
+// Package scrape 实现拉取模式：服务器定时从无法主动连接服务器的Agent获取监控数据
+package scrape
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"miniPanel/internal/config"
+	"miniPanel/internal/database"
+	"miniPanel/internal/models"
+	"miniPanel/internal/tlsutil"
+)
+
+// Ingester 处理拉取到的数据，与Agent推送的数据走同一流程
+type Ingester interface {
+	IngestScraped(name, ip string, metrics *models.AgentMetrics) error
+}
+
+// Scraper 定时拉取全部目标
+type Scraper struct {
+	store    database.Store
+	ingester Ingester
+	cfg      config.ScrapeConfig
+	client   *http.Client
+
+	mu     sync.Mutex
+	states map[int]*targetState
+}
+
+// targetState 每个目标最近一次处理的数据
+type targetState struct {
+	timestamp time.Time // Agent返回的数据时间，未校正时钟偏差
+	host      *models.HostInfo
+}
+
+// NewScraper 创建拉取器
+func NewScraper(store database.Store, ingester Ingester, cfg config.ScrapeConfig) (*Scraper, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.CAFile != "" {
+		pool, err := tlsutil.LoadCAPool(cfg.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if cfg.Concurrency <= 0 {
+		cfg.Concurrency = 1
+	}
+
+	return &Scraper{
+		store:    store,
+		ingester: ingester,
+		cfg:      cfg,
+		client: &http.Client{
+			Timeout:   time.Duration(cfg.Timeout) * time.Second,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+		states: make(map[int]*targetState),
+	}, nil
+}
+
+// Run 按配置的间隔拉取，直到 stop 关闭
+func (s *Scraper) Run(stop <-chan struct{}) {
+	if s.cfg.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(s.cfg.Interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		s.ScrapeAll()
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// ScrapeAll 并发拉取全部目标，并记录每个目标的结果
+func (s *Scraper) ScrapeAll() {
+	targets, err := s.store.GetScrapeTargets()
+	if err != nil {
+		log.Printf("Failed to load scrape targets: %v", err)
+		return
+	}
+
+	// 删除已移除目标的状态
+	s.mu.Lock()
+	for id := range s.states {
+		if !containsTarget(targets, id) {
+			delete(s.states, id)
+		}
+	}
+	s.mu.Unlock()
+
+	sem := make(chan struct{}, s.cfg.Concurrency)
+	var wg sync.WaitGroup
+	for _, target := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(target models.ScrapeTarget) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			now := time.Now()
+			var msg string
+			if err := s.scrape(target); err != nil {
+				msg = err.Error()
+				log.Printf("Failed to scrape %s: %v", target.URL, err)
+			}
+			if err := s.store.UpdateScrapeStatus(target.ID, now, msg); err != nil {
+				log.Printf("Failed to update scrape status for %s: %v", target.URL, err)
+			}
+		}(target)
+	}
+	wg.Wait()
+}
+
+// scrape 拉取单个目标
+func (s *Scraper) scrape(target models.ScrapeTarget) error {
+	u, err := url.Parse(target.URL)
+	if err != nil {
+		return fmt.Errorf("invalid url: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, target.URL, nil)
+	if err != nil {
+		return err
+	}
+	if s.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.cfg.Token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return fmt.Errorf("agent returned status %d: %s", resp.StatusCode, body)
+	}
+
+	var metrics models.AgentMetrics
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&metrics); err != nil {
+		return fmt.Errorf("invalid response: %v", err)
+	}
+	if metrics.Timestamp.IsZero() {
+		metrics.Timestamp = time.Now()
+	}
+
+	// 节点名称：目标配置 > Agent返回的名称 > 主机名
+	name := target.Name
+	if name == "" {
+		name = resp.Header.Get("Node-Name")
+	}
+	if name == "" {
+		name = u.Hostname()
+	}
+
+	// Agent停止采集时会一直返回同一条数据，不重复保存；
+	// Agent每次都返回主机信息，只在变化时保存。
+	// 按Agent返回的原始时间比较，入库时校正时钟偏差后的时间不能用于比较
+	sampleTime := metrics.Timestamp
+	s.mu.Lock()
+	state, ok := s.states[target.ID]
+	if !ok {
+		state = &targetState{}
+		s.states[target.ID] = state
+	}
+	stale := !sampleTime.After(state.timestamp)
+	host := metrics.Host
+	if host != nil && state.host != nil && *state.host == *host {
+		metrics.Host = nil
+	}
+	s.mu.Unlock()
+	if stale {
+		return fmt.Errorf("no new sample since %s", state.timestamp.UTC().Format(time.RFC3339))
+	}
+
+	if err := s.ingester.IngestScraped(name, u.Hostname(), &metrics); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	state.timestamp = sampleTime
+	if host != nil {
+		state.host = host
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+func containsTarget(targets []models.ScrapeTarget, id int) bool {
+	for _, t := range targets {
+		if t.ID == id {
+			return true
+		}
+	}
+	return false
+}
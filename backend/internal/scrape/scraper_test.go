@@ -0,0 +1,129 @@
+package scrape
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"miniPanel/internal/config"
+	"miniPanel/internal/database"
+	"miniPanel/internal/models"
+)
+
+// targetStore 返回固定的拉取目标，并记录每次拉取的结果
+type targetStore struct {
+	database.Store
+	mu      sync.Mutex
+	targets []models.ScrapeTarget
+	errors  []string
+}
+
+func (s *targetStore) GetScrapeTargets() ([]models.ScrapeTarget, error) {
+	return s.targets, nil
+}
+
+func (s *targetStore) UpdateScrapeStatus(id int, at time.Time, scrapeErr string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors = append(s.errors, scrapeErr)
+	return nil
+}
+
+// skewIngester 像 checkClock 一样按固定的偏差校正数据时间
+type skewIngester struct {
+	skew    time.Duration
+	mu      sync.Mutex
+	samples []time.Time
+}
+
+func (i *skewIngester) IngestScraped(name, ip string, metrics *models.AgentMetrics) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	metrics.Timestamp = metrics.Timestamp.Add(i.skew)
+	i.samples = append(i.samples, metrics.Timestamp)
+	return nil
+}
+
+func TestScrapeClockBehind(t *testing.T) {
+	// Agent时钟慢 5 分钟，每次拉取返回新的数据
+	agentTime := time.Now().Add(-5 * time.Minute)
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agentTime = agentTime.Add(5 * time.Second)
+		json.NewEncoder(w).Encode(models.AgentMetrics{Timestamp: agentTime})
+	}))
+	defer agent.Close()
+
+	store := &targetStore{targets: []models.ScrapeTarget{{ID: 1, Name: "edge-1", URL: agent.URL}}}
+	ingester := &skewIngester{skew: 5 * time.Minute}
+	s, err := NewScraper(store, ingester, config.ScrapeConfig{Timeout: 5, Concurrency: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		s.ScrapeAll()
+	}
+	for i, msg := range store.errors {
+		if msg != "" {
+			t.Errorf("scrape %d: %s", i, msg)
+		}
+	}
+	if len(ingester.samples) != 3 {
+		t.Errorf("ingested %d samples, want 3", len(ingester.samples))
+	}
+}
+
+func TestScrapeStaleSample(t *testing.T) {
+	// Agent停止采集，一直返回同一条数据
+	sampleTime := time.Now().Add(-time.Minute).UTC()
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(models.AgentMetrics{Timestamp: sampleTime})
+	}))
+	defer agent.Close()
+
+	store := &targetStore{targets: []models.ScrapeTarget{{ID: 1, Name: "edge-1", URL: agent.URL}}}
+	ingester := &skewIngester{}
+	s, err := NewScraper(store, ingester, config.ScrapeConfig{Timeout: 5, Concurrency: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.ScrapeAll()
+	s.ScrapeAll()
+	if len(ingester.samples) != 1 {
+		t.Errorf("ingested %d samples, want 1", len(ingester.samples))
+	}
+	if len(store.errors) != 2 || store.errors[0] != "" || !strings.HasPrefix(store.errors[1], "no new sample since") {
+		t.Errorf("scrape errors = %q", store.errors)
+	}
+}
+
+func TestScrapeForgetsRemovedTargets(t *testing.T) {
+	agent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(models.AgentMetrics{Timestamp: time.Now()})
+	}))
+	defer agent.Close()
+
+	store := &targetStore{targets: []models.ScrapeTarget{
+		{ID: 1, Name: "edge-1", URL: agent.URL},
+		{ID: 2, Name: "edge-2", URL: agent.URL},
+	}}
+	s, err := NewScraper(store, &skewIngester{}, config.ScrapeConfig{Timeout: 5, Concurrency: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.ScrapeAll()
+	if len(s.states) != 2 {
+		t.Fatalf("%d target states, want 2", len(s.states))
+	}
+	store.targets = store.targets[1:]
+	s.ScrapeAll()
+	if _, ok := s.states[1]; ok || len(s.states) != 1 {
+		t.Errorf("states after removing target 1: %v", s.states)
+	}
+}
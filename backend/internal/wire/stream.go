@@ -0,0 +1,201 @@
+package wire
+
+import (
+	"fmt"
+
+	"miniPanel/internal/models"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// gRPC 流的服务名和方法名，格式定义见 proto/stream.proto
+const (
+	StreamService = "minipanel.report.v1.AgentStream"
+	StreamMethod  = "Connect"
+)
+
+// 服务器下发的命令
+const (
+	CommandCollectNow    = "collect_now"    // 立即采集并上报一次
+	CommandSendInventory = "send_inventory" // 下次上报时携带主机信息
+	CommandSyncConfig    = "sync_config"    // 集中配置已变化
+)
+
+// ValidCommand 是否为 Agent 支持的命令
+func ValidCommand(cmd string) bool {
+	return cmd == CommandCollectNow || cmd == CommandSendInventory || cmd == CommandSyncConfig
+}
+
+// Codec gRPC 编解码器，直接收发已编码的消息：发送 []byte，接收到 *[]byte
+type Codec struct{}
+
+func (Codec) Marshal(v any) ([]byte, error) {
+	b, ok := v.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("wire: cannot marshal %T", v)
+	}
+	return b, nil
+}
+
+func (Codec) Unmarshal(data []byte, v any) error {
+	p, ok := v.(*[]byte)
+	if !ok {
+		return fmt.Errorf("wire: cannot unmarshal into %T", v)
+	}
+	*p = append((*p)[:0], data...)
+	return nil
+}
+
+// Name 与 protobuf 编解码器同名，其他语言的 protobuf 客户端也可以连接
+func (Codec) Name() string {
+	return "proto"
+}
+
+// AgentMessage Agent 发送的消息，Hello 和 Report 只有一个不为 nil
+type AgentMessage struct {
+	Hello  *Hello
+	Seq    uint64
+	Report *models.AgentMetrics
+}
+
+// Hello 连接后的第一条消息
+type Hello struct {
+	NodeName        string
+	ProtocolVersion int
+	AgentVersion    string
+}
+
+// DecodeAgentMessage 解析 Agent 发送的消息
+func DecodeAgentMessage(b []byte) (*AgentMessage, error) {
+	msg := &AgentMessage{}
+	err := walk(b, func(num protowire.Number, typ protowire.Type, v value) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 1:
+			hello, err := decodeHello(v.bytes)
+			if err != nil {
+				return fmt.Errorf("hello: %v", err)
+			}
+			msg.Hello, msg.Report = hello, nil
+		case 2:
+			seq, report, err := decodeReportRequest(v.bytes)
+			if err != nil {
+				return fmt.Errorf("report: %v", err)
+			}
+			msg.Hello, msg.Seq, msg.Report = nil, seq, report
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if msg.Hello == nil && msg.Report == nil {
+		return nil, fmt.Errorf("empty message")
+	}
+	return msg, nil
+}
+
+func decodeHello(b []byte) (*Hello, error) {
+	h := &Hello{}
+	err := walk(b, func(num protowire.Number, typ protowire.Type, v value) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			h.NodeName = string(v.bytes)
+		case num == 2 && typ == protowire.VarintType:
+			h.ProtocolVersion = int(v.varint)
+		case num == 3 && typ == protowire.BytesType:
+			h.AgentVersion = string(v.bytes)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func decodeReportRequest(b []byte) (uint64, *models.AgentMetrics, error) {
+	var seq uint64
+	var report *models.AgentMetrics
+	err := walk(b, func(num protowire.Number, typ protowire.Type, v value) error {
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			seq = v.varint
+		case num == 2 && typ == protowire.BytesType:
+			m, err := DecodeReport(v.bytes)
+			if err != nil {
+				return err
+			}
+			report = m
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+	if report == nil {
+		// 所有字段均为零值的上报
+		report = &models.AgentMetrics{}
+	}
+	return seq, report, nil
+}
+
+// Ack 对一条上报数据的回复
+type Ack struct {
+	Seq           uint64
+	Status        int // 与 POST /api/metrics 的状态码相同
+	Message       string
+	RetryAfter    int // 秒
+	ConfigVersion string
+}
+
+// EncodeWelcome 编码连接成功的回复
+func EncodeWelcome(nodeID int, nodeName, configVersion string) []byte {
+	var b []byte
+	b = appendVarint(b, 1, uint64(nodeID))
+	b = appendString(b, 2, nodeName)
+	b = appendString(b, 3, configVersion)
+	return appendMessage(nil, 1, b)
+}
+
+// EncodeAck 编码对上报数据的回复
+func EncodeAck(ack Ack) []byte {
+	var b []byte
+	b = appendVarint(b, 1, ack.Seq)
+	b = appendVarint(b, 2, uint64(ack.Status))
+	b = appendString(b, 3, ack.Message)
+	b = appendVarint(b, 4, uint64(ack.RetryAfter))
+	b = appendString(b, 5, ack.ConfigVersion)
+	return appendMessage(nil, 2, b)
+}
+
+// EncodeCommand 编码下发的命令
+func EncodeCommand(cmd, configVersion string) []byte {
+	var b []byte
+	b = appendString(b, 1, cmd)
+	b = appendString(b, 2, configVersion)
+	return appendMessage(nil, 3, b)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
@@ -0,0 +1 @@
+,*Server is busy, retry later *abc123
\ No newline at end of file
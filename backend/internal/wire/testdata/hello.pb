@@ -0,0 +1,3 @@
+
+
+web-011.4.0
\ No newline at end of file
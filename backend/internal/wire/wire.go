@@ -0,0 +1,249 @@
+// Package wire 解析 protobuf 格式的Agent上报数据和 gRPC 流消息，格式定义见 proto/report.proto 和 proto/stream.proto
+package wire
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"miniPanel/internal/models"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ProtocolVersion 服务器支持的最高上报协议版本，更高版本中不认识的字段被忽略
+const ProtocolVersion = 3
+
+// 上报数据的 Content-Type
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+)
+
+// AcceptPost 服务器接受的上报格式，通过 Accept-Post 响应头告知Agent
+const AcceptPost = ContentTypeJSON + ", " + ContentTypeProtobuf
+
+// DecodeReport 解析 protobuf 格式的上报数据
+func DecodeReport(b []byte) (*models.AgentMetrics, error) {
+	m := &models.AgentMetrics{}
+	err := walk(b, func(num protowire.Number, typ protowire.Type, v value) error {
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			m.ProtocolVersion = int(v.varint)
+		case num == 2 && typ == protowire.Fixed64Type:
+			m.CPUPercent = math.Float64frombits(v.varint)
+		case num == 3 && typ == protowire.VarintType:
+			m.MemoryTotal = v.varint
+		case num == 4 && typ == protowire.VarintType:
+			m.MemoryUsed = v.varint
+		case num == 5 && typ == protowire.Fixed64Type:
+			m.MemoryPercent = math.Float64frombits(v.varint)
+		case num == 6 && typ == protowire.Fixed64Type:
+			m.CPUTemp = math.Float64frombits(v.varint)
+		case num == 7 && typ == protowire.BytesType:
+			ts, err := decodeTimestamp(v.bytes)
+			if err != nil {
+				return fmt.Errorf("timestamp: %v", err)
+			}
+			m.Timestamp = ts
+		case num == 8 && typ == protowire.BytesType:
+			key, val, err := decodeLabel(v.bytes)
+			if err != nil {
+				return fmt.Errorf("labels: %v", err)
+			}
+			if m.Labels == nil {
+				m.Labels = map[string]string{}
+			}
+			m.Labels[key] = val
+		case num == 9 && typ == protowire.BytesType:
+			host, err := decodeHost(v.bytes)
+			if err != nil {
+				return fmt.Errorf("host: %v", err)
+			}
+			m.Host = host
+		case num == 10 && typ == protowire.BytesType:
+			ts, err := decodeTimestamp(v.bytes)
+			if err != nil {
+				return fmt.Errorf("sent_at: %v", err)
+			}
+			m.SentAt = ts
+		case num == 11 && typ == protowire.BytesType:
+			check, err := decodeCheck(v.bytes)
+			if err != nil {
+				return fmt.Errorf("checks: %v", err)
+			}
+			m.Checks = append(m.Checks, check)
+		case num == 12 && typ == protowire.BytesType:
+			unit, err := decodeUnit(v.bytes)
+			if err != nil {
+				return fmt.Errorf("units: %v", err)
+			}
+			m.Units = append(m.Units, unit)
+		case num == 13 && typ == protowire.VarintType:
+			m.UnitsUnavailable = v.varint != 0
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// decodeTimestamp 解析 google.protobuf.Timestamp
+func decodeTimestamp(b []byte) (time.Time, error) {
+	var seconds, nanos int64
+	err := walk(b, func(num protowire.Number, typ protowire.Type, v value) error {
+		switch {
+		case num == 1 && typ == protowire.VarintType:
+			seconds = int64(v.varint)
+		case num == 2 && typ == protowire.VarintType:
+			nanos = int64(int32(v.varint))
+		}
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	if nanos < 0 || nanos >= int64(time.Second) {
+		return time.Time{}, fmt.Errorf("nanos out of range: %d", nanos)
+	}
+	return time.Unix(seconds, nanos), nil
+}
+
+// decodeLabel 解析 map<string, string> 的一项
+func decodeLabel(b []byte) (key, val string, err error) {
+	err = walk(b, func(num protowire.Number, typ protowire.Type, v value) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			key = string(v.bytes)
+		case num == 2 && typ == protowire.BytesType:
+			val = string(v.bytes)
+		}
+		return nil
+	})
+	return key, val, err
+}
+
+func decodeCheck(b []byte) (models.CheckResult, error) {
+	var c models.CheckResult
+	err := walk(b, func(num protowire.Number, typ protowire.Type, v value) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			c.Name = string(v.bytes)
+		case num == 2 && typ == protowire.BytesType:
+			c.Type = string(v.bytes)
+		case num == 3 && typ == protowire.BytesType:
+			c.Status = string(v.bytes)
+		case num == 4 && typ == protowire.Fixed64Type:
+			c.LatencyMs = math.Float64frombits(v.varint)
+		case num == 5 && typ == protowire.BytesType:
+			c.Message = string(v.bytes)
+		}
+		return nil
+	})
+	return c, err
+}
+
+func decodeUnit(b []byte) (models.UnitStatus, error) {
+	var u models.UnitStatus
+	err := walk(b, func(num protowire.Number, typ protowire.Type, v value) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			u.Name = string(v.bytes)
+		case num == 2 && typ == protowire.BytesType:
+			u.LoadState = string(v.bytes)
+		case num == 3 && typ == protowire.BytesType:
+			u.ActiveState = string(v.bytes)
+		case num == 4 && typ == protowire.BytesType:
+			u.SubState = string(v.bytes)
+		case num == 5 && typ == protowire.VarintType:
+			u.Restarts = uint32(v.varint)
+		case num == 6 && typ == protowire.VarintType:
+			u.MemoryBytes = v.varint
+		}
+		return nil
+	})
+	return u, err
+}
+
+func decodeHost(b []byte) (*models.HostInfo, error) {
+	h := &models.HostInfo{}
+	err := walk(b, func(num protowire.Number, typ protowire.Type, v value) error {
+		if typ == protowire.BytesType {
+			s := string(v.bytes)
+			switch num {
+			case 1:
+				h.Hostname = s
+			case 2:
+				h.OS = s
+			case 3:
+				h.Platform = s
+			case 4:
+				h.PlatformVersion = s
+			case 5:
+				h.KernelVersion = s
+			case 6:
+				h.Arch = s
+			case 7:
+				h.CPUModel = s
+			case 11:
+				h.AgentVersion = s
+			}
+			return nil
+		}
+		if typ == protowire.VarintType {
+			switch num {
+			case 8:
+				h.CPUCores = int(int32(v.varint))
+			case 9:
+				h.MemoryTotal = v.varint
+			case 10:
+				h.BootTime = v.varint
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// value 一个字段的值，varint 和 fixed64 类型使用 varint，bytes 类型使用 bytes
+type value struct {
+	varint uint64
+	bytes  []byte
+}
+
+// walk 依次处理消息中的字段，不认识的字段类型跳过
+func walk(b []byte, fn func(num protowire.Number, typ protowire.Type, v value) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		var v value
+		switch typ {
+		case protowire.VarintType:
+			v.varint, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			v.varint, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			v.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if err := fn(num, typ, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package wire
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"miniPanel/internal/models"
+)
+
+// testdata 中的文件与Agent agent/internal/wire/testdata 中的相同：
+// Agent编码的消息由这里解析，服务器编码的消息由Agent的测试解析，保证两端格式一致
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+// wantReport Agent测试中 testReport() 编码后应解析出的数据
+func wantReport() *models.AgentMetrics {
+	return &models.AgentMetrics{
+		ProtocolVersion: 3,
+		CPUPercent:      37.5,
+		MemoryTotal:     8 << 30,
+		MemoryUsed:      3 << 30,
+		MemoryPercent:   37.5,
+		CPUTemp:         51.25,
+		Timestamp:       time.Date(2024, 3, 1, 8, 30, 15, 250000000, time.UTC),
+		SentAt:          time.Date(2024, 3, 1, 8, 30, 16, 0, time.UTC),
+		Labels:          map[string]string{"role": "web", "env": "prod"},
+		Host: &models.HostInfo{
+			Hostname:        "web-01",
+			OS:              "linux",
+			Platform:        "debian",
+			PlatformVersion: "12.5",
+			KernelVersion:   "6.1.0-18-amd64",
+			Arch:            "x86_64",
+			CPUModel:        "Intel(R) Xeon(R) CPU",
+			CPUCores:        4,
+			MemoryTotal:     8 << 30,
+			BootTime:        1709251200,
+			AgentVersion:    "1.4.0",
+		},
+		Checks: []models.CheckResult{
+			{Name: "nginx", Type: "http", Status: "ok", LatencyMs: 12.5},
+			{Name: "db", Type: "tcp", Status: "failed", Message: "connection refused"},
+		},
+		Units: []models.UnitStatus{
+			{Name: "nginx.service", LoadState: "loaded", ActiveState: "active", SubState: "running", Restarts: 2, MemoryBytes: 52428800},
+			{Name: "backup.service", LoadState: "loaded", ActiveState: "failed", SubState: "failed"},
+		},
+	}
+}
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// equalReport 比较解析结果，时间按时刻比较
+func equalReport(t *testing.T, got, want *models.AgentMetrics) {
+	t.Helper()
+	if !got.Timestamp.Equal(want.Timestamp) || !got.SentAt.Equal(want.SentAt) {
+		t.Errorf("timestamp/sent_at = %v/%v, want %v/%v", got.Timestamp, got.SentAt, want.Timestamp, want.SentAt)
+	}
+	g, w := *got, *want
+	g.Timestamp, g.SentAt, w.Timestamp, w.SentAt = time.Time{}, time.Time{}, time.Time{}, time.Time{}
+	if !reflect.DeepEqual(g, w) {
+		t.Errorf("decoded %+v\nwant %+v", g, w)
+	}
+}
+
+func TestDecodeReport(t *testing.T) {
+	got, err := DecodeReport(readTestdata(t, "report.pb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	equalReport(t, got, wantReport())
+}
+
+func TestDecodeReportCompat(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want *models.AgentMetrics
+	}{
+		// 旧版Agent不发送协议版本、标签、检查和单元，解析结果保持 nil，服务器据此保留已有数据
+		{"empty", nil, &models.AgentMetrics{}},
+		{"version only", []byte{0x08, 0x03}, &models.AgentMetrics{ProtocolVersion: 3}},
+		{"units unavailable", []byte{0x08, 0x03, 0x68, 0x01}, &models.AgentMetrics{ProtocolVersion: 3, UnitsUnavailable: true}},
+		// 更新版本中不认识的字段被忽略
+		{"unknown fields", []byte{0x08, 0x09, 0xf8, 0x01, 0x05, 0x82, 0x02, 0x01, 0x00}, &models.AgentMetrics{ProtocolVersion: 9}},
+		// 字段类型不匹配时忽略该字段
+		{"wrong wire type", []byte{0x0a, 0x00}, &models.AgentMetrics{}},
+	}
+	for _, tt := range tests {
+		got, err := DecodeReport(tt.data)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: decoded %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeReportErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated varint", []byte{0x08}},
+		{"truncated bytes", []byte{0x42, 0x05, 0x0a}},
+		{"bad tag", []byte{0x00}},
+		// Timestamp.nanos 超出范围
+		{"nanos out of range", []byte{0x3a, 0x06, 0x10, 0x80, 0x94, 0xeb, 0xdc, 0x03}},
+	}
+	for _, tt := range tests {
+		if _, err := DecodeReport(tt.data); err == nil {
+			t.Errorf("%s: decoded without error", tt.name)
+		}
+	}
+}
+
+func TestDecodeAgentMessage(t *testing.T) {
+	msg, err := DecodeAgentMessage(readTestdata(t, "hello.pb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantHello := &Hello{NodeName: "web-01", ProtocolVersion: 3, AgentVersion: "1.4.0"}
+	if !reflect.DeepEqual(msg.Hello, wantHello) || msg.Report != nil {
+		t.Errorf("hello: %+v, want %+v", msg.Hello, wantHello)
+	}
+
+	msg, err = DecodeAgentMessage(readTestdata(t, "report_request.pb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Hello != nil || msg.Seq != 42 || msg.Report == nil {
+		t.Fatalf("report request: %+v", msg)
+	}
+	equalReport(t, msg.Report, wantReport())
+
+	if _, err := DecodeAgentMessage(nil); err == nil {
+		t.Error("empty message decoded without error")
+	}
+}
+
+// golden 比较编码结果与 testdata 中的文件，-update 时重写文件
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	if want := readTestdata(t, name); !bytes.Equal(got, want) {
+		t.Errorf("%s: encoding changed\n got %x\nwant %x", name, got, want)
+	}
+}
+
+func TestEncodeServerMessages(t *testing.T) {
+	golden(t, "welcome.pb", EncodeWelcome(7, "web-01", "abc123"))
+	golden(t, "ack.pb", EncodeAck(Ack{Seq: 42, Status: 429, Message: "Server is busy, retry later", RetryAfter: 5, ConfigVersion: "abc123"}))
+	golden(t, "command.pb", EncodeCommand(CommandSyncConfig, "def456"))
+}
+
+func TestValidCommand(t *testing.T) {
+	for _, cmd := range []string{CommandCollectNow, CommandSendInventory, CommandSyncConfig} {
+		if !ValidCommand(cmd) {
+			t.Errorf("ValidCommand(%q) = false", cmd)
+		}
+	}
+	for _, cmd := range []string{"", "reboot", "SYNC_CONFIG"} {
+		if ValidCommand(cmd) {
+			t.Errorf("ValidCommand(%q) = true", cmd)
+		}
+	}
+}
+
+// TestTestdataInSync 在完整的仓库中检查两端的 testdata 相同
+func TestTestdataInSync(t *testing.T) {
+	agentDir := filepath.Join("..", "..", "..", "agent", "internal", "wire", "testdata")
+	if _, err := os.Stat(agentDir); err != nil {
+		t.Skip("agent source not found")
+	}
+	entries, err := os.ReadDir("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		theirs, err := os.ReadFile(filepath.Join(agentDir, entry.Name()))
+		if err != nil {
+			t.Errorf("%s: %v", entry.Name(), err)
+			continue
+		}
+		if !bytes.Equal(readTestdata(t, entry.Name()), theirs) {
+			t.Errorf("%s differs from the agent copy, copy testdata after running go test -update", entry.Name())
+		}
+	}
+}
@@ -77,7 +77,7 @@ build_agent() {
         local output="../dist/${AGENT_BINARY}-${os}-${arch}"
         
         log_info "编译 $platform..."
-        GOOS=$os GOARCH=$arch go build -o "$output" cmd/main.go
+        GOOS=$os GOARCH=$arch go build -o "$output" ./cmd
         
         if [ $? -eq 0 ]; then
             log_success "$platform 编译完成"
@@ -138,37 +138,38 @@ install_agent_on_host() {
     
     # 生成配置文件
     local node_name=$(ssh $ssh_opts "$host" "hostname")
-    local node_id="node-$(ssh $ssh_opts "$host" "hostname | tr '[:upper:]' '[:lower:]' | sed 's/[^a-z0-9-]/-/g'")"
-    
-    cat > "/tmp/agent-config-$host.yaml" << EOF
-# MiniPanel Agent 配置文件
-server:
-  url: "$SERVER_URL"
-  timeout: 30
-  retry_count: 3
-  retry_interval: 5
-
-agent:
-  node_id: "$node_id"
-  node_name: "$node_name"
-  
-collector:
-  interval: 30
-  enable_cpu: true
-  enable_memory: true
-  enable_temperature: true
-  
-log:
-  level: "info"
-  file: "/var/log/miniPanel/agent.log"
-  max_size: 50
-  max_backups: 3
-  max_age: 7
+    local server_url="$SERVER_URL"
+    # 上报地址需要指向 /api/metrics
+    if [[ "$server_url" != */api/metrics ]]; then
+        server_url="${server_url%/}/api/metrics"
+    fi
+    
+    cat > "/tmp/agent-config-$host.json" << EOF
+{
+  "server": {
+    "url": "$server_url"
+  },
+  "agent": {
+    "node_name": "$node_name",
+    "interval": 30
+  },
+  "collector": {
+    "cpu": true,
+    "memory": true,
+    "temp": true
+  }
+}
 EOF
     
     # 复制配置文件
-    scp $ssh_opts "/tmp/agent-config-$host.yaml" "$host:$CONFIG_DIR/agent.yaml"
-    rm -f "/tmp/agent-config-$host.yaml"
+    scp $ssh_opts "/tmp/agent-config-$host.json" "$host:$CONFIG_DIR/agent.json"
+    rm -f "/tmp/agent-config-$host.json"
+    
+    # 校验配置并测试与服务器的连接，失败时不启用服务
+    if ! ssh $ssh_opts "$host" "$AGENT_DIR/$AGENT_BINARY -check -config $CONFIG_DIR/agent.json"; then
+        log_error "$host Agent配置检查失败，未启用服务"
+        return 1
+    fi
     
     # 创建systemd服务
     cat > "/tmp/miniPanel-agent-$host.service" << EOF
@@ -181,7 +182,7 @@ Type=simple
 User=$SERVICE_USER
 Group=$SERVICE_USER
 WorkingDirectory=$AGENT_DIR
-ExecStart=$AGENT_DIR/$AGENT_BINARY -config $CONFIG_DIR/agent.yaml
+ExecStart=$AGENT_DIR/$AGENT_BINARY -config $CONFIG_DIR/agent.json
 Restart=always
 RestartSec=10
 StandardOutput=journal
@@ -196,8 +197,8 @@ EOF
     rm -f "/tmp/miniPanel-agent-$host.service"
     
     # 设置权限
-    ssh $ssh_opts "$host" "chown -R $SERVICE_USER:$SERVICE_USER $AGENT_DIR $CONFIG_DIR/agent.yaml /var/log/miniPanel"
-    ssh $ssh_opts "$host" "chmod 640 $CONFIG_DIR/agent.yaml"
+    ssh $ssh_opts "$host" "chown -R $SERVICE_USER:$SERVICE_USER $AGENT_DIR $CONFIG_DIR/agent.json /var/log/miniPanel"
+    ssh $ssh_opts "$host" "chmod 640 $CONFIG_DIR/agent.json"
     
     # 启用并启动服务
     ssh $ssh_opts "$host" "systemctl daemon-reload"
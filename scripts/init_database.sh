@@ -57,28 +57,43 @@ create_directories() {
 }
 
 # 初始化数据库
+# 表结构由后端的迁移系统维护，这里调用后端的 migrate up 命令建表
 init_database() {
     local db_path="${1:-backend/data/miniPanel.db}"
-    local sql_file="${2:-scripts/init_db.sql}"
-    
+    local backend_bin="${2:-backend/miniPanel-backend}"
+
     log_info "初始化数据库: $db_path"
-    
-    if [ ! -f "$sql_file" ]; then
-        log_error "SQL文件不存在: $sql_file"
-        exit 1
-    fi
-    
+
     # 创建数据库目录
     mkdir -p "$(dirname "$db_path")"
-    
-    # 执行SQL脚本
-    if sqlite3 "$db_path" < "$sql_file"; then
+    db_path="$(cd "$(dirname "$db_path")" && pwd)/$(basename "$db_path")"
+
+    # 生成仅包含数据库路径的临时配置
+    local tmp_config
+    tmp_config=$(mktemp)
+    echo "{\"database\": {\"driver\": \"sqlite\", \"path\": \"$db_path\", \"backup_before_migrate\": true}}" > "$tmp_config"
+
+    # 执行迁移
+    local status=0
+    if [ -x "$backend_bin" ]; then
+        "$backend_bin" -config "$tmp_config" migrate up || status=$?
+    elif command -v go &> /dev/null && [ -f "backend/cmd/main.go" ]; then
+        log_warning "后端程序不存在: $backend_bin，使用 go run 执行迁移"
+        (cd backend && go run ./cmd -config "$tmp_config" migrate up) || status=$?
+    else
+        log_error "后端程序不存在: $backend_bin，且无法使用 go run"
+        rm -f "$tmp_config"
+        exit 1
+    fi
+    rm -f "$tmp_config"
+
+    if [ $status -eq 0 ]; then
         log_success "数据库初始化完成: $db_path"
     else
         log_error "数据库初始化失败"
         exit 1
     fi
-    
+
     # 设置数据库文件权限
     chmod 644 "$db_path"
     
@@ -91,6 +106,9 @@ init_database() {
     
     log_info "节点表记录数:"
     sqlite3 "$db_path" "SELECT COUNT(*) FROM nodes;"
+
+    log_info "数据库结构版本:"
+    sqlite3 "$db_path" "SELECT MAX(version) FROM schema_version;"
 }
 
 # 生成配置文件
@@ -124,7 +142,7 @@ verify_database() {
     fi
     
     # 检查表是否存在
-    local tables=("users" "nodes" "system_metrics")
+    local tables=("users" "nodes" "system_metrics" "api_keys" "schema_version")
     for table in "${tables[@]}"; do
         if sqlite3 "$db_path" "SELECT name FROM sqlite_master WHERE type='table' AND name='$table';" | grep -q "$table"; then
             log_success "表 $table 存在"
@@ -152,7 +170,7 @@ show_help() {
     echo "选项:"
     echo "  -h, --help              显示帮助信息"
     echo "  -d, --database PATH     指定数据库文件路径 (默认: backend/data/miniPanel.db)"
-    echo "  -s, --sql PATH          指定SQL文件路径 (默认: scripts/init_db.sql)"
+    echo "  -b, --backend PATH      指定后端程序路径，用于执行迁移 (默认: backend/miniPanel-backend)"
     echo "  --skip-deps             跳过依赖检查"
     echo "  --verify-only           仅验证数据库，不进行初始化"
     echo ""
@@ -165,7 +183,7 @@ show_help() {
 # 主函数
 main() {
     local db_path="backend/data/miniPanel.db"
-    local sql_file="scripts/init_db.sql"
+    local backend_bin="backend/miniPanel-backend"
     local skip_deps=false
     local verify_only=false
     
@@ -180,8 +198,8 @@ main() {
                 db_path="$2"
                 shift 2
                 ;;
-            -s|--sql)
-                sql_file="$2"
+            -b|--backend)
+                backend_bin="$2"
                 shift 2
                 ;;
             --skip-deps)
@@ -217,7 +235,7 @@ main() {
     create_directories
     
     # 初始化数据库
-    init_database "$db_path" "$sql_file"
+    init_database "$db_path" "$backend_bin"
     
     # 生成配置文件
     generate_configs
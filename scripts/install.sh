@@ -174,7 +174,7 @@ build_backend() {
     cd backend
     export GOPROXY=https://goproxy.cn,direct
     go mod tidy
-    go build -o "$INSTALL_DIR/backend/miniPanel-backend" cmd/main.go
+    go build -o "$INSTALL_DIR/backend/miniPanel-backend" ./cmd
     
     log_success "后端编译完成"
 }
@@ -185,7 +185,7 @@ build_agent() {
     
     cd ../agent
     go mod tidy
-    go build -o "$INSTALL_DIR/agent/miniPanel-agent" cmd/main.go
+    go build -o "$INSTALL_DIR/agent/miniPanel-agent" ./cmd
     
     log_success "Agent编译完成"
 }
@@ -322,7 +322,7 @@ init_database() {
     log_info "初始化数据库..."
     
     cd "$INSTALL_DIR"
-    ./scripts/init_database.sh -d "$INSTALL_DIR/data/miniPanel.db"
+    ./scripts/init_database.sh -d "$INSTALL_DIR/data/miniPanel.db" -b "$INSTALL_DIR/backend/miniPanel-backend"
     
     # 设置数据库权限
     chown "$SERVICE_USER:$SERVICE_USER" "$INSTALL_DIR/data/miniPanel.db"